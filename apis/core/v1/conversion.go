@@ -0,0 +1,13 @@
+/*
+Unlicensed
+*/
+
+package v1
+
+// v1 is the hub the other versions convert through
+
+// Hub marks this type as a conversion hub.
+func (*Project) Hub() {}
+
+// Hub marks this type as a conversion hub.
+func (*Revision) Hub() {}
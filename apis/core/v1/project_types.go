@@ -0,0 +1,264 @@
+/*
+Unlicensed
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:validation:Enum=none;shallow;recursive
+
+type SubmoduleMode string
+
+// Repository is where the commits of a project are fetched from, and which
+// of them are built
+type Repository struct {
+	URL string `json:"url"`
+
+	// Ref is fully qualified, e.g. refs/heads/main
+	Ref string `json:"ref,omitempty"`
+
+	// SecretRef names a Secret with the credentials used to access the
+	// repository, its submodules and its LFS objects: "username" and
+	// "password" for HTTP(S) URLs, "ssh-privatekey" and
+	// "known_hosts" for SSH URLs, whose host keys are always verified.
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	Submodules SubmoduleMode `json:"submodules,omitempty"`
+	LFS        bool          `json:"lfs,omitempty"`
+
+	// Depth limits the checkout to the given number of commits, 0 fetching
+	// the full history.
+	// +kubebuilder:validation:Minimum=0
+	Depth int32 `json:"depth,omitempty"`
+
+	// SparsePaths restricts the checkout to the given directories
+	SparsePaths []string `json:"sparsePaths,omitempty"`
+
+	// FullHistory makes shallow checkouts fetch the full commit history
+	// before the build runs, for commands such as git describe.
+	FullHistory bool `json:"fullHistory,omitempty"`
+
+	// TrustedKeys lists armored OpenPGP public keys. When set, only commits
+	// signed by one of these keys are built.
+	TrustedKeys []KeySource `json:"trustedKeys,omitempty"`
+
+	// PollInterval is how often the repository is fetched for new commits,
+	// which is only fetched when the project changes if unset
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// KeySource selects a key stored in a Secret or a ConfigMap
+type KeySource struct {
+	SecretKeyRef    *corev1.SecretKeySelector    `json:"secretKeyRef,omitempty"`
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// Build describes the build container of the jobs of a project
+type Build struct {
+	// Image defaults to the manager runner image, or to its Nix image for
+	// Nix builds
+	Image string `json:"image,omitempty"`
+
+	// Command and Args override the image entrypoint and cmd
+	Command []string `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	Env          []EnvVar        `json:"env,omitempty"`
+	EnvFrom      []EnvFromSource `json:"envFrom,omitempty"`
+	SecretMounts []SecretMount   `json:"secretMounts,omitempty"`
+
+	PodTemplate *PodTemplate `json:"podTemplate,omitempty"`
+
+	// Timeout is how long build jobs run before they are failed
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Nix builds the project with Nix instead of the image entrypoint and
+	// cmd
+	Nix *Nix `json:"nix,omitempty"`
+}
+
+// Policies bound the resources a project keeps
+type Policies struct {
+	// Retention is the number of finished revisions kept, the revisions with
+	// the lowest build numbers being deleted first
+	// +kubebuilder:validation:Minimum=1
+	Retention *int32 `json:"retention,omitempty"`
+
+	// CacheQuota is the size above which the least recently used cache
+	// entries of the project are evicted, defaulting to the manager quota
+	CacheQuota *resource.Quantity `json:"cacheQuota,omitempty"`
+}
+
+// SupplyChain attests what successful revisions built
+type SupplyChain struct {
+	// Provenance is generated for successful revisions when set
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Signing signs the artifacts and images of successful revisions when
+	// set
+	Signing *Signing `json:"signing,omitempty"`
+}
+
+type ProjectSpec struct {
+	// TemplateRef names a ProjectTemplate whose fields apply where the
+	// project leaves them unset
+	TemplateRef *corev1.LocalObjectReference `json:"templateRef,omitempty"`
+
+	Repository Repository `json:"repository"`
+	Build      Build      `json:"build,omitempty"`
+
+	Pipeline Pipeline `json:"pipeline,omitempty"`
+
+	// PipelinePath is a YAML pipeline file in the repository that takes
+	// precedence over Pipeline when the built commit contains it.
+	PipelinePath string `json:"pipelinePath,omitempty"`
+
+	Policies    Policies    `json:"policies,omitempty"`
+	SupplyChain SupplyChain `json:"supplyChain,omitempty"`
+}
+
+// Provenance describes how revisions were built in a signed SLSA provenance
+// statement, stored along with their artifacts
+type Provenance struct {
+	// SigningKey selects a PEM-encoded ECDSA P-256 or Ed25519 private key
+	SigningKey corev1.SecretKeySelector `json:"signingKey"`
+}
+
+// Signing signs artifacts and images with cosign-compatible signatures.
+// Image signatures are not pushed as .sig tags: use hedronctl verify.
+type Signing struct {
+	// SecretRef names a Secret with an ECDSA P-256 private key in cosign.key,
+	// encrypted with cosign.password if any, as created by cosign
+	// generate-key-pair k8s://<namespace>/<name>
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+}
+
+// Nix builds an attribute of a Nix expression or flake of the workspace
+type Nix struct {
+	// Flake builds the flake of the workspace with nix build instead of
+	// building File with nix-build
+	Flake bool `json:"flake,omitempty"`
+
+	// File is relative to the workspace, defaulting to default.nix
+	File string `json:"file,omitempty"`
+
+	// Attribute is built instead of the whole expression, or the default
+	// package of the flake
+	Attribute string `json:"attribute,omitempty"`
+
+	Args []string `json:"args,omitempty"`
+
+	// CacheClaimName names a ReadWriteMany PersistentVolumeClaim holding a
+	// binary cache shared between builds, which substitutes store paths and
+	// receives the outputs of successful trusted builds
+	CacheClaimName string `json:"cacheClaimName,omitempty"`
+
+	// CacheSigningKey selects a secret key generated with
+	// nix-store --generate-binary-cache-key, which signs the outputs copied
+	// to the shared cache
+	CacheSigningKey *corev1.SecretKeySelector `json:"cacheSigningKey,omitempty"`
+
+	// CachePublicKey is the public key of CacheSigningKey, e.g.
+	// "cache-1:...". Paths are only substituted from the shared cache when
+	// signed with it.
+	CachePublicKey string `json:"cachePublicKey,omitempty"`
+
+	// BinaryCache receives the signed outputs of successful builds of
+	// trusted revisions, copied after the build container exits
+	BinaryCache *NixBinaryCache `json:"binaryCache,omitempty"`
+}
+
+// NixBinaryCache is a binary cache developers substitute build outputs from
+type NixBinaryCache struct {
+	// URL is an S3-compatible cache, e.g.
+	// "s3://cache?endpoint=minio.example.com&region=us-east-1", or a file
+	// cache, e.g. "file:///cache"
+	// +kubebuilder:validation:Pattern=`^(s3|file)://`
+	URL string `json:"url"`
+
+	// ClaimName names the PersistentVolumeClaim mounted at the path of file
+	// caches
+	ClaimName string `json:"claimName,omitempty"`
+
+	// SigningKey selects a secret key generated with
+	// nix-store --generate-binary-cache-key
+	SigningKey corev1.SecretKeySelector `json:"signingKey"`
+
+	// CredentialsSecretRef names a Secret with the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY of S3-compatible caches
+	CredentialsSecretRef *corev1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// PodTemplate overrides the spec of build pods. Resources and the user of
+// the security context apply to the build container, the other fields to
+// the pod. Service accounts must be allowed by the manager, and builds cannot
+// run as root.
+type PodTemplate struct {
+	Resources          corev1.ResourceRequirements   `json:"resources,omitempty"`
+	NodeSelector       map[string]string             `json:"nodeSelector,omitempty"`
+	Tolerations        []corev1.Toleration           `json:"tolerations,omitempty"`
+	Affinity           *corev1.Affinity              `json:"affinity,omitempty"`
+	ServiceAccountName string                        `json:"serviceAccountName,omitempty"`
+	SecurityContext    *corev1.PodSecurityContext    `json:"securityContext,omitempty"`
+	PriorityClassName  string                        `json:"priorityClassName,omitempty"`
+	ImagePullSecrets   []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+}
+
+// EnvVar is an environment variable set on the build container. Protected
+// variables are only set for builds the project creates for its ref, and
+// are withheld from pull requests coming from forks.
+type EnvVar struct {
+	corev1.EnvVar `json:",inline"`
+	Protected     bool `json:"protected,omitempty"`
+}
+
+// EnvFromSource sets the build environment from a Secret or a ConfigMap.
+// Protected sources are withheld like protected variables.
+type EnvFromSource struct {
+	corev1.EnvFromSource `json:",inline"`
+	Protected            bool `json:"protected,omitempty"`
+}
+
+// SecretMount mounts the keys of a Secret as files in the build container.
+// Protected mounts are withheld like protected variables.
+type SecretMount struct {
+	SecretName string             `json:"secretName"`
+	MountPath  string             `json:"mountPath"`
+	Items      []corev1.KeyToPath `json:"items,omitempty"`
+	Protected  bool               `json:"protected,omitempty"`
+}
+
+type ProjectStatus struct {
+	LastRevision string `json:"lastRevision,omitempty"`
+	BuildNumber  int64  `json:"buildNumber,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.repository.url`
+// +kubebuilder:printcolumn:name="Ref",type=string,JSONPath=`.spec.repository.ref`
+// +kubebuilder:printcolumn:name="Last Revision",type=string,JSONPath=`.status.lastRevision`
+
+type Project struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProjectSpec   `json:"spec,omitempty"`
+	Status ProjectStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type ProjectList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Project `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Project{}, &ProjectList{})
+}
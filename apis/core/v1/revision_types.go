@@ -0,0 +1,205 @@
+/*
+Unlicensed
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:validation:Enum=Pending;Failed;Succeeded;Skipped;Rejected
+
+type State string
+
+type RevisionSpec struct {
+	ProjectRef corev1.LocalObjectReference `json:"projectRef"`
+
+	// Commit is the full SHA of the commit built
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{40}$`
+	Commit string `json:"commit"`
+
+	Ref         string            `json:"ref,omitempty"`
+	BuildNumber int64             `json:"buildNumber,omitempty"`
+	Directives  map[string]string `json:"directives,omitempty"`
+	Pipeline    *Pipeline         `json:"pipeline,omitempty"`
+	PullRequest *PullRequest      `json:"pullRequest,omitempty"`
+
+	// Template is the project template the revision was built with
+	Template *TemplateReference `json:"template,omitempty"`
+}
+
+// TemplateReference identifies a generation of a project template
+type TemplateReference struct {
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+}
+
+// PullRequest identifies the pull request a revision is built for
+type PullRequest struct {
+	Number int64 `json:"number"`
+
+	// SourceURL is the URL of the repository the pull request comes from,
+	// which differs from the project repository for forks
+	SourceURL string `json:"sourceURL,omitempty"`
+}
+
+// CellStatus is the state of the build of one matrix combination
+type CellStatus struct {
+	Name         string            `json:"name"`
+	Values       map[string]string `json:"values,omitempty"`
+	Job          string            `json:"job,omitempty"`
+	State        State             `json:"state,omitempty"`
+	Reason       string            `json:"reason,omitempty"`
+	AllowFailure bool              `json:"allowFailure,omitempty"`
+}
+
+// ArtifactStatus describes an artifact uploaded by the build of a cell,
+// which the manager serves at /artifacts/<namespace>/<revision>/<job>/<name>
+type ArtifactStatus struct {
+	Name   string `json:"name"`
+	Cell   string `json:"cell,omitempty"`
+	Job    string `json:"job"`
+	Size   int64  `json:"size"`
+	Digest string `json:"digest"`
+}
+
+// TestSummary totals the results of the test reports of a revision
+type TestSummary struct {
+	Passed   int64           `json:"passed"`
+	Failed   int64           `json:"failed"`
+	Skipped  int64           `json:"skipped"`
+	Duration metav1.Duration `json:"duration,omitempty"`
+
+	// Failures lists the first failed tests
+	Failures []TestFailure `json:"failures,omitempty"`
+}
+
+type TestFailure struct {
+	Cell    string `json:"cell,omitempty"`
+	Suite   string `json:"suite,omitempty"`
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+}
+
+// CoverageStatus is the code coverage of a revision. Percentages are
+// formatted with two decimals.
+type CoverageStatus struct {
+	Covered    int64  `json:"covered"`
+	Total      int64  `json:"total"`
+	Percentage string `json:"percentage"`
+
+	// BaseRevision is the last successful revision of the tracked branch,
+	// which Delta is the difference in percentage points with
+	BaseRevision string `json:"baseRevision,omitempty"`
+	Delta        string `json:"delta,omitempty"`
+}
+
+// ImageStatus describes an image pushed by the build of a cell
+type ImageStatus struct {
+	Name         string   `json:"name"`
+	Cell         string   `json:"cell,omitempty"`
+	Destinations []string `json:"destinations"`
+	Digest       string   `json:"digest"`
+}
+
+// SBOMStatus summarizes a software bill of materials uploaded as an
+// artifact by the build of a cell
+type SBOMStatus struct {
+	// Name is the name of the artifact
+	Name string `json:"name"`
+	Cell string `json:"cell,omitempty"`
+	Job  string `json:"job"`
+
+	// Image is the image described, the workspace when empty
+	Image string `json:"image,omitempty"`
+
+	Components int64    `json:"components"`
+	Licenses   []string `json:"licenses,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Artifact;Image
+
+type SignatureKind string
+
+const (
+	ArtifactSignature SignatureKind = "Artifact"
+	ImageSignature    SignatureKind = "Image"
+)
+
+// SignatureStatus is the base64-encoded cosign signature of an artifact,
+// named <job>/<name>, or of an image, named after its repository
+type SignatureStatus struct {
+	Kind      SignatureKind `json:"kind"`
+	Name      string        `json:"name"`
+	Digest    string        `json:"digest"`
+	Signature string        `json:"signature"`
+}
+
+// NixOutput is a store path produced by the Nix build of a cell
+type NixOutput struct {
+	Cell    string `json:"cell,omitempty"`
+	Path    string `json:"path"`
+	NarHash string `json:"narHash"`
+
+	// Cache is the URL of the binary cache the path was uploaded to
+	Cache string `json:"cache,omitempty"`
+}
+
+// Outputs are what the builds of a revision produced
+type Outputs struct {
+	Artifacts []ArtifactStatus `json:"artifacts,omitempty"`
+	Images    []ImageStatus    `json:"images,omitempty"`
+	Nix       []NixOutput      `json:"nix,omitempty"`
+	SBOMs     []SBOMStatus     `json:"sboms,omitempty"`
+}
+
+// Attestations vouch for the outputs of a successful revision
+type Attestations struct {
+	Signatures []SignatureStatus `json:"signatures,omitempty"`
+
+	// Provenance is the signed provenance of the artifacts and images of
+	// the revision
+	Provenance *ArtifactStatus `json:"provenance,omitempty"`
+}
+
+type RevisionStatus struct {
+	State  State        `json:"state,omitempty"`
+	Reason string       `json:"reason,omitempty"`
+	Cells  []CellStatus `json:"cells,omitempty"`
+
+	Tests    *TestSummary    `json:"tests,omitempty"`
+	Coverage *CoverageStatus `json:"coverage,omitempty"`
+
+	Outputs      Outputs      `json:"outputs,omitempty"`
+	Attestations Attestations `json:"attestations,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Project",type=string,JSONPath=`.spec.projectRef.name`
+// +kubebuilder:printcolumn:name="Build",type=integer,JSONPath=`.spec.buildNumber`
+// +kubebuilder:printcolumn:name="Commit",type=string,JSONPath=`.spec.commit`,priority=1
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+type Revision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RevisionSpec   `json:"spec,omitempty"`
+	Status RevisionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type RevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Revision `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Revision{}, &RevisionList{})
+}
@@ -0,0 +1,107 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	fuzz "github.com/google/gofuzz"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	v1 "github.com/thmzlt/hedron/apis/core/v1"
+)
+
+const fuzzIterations = 500
+
+// newFuzzer returns a fuzzer that leaves the type metadata empty, which
+// conversions do not set
+func newFuzzer(t *testing.T) *fuzz.Fuzzer {
+	seed := time.Now().UnixNano()
+	t.Logf("seed: %d", seed)
+
+	return fuzz.New().NilChance(0.2).RandSource(rand.NewSource(seed)).Funcs(
+		func(typeMeta *metav1.TypeMeta, c fuzz.Continue) {},
+	)
+}
+
+func TestProjectRoundTrip(t *testing.T) {
+	fuzzer := newFuzzer(t)
+
+	for i := 0; i < fuzzIterations; i++ {
+		var original Project
+		fuzzer.Fuzz(&original)
+
+		var hub v1.Project
+		var converted Project
+		if err := original.ConvertTo(&hub); err != nil {
+			t.Fatal(err)
+		}
+		if err := converted.ConvertFrom(&hub); err != nil {
+			t.Fatal(err)
+		}
+		if !apiequality.Semantic.DeepEqual(original, converted) {
+			t.Fatalf("v1beta1 project changed in a round trip: %s", diff.ObjectReflectDiff(original, converted))
+		}
+	}
+
+	for i := 0; i < fuzzIterations; i++ {
+		var original v1.Project
+		fuzzer.Fuzz(&original)
+
+		var spoke Project
+		var converted v1.Project
+		if err := spoke.ConvertFrom(&original); err != nil {
+			t.Fatal(err)
+		}
+		if err := spoke.ConvertTo(&converted); err != nil {
+			t.Fatal(err)
+		}
+		if !apiequality.Semantic.DeepEqual(original, converted) {
+			t.Fatalf("v1 project changed in a round trip: %s", diff.ObjectReflectDiff(original, converted))
+		}
+	}
+}
+
+func TestRevisionRoundTrip(t *testing.T) {
+	fuzzer := newFuzzer(t)
+
+	for i := 0; i < fuzzIterations; i++ {
+		var original Revision
+		fuzzer.Fuzz(&original)
+
+		var hub v1.Revision
+		var converted Revision
+		if err := original.ConvertTo(&hub); err != nil {
+			t.Fatal(err)
+		}
+		if err := converted.ConvertFrom(&hub); err != nil {
+			t.Fatal(err)
+		}
+		if !apiequality.Semantic.DeepEqual(original, converted) {
+			t.Fatalf("v1beta1 revision changed in a round trip: %s", diff.ObjectReflectDiff(original, converted))
+		}
+	}
+
+	for i := 0; i < fuzzIterations; i++ {
+		var original v1.Revision
+		fuzzer.Fuzz(&original)
+
+		var spoke Revision
+		var converted v1.Revision
+		if err := spoke.ConvertFrom(&original); err != nil {
+			t.Fatal(err)
+		}
+		if err := spoke.ConvertTo(&converted); err != nil {
+			t.Fatal(err)
+		}
+		if !apiequality.Semantic.DeepEqual(original, converted) {
+			t.Fatalf("v1 revision changed in a round trip: %s", diff.ObjectReflectDiff(original, converted))
+		}
+	}
+}
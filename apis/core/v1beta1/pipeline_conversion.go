@@ -0,0 +1,218 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	v1 "github.com/thmzlt/hedron/apis/core/v1"
+)
+
+// The pipeline shape is the same in v1, so pipelines convert field by field.
+// Types whose fields all have the same types convert directly.
+
+func pipelineToV1(in Pipeline) v1.Pipeline {
+	out := v1.Pipeline{
+		Services:  servicesToV1(in.Services),
+		Caches:    cachesToV1(in.Caches),
+		Artifacts: artifactsToV1(in.Artifacts),
+		Reports:   reportsToV1(in.Reports),
+		Images:    imageBuildsToV1(in.Images),
+	}
+
+	if in.Matrix != nil {
+		out.Matrix = &v1.Matrix{
+			Include:       in.Matrix.Include,
+			Exclude:       in.Matrix.Exclude,
+			AllowFailures: in.Matrix.AllowFailures,
+		}
+		if in.Matrix.Axes != nil {
+			out.Matrix.Axes = make([]v1.Axis, len(in.Matrix.Axes))
+			for i, axis := range in.Matrix.Axes {
+				out.Matrix.Axes[i] = v1.Axis(axis)
+			}
+		}
+	}
+
+	if in.Coverage != nil {
+		out.Coverage = &v1.Coverage{
+			Format:      v1.CoverageFormat(in.Coverage.Format),
+			Paths:       in.Coverage.Paths,
+			MaxDecrease: in.Coverage.MaxDecrease,
+		}
+	}
+
+	if in.SBOM != nil {
+		out.SBOM = &v1.SBOM{Format: v1.SBOMFormat(in.SBOM.Format)}
+	}
+
+	return out
+}
+
+func pipelineFromV1(in v1.Pipeline) Pipeline {
+	out := Pipeline{
+		Services:  servicesFromV1(in.Services),
+		Caches:    cachesFromV1(in.Caches),
+		Artifacts: artifactsFromV1(in.Artifacts),
+		Reports:   reportsFromV1(in.Reports),
+		Images:    imageBuildsFromV1(in.Images),
+	}
+
+	if in.Matrix != nil {
+		out.Matrix = &Matrix{
+			Include:       in.Matrix.Include,
+			Exclude:       in.Matrix.Exclude,
+			AllowFailures: in.Matrix.AllowFailures,
+		}
+		if in.Matrix.Axes != nil {
+			out.Matrix.Axes = make([]Axis, len(in.Matrix.Axes))
+			for i, axis := range in.Matrix.Axes {
+				out.Matrix.Axes[i] = Axis(axis)
+			}
+		}
+	}
+
+	if in.Coverage != nil {
+		out.Coverage = &Coverage{
+			Format:      CoverageFormat(in.Coverage.Format),
+			Paths:       in.Coverage.Paths,
+			MaxDecrease: in.Coverage.MaxDecrease,
+		}
+	}
+
+	if in.SBOM != nil {
+		out.SBOM = &SBOM{Format: SBOMFormat(in.SBOM.Format)}
+	}
+
+	return out
+}
+
+func servicesToV1(in []Service) []v1.Service {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.Service, len(in))
+	for i, service := range in {
+		out[i] = v1.Service(service)
+	}
+
+	return out
+}
+
+func servicesFromV1(in []v1.Service) []Service {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]Service, len(in))
+	for i, service := range in {
+		out[i] = Service(service)
+	}
+
+	return out
+}
+
+func cachesToV1(in []Cache) []v1.Cache {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.Cache, len(in))
+	for i, cache := range in {
+		out[i] = v1.Cache(cache)
+	}
+
+	return out
+}
+
+func cachesFromV1(in []v1.Cache) []Cache {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]Cache, len(in))
+	for i, cache := range in {
+		out[i] = Cache(cache)
+	}
+
+	return out
+}
+
+func artifactsToV1(in []Artifact) []v1.Artifact {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.Artifact, len(in))
+	for i, artifact := range in {
+		out[i] = v1.Artifact(artifact)
+	}
+
+	return out
+}
+
+func artifactsFromV1(in []v1.Artifact) []Artifact {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]Artifact, len(in))
+	for i, artifact := range in {
+		out[i] = Artifact(artifact)
+	}
+
+	return out
+}
+
+func reportsToV1(in []Report) []v1.Report {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.Report, len(in))
+	for i, report := range in {
+		out[i] = v1.Report{Name: report.Name, Format: v1.ReportFormat(report.Format), Paths: report.Paths}
+	}
+
+	return out
+}
+
+func reportsFromV1(in []v1.Report) []Report {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]Report, len(in))
+	for i, report := range in {
+		out[i] = Report{Name: report.Name, Format: ReportFormat(report.Format), Paths: report.Paths}
+	}
+
+	return out
+}
+
+func imageBuildsToV1(in []ImageBuild) []v1.ImageBuild {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.ImageBuild, len(in))
+	for i, image := range in {
+		out[i] = v1.ImageBuild(image)
+	}
+
+	return out
+}
+
+func imageBuildsFromV1(in []v1.ImageBuild) []ImageBuild {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]ImageBuild, len(in))
+	for i, image := range in {
+		out[i] = ImageBuild(image)
+	}
+
+	return out
+}
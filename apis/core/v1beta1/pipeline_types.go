@@ -0,0 +1,173 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Pipeline describes how the revisions of a project are built. It is either
+// declared on the project or read from a file in the repository, and copied
+// onto each revision when it is created.
+type Pipeline struct {
+	Matrix *Matrix `json:"matrix,omitempty"`
+
+	// Services run next to the build, which starts once they are all ready.
+	// The build command is then run by /bin/sh, which the build image must
+	// provide, and must be spelled out by the entrypoint or cmd.
+	Services []Service `json:"services,omitempty"`
+
+	// Caches keep paths of the workspace between builds. All revisions restore
+	// them, but only trusted revisions save them.
+	Caches []Cache `json:"caches,omitempty"`
+
+	// Artifacts are uploaded once the build exits
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+
+	// Reports are test reports uploaded once the build exits, whose results
+	// are summarized on the revision
+	Reports []Report `json:"reports,omitempty"`
+
+	// Coverage is uploaded once the build exits and compared to the
+	// coverage of the tracked branch
+	Coverage *Coverage `json:"coverage,omitempty"`
+
+	// Images are built from the workspace once the build succeeds, and pushed
+	// by trusted revisions
+	Images []ImageBuild `json:"images,omitempty"`
+
+	// SBOM generates software bills of materials of the workspace and of
+	// the images once the build succeeds, uploaded as artifacts
+	SBOM *SBOM `json:"sbom,omitempty"`
+}
+
+// Matrix fans a revision out into one build per combination of axis values
+type Matrix struct {
+	Axes []Axis `json:"axes,omitempty"`
+
+	// Include adds combinations, or extends the existing combinations that
+	// match all of its axis values with extra values
+	Include []map[string]string `json:"include,omitempty"`
+
+	// Exclude removes the combinations that match all of its values
+	Exclude []map[string]string `json:"exclude,omitempty"`
+
+	// AllowFailures lists combinations whose failure does not fail the
+	// revision
+	AllowFailures []map[string]string `json:"allowFailures,omitempty"`
+}
+
+type Axis struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Service is a container, such as a database, that the build talks to over
+// localhost
+type Service struct {
+	Name           string                 `json:"name"`
+	Image          string                 `json:"image"`
+	Command        []string               `json:"command,omitempty"`
+	Args           []string               `json:"args,omitempty"`
+	Env            []corev1.EnvVar        `json:"env,omitempty"`
+	Ports          []corev1.ContainerPort `json:"ports,omitempty"`
+	ReadinessProbe *corev1.Probe          `json:"readinessProbe,omitempty"`
+}
+
+// Cache is restored before the build and saved after it when no entry
+// matches its key, a template such as "go-{{ .HashFiles "go.sum" }}"
+type Cache struct {
+	Key string `json:"key"`
+
+	// Paths are relative to the workspace
+	Paths []string `json:"paths"`
+}
+
+// Artifact is a build output kept with the revision. Artifacts named with a
+// .tar.gz or .tgz extension archive all the files their paths match, other
+// artifacts are the single file their paths match.
+type Artifact struct {
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9._-]+$`
+	Name string `json:"name"`
+
+	// Paths are glob patterns relative to the workspace
+	Paths []string `json:"paths"`
+}
+
+// +kubebuilder:validation:Enum=junit;go-test-json
+
+type ReportFormat string
+
+// Report is a set of test report files, such as JUnit XML files or the output
+// of go test -json
+type Report struct {
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9._-]+$`
+	Name   string       `json:"name"`
+	Format ReportFormat `json:"format"`
+
+	// Paths are glob patterns relative to the workspace
+	Paths []string `json:"paths"`
+}
+
+// +kubebuilder:validation:Enum=go;cobertura;lcov
+
+type CoverageFormat string
+
+// Coverage is a set of code coverage reports, which are merged across the
+// cells of a revision
+type Coverage struct {
+	Format CoverageFormat `json:"format"`
+
+	// Paths are glob patterns relative to the workspace
+	Paths []string `json:"paths"`
+
+	// MaxDecrease fails revisions whose coverage is lower than the coverage
+	// of the last successful revision of the tracked branch by more than the
+	// given percentage points, e.g. "0.5"
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	MaxDecrease string `json:"maxDecrease,omitempty"`
+}
+
+// ImageBuild builds an image without a Docker daemon and pushes it. Build
+// args and destinations are templates, e.g.
+// "registry.example.com/app:{{ .ShortCommit }}".
+type ImageBuild struct {
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Dockerfile is relative to the workspace, defaulting to
+	// <context>/Dockerfile
+	Dockerfile string `json:"dockerfile,omitempty"`
+
+	// Context is relative to the workspace, defaulting to its root
+	Context string `json:"context,omitempty"`
+
+	BuildArgs    map[string]string `json:"buildArgs,omitempty"`
+	Destinations []string          `json:"destinations"`
+
+	// SecretRef names a kubernetes.io/dockerconfigjson Secret with the
+	// credentials of the registries images are pulled from and pushed to,
+	// only given to trusted revisions
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Insecure allows registries served over HTTP or with untrusted
+	// certificates, such as a registry service of the pipeline
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=cyclonedx;spdx
+
+type SBOMFormat string
+
+const (
+	CycloneDX SBOMFormat = "cyclonedx"
+	SPDX      SBOMFormat = "spdx"
+)
+
+// SBOM selects the format of software bills of materials, CycloneDX unless
+// set
+type SBOM struct {
+	Format SBOMFormat `json:"format,omitempty"`
+}
@@ -0,0 +1,236 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	v1 "github.com/thmzlt/hedron/apis/core/v1"
+)
+
+var _ conversion.Convertible = &Project{}
+
+// ConvertTo converts this Project to the Hub version (v1).
+func (src *Project) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1.Project)
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	repository := src.Spec.Repository
+	dst.Spec = v1.ProjectSpec{
+		TemplateRef: src.Spec.TemplateRef,
+		Repository: v1.Repository{
+			URL:          repository.URL,
+			Ref:          repository.Ref,
+			SecretRef:    repository.SecretRef,
+			Submodules:   v1.SubmoduleMode(repository.Submodules),
+			LFS:          repository.LFS,
+			Depth:        repository.Depth,
+			SparsePaths:  repository.SparsePaths,
+			FullHistory:  repository.History,
+			TrustedKeys:  keySourcesToV1(src.Spec.TrustedKeys),
+			PollInterval: src.Spec.PollInterval,
+		},
+		Build: v1.Build{
+			Image:        src.Spec.Image.Name,
+			Command:      src.Spec.Image.Entrypoint,
+			Args:         src.Spec.Image.Cmd,
+			Env:          envToV1(src.Spec.Env),
+			EnvFrom:      envFromToV1(src.Spec.EnvFrom),
+			SecretMounts: secretMountsToV1(src.Spec.SecretMounts),
+			PodTemplate:  (*v1.PodTemplate)(src.Spec.PodTemplate),
+			Timeout:      src.Spec.Timeout,
+		},
+		Pipeline:     pipelineToV1(src.Spec.Pipeline),
+		PipelinePath: src.Spec.PipelinePath,
+		Policies: v1.Policies{
+			Retention:  src.Spec.Retention,
+			CacheQuota: src.Spec.CacheQuota,
+		},
+		SupplyChain: v1.SupplyChain{
+			Provenance: (*v1.Provenance)(src.Spec.Provenance),
+			Signing:    (*v1.Signing)(src.Spec.Signing),
+		},
+	}
+
+	if nix := src.Spec.Nix; nix != nil {
+		dst.Spec.Build.Nix = &v1.Nix{
+			Flake:           nix.Flake,
+			File:            nix.File,
+			Attribute:       nix.Attribute,
+			Args:            nix.Args,
+			CacheClaimName:  nix.CacheClaimName,
+			CacheSigningKey: nix.CacheSigningKey,
+			CachePublicKey:  nix.CachePublicKey,
+			BinaryCache:     (*v1.NixBinaryCache)(nix.BinaryCache),
+		}
+	}
+
+	dst.Status = v1.ProjectStatus(src.Status)
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1) to this version.
+func (dst *Project) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1.Project)
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	repository := src.Spec.Repository
+	build := src.Spec.Build
+	dst.Spec = ProjectSpec{
+		TemplateRef: src.Spec.TemplateRef,
+		Image: Image{
+			Name:       build.Image,
+			Entrypoint: build.Command,
+			Cmd:        build.Args,
+		},
+		Repository: Repository{
+			URL:         repository.URL,
+			Ref:         repository.Ref,
+			SecretRef:   repository.SecretRef,
+			Submodules:  SubmoduleMode(repository.Submodules),
+			LFS:         repository.LFS,
+			Depth:       repository.Depth,
+			SparsePaths: repository.SparsePaths,
+			History:     repository.FullHistory,
+		},
+		TrustedKeys:  keySourcesFromV1(repository.TrustedKeys),
+		Pipeline:     pipelineFromV1(src.Spec.Pipeline),
+		PipelinePath: src.Spec.PipelinePath,
+		Env:          envFromV1(build.Env),
+		EnvFrom:      envFromFromV1(build.EnvFrom),
+		SecretMounts: secretMountsFromV1(build.SecretMounts),
+		PodTemplate:  (*PodTemplate)(build.PodTemplate),
+		CacheQuota:   src.Spec.Policies.CacheQuota,
+		PollInterval: repository.PollInterval,
+		Timeout:      build.Timeout,
+		Retention:    src.Spec.Policies.Retention,
+		Provenance:   (*Provenance)(src.Spec.SupplyChain.Provenance),
+		Signing:      (*Signing)(src.Spec.SupplyChain.Signing),
+	}
+
+	if nix := build.Nix; nix != nil {
+		dst.Spec.Nix = &Nix{
+			Flake:           nix.Flake,
+			File:            nix.File,
+			Attribute:       nix.Attribute,
+			Args:            nix.Args,
+			CacheClaimName:  nix.CacheClaimName,
+			CacheSigningKey: nix.CacheSigningKey,
+			CachePublicKey:  nix.CachePublicKey,
+			BinaryCache:     (*NixBinaryCache)(nix.BinaryCache),
+		}
+	}
+
+	dst.Status = ProjectStatus(src.Status)
+
+	return nil
+}
+
+func keySourcesToV1(in []KeySource) []v1.KeySource {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.KeySource, len(in))
+	for i, key := range in {
+		out[i] = v1.KeySource(key)
+	}
+
+	return out
+}
+
+func keySourcesFromV1(in []v1.KeySource) []KeySource {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]KeySource, len(in))
+	for i, key := range in {
+		out[i] = KeySource(key)
+	}
+
+	return out
+}
+
+func envToV1(in []EnvVar) []v1.EnvVar {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.EnvVar, len(in))
+	for i, env := range in {
+		out[i] = v1.EnvVar(env)
+	}
+
+	return out
+}
+
+func envFromV1(in []v1.EnvVar) []EnvVar {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]EnvVar, len(in))
+	for i, env := range in {
+		out[i] = EnvVar(env)
+	}
+
+	return out
+}
+
+func envFromToV1(in []EnvFromSource) []v1.EnvFromSource {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.EnvFromSource, len(in))
+	for i, source := range in {
+		out[i] = v1.EnvFromSource(source)
+	}
+
+	return out
+}
+
+func envFromFromV1(in []v1.EnvFromSource) []EnvFromSource {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]EnvFromSource, len(in))
+	for i, source := range in {
+		out[i] = EnvFromSource(source)
+	}
+
+	return out
+}
+
+func secretMountsToV1(in []SecretMount) []v1.SecretMount {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.SecretMount, len(in))
+	for i, mount := range in {
+		out[i] = v1.SecretMount(mount)
+	}
+
+	return out
+}
+
+func secretMountsFromV1(in []v1.SecretMount) []SecretMount {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]SecretMount, len(in))
+	for i, mount := range in {
+		out[i] = SecretMount(mount)
+	}
+
+	return out
+}
@@ -0,0 +1,99 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultRef is the ref of projects whose remote default branch cannot be
+// resolved
+const DefaultRef = "refs/heads/main"
+
+// ProjectDefaults are the cluster-wide defaults of projects, read from the
+// image, pollInterval, timeout and retention keys of a ConfigMap
+// +kubebuilder:object:generate=false
+type ProjectDefaults struct {
+	Image        string
+	PollInterval *metav1.Duration
+	Timeout      *metav1.Duration
+	Retention    *int32
+}
+
+// ParseProjectDefaults parses the data of a project defaults ConfigMap
+func ParseProjectDefaults(data map[string]string) (ProjectDefaults, error) {
+	defaults := ProjectDefaults{Image: data["image"]}
+
+	for key, duration := range map[string]**metav1.Duration{
+		"pollInterval": &defaults.PollInterval,
+		"timeout":      &defaults.Timeout,
+	} {
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return ProjectDefaults{}, fmt.Errorf("%s: invalid duration %q", key, value)
+		}
+		if key == "timeout" && parsed < time.Second {
+			return ProjectDefaults{}, fmt.Errorf("%s: duration %q is shorter than 1s", key, value)
+		}
+		*duration = &metav1.Duration{Duration: parsed}
+	}
+
+	if value, ok := data["retention"]; ok {
+		retention, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || retention < 1 {
+			return ProjectDefaults{}, fmt.Errorf("retention: invalid number %q", value)
+		}
+		defaults.Retention = new(int32)
+		*defaults.Retention = int32(retention)
+	}
+
+	return defaults, nil
+}
+
+// ApplyDefaults fills in the fields of a project left unset, except for
+// projects referencing a template, which provides them
+func (r *Project) ApplyDefaults(defaults ProjectDefaults) {
+	if r.Spec.TemplateRef == nil {
+		if r.Spec.Image.Name == "" && r.Spec.Nix == nil {
+			r.Spec.Image.Name = defaults.Image
+		}
+		if r.Spec.PollInterval == nil {
+			r.Spec.PollInterval = defaults.PollInterval
+		}
+		if r.Spec.Timeout == nil {
+			r.Spec.Timeout = defaults.Timeout
+		}
+		if r.Spec.Retention == nil {
+			r.Spec.Retention = defaults.Retention
+		}
+	}
+
+	if r.Spec.Repository.Ref != "" {
+		r.Spec.Repository.Ref = NormalizeRef(r.Spec.Repository.Ref)
+	}
+}
+
+// NormalizeRef qualifies short ref names: main becomes refs/heads/main and
+// tags/v1.0 becomes refs/tags/v1.0
+func NormalizeRef(ref string) string {
+	switch {
+	case strings.HasPrefix(ref, "refs/"):
+		return ref
+	case strings.HasPrefix(ref, "heads/"), strings.HasPrefix(ref, "tags/"):
+		return "refs/" + ref
+	default:
+		return "refs/heads/" + ref
+	}
+}
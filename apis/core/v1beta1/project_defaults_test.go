@@ -0,0 +1,73 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var normalizeRefTests = []struct {
+	ref        string
+	normalized string
+}{
+	{ref: "main", normalized: "refs/heads/main"},
+	{ref: "release/1.0", normalized: "refs/heads/release/1.0"},
+	{ref: "heads/main", normalized: "refs/heads/main"},
+	{ref: "tags/v1.0", normalized: "refs/tags/v1.0"},
+	{ref: "refs/pull/1/head", normalized: "refs/pull/1/head"},
+}
+
+func TestNormalizeRef(t *testing.T) {
+	for _, test := range normalizeRefTests {
+		t.Run(test.ref, func(t *testing.T) {
+			if normalized := NormalizeRef(test.ref); normalized != test.normalized {
+				t.Errorf("expected %s, got %s", test.normalized, normalized)
+			}
+		})
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	defaults, err := ParseProjectDefaults(map[string]string{
+		"image":        "golang",
+		"pollInterval": "5m",
+		"timeout":      "1h",
+		"retention":    "20",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project := Project{Spec: ProjectSpec{Repository: Repository{Ref: "main"}}}
+	project.ApplyDefaults(defaults)
+
+	if project.Spec.Image.Name != "golang" || project.Spec.Repository.Ref != "refs/heads/main" {
+		t.Errorf("unexpected image %q and ref %q", project.Spec.Image.Name, project.Spec.Repository.Ref)
+	}
+	if project.Spec.PollInterval.Duration != 5*time.Minute || project.Spec.Timeout.Duration != time.Hour || *project.Spec.Retention != 20 {
+		t.Errorf("unexpected poll interval %s, timeout %s and retention %d", project.Spec.PollInterval, project.Spec.Timeout, *project.Spec.Retention)
+	}
+
+	nix := Project{Spec: ProjectSpec{Nix: &Nix{}}}
+	nix.ApplyDefaults(defaults)
+	if nix.Spec.Image.Name != "" {
+		t.Errorf("expected Nix projects to keep the Nix image, got %q", nix.Spec.Image.Name)
+	}
+
+	templated := Project{Spec: ProjectSpec{TemplateRef: &corev1.LocalObjectReference{Name: "go"}}}
+	templated.ApplyDefaults(defaults)
+	if templated.Spec.Image.Name != "" || templated.Spec.Timeout != nil {
+		t.Errorf("expected templated projects to keep the template image and timeout, got %q and %s", templated.Spec.Image.Name, templated.Spec.Timeout)
+	}
+
+	for _, data := range []map[string]string{{"timeout": "soon"}, {"timeout": "500ms"}, {"retention": "0"}} {
+		if _, err := ParseProjectDefaults(data); err == nil {
+			t.Errorf("expected an error for %v", data)
+		}
+	}
+}
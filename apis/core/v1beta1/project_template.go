@@ -0,0 +1,197 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResolveProject returns a project with the fields it leaves unset taken
+// from its template, and the template, which is nil when the project
+// references none
+func ResolveProject(ctx context.Context, c client.Reader, project Project) (Project, *ProjectTemplate, error) {
+	if project.Spec.TemplateRef == nil {
+		return project, nil, nil
+	}
+
+	var template ProjectTemplate
+	if err := c.Get(ctx, client.ObjectKey{Name: project.Spec.TemplateRef.Name}, &template); err != nil {
+		return project, nil, err
+	}
+
+	project.Spec = template.Resolve(project.Spec)
+
+	return project, &template, nil
+}
+
+// Resolve returns spec with the fields it leaves unset taken from the
+// template
+func (r *ProjectTemplate) Resolve(spec ProjectSpec) ProjectSpec {
+	template := r.Spec.DeepCopy()
+	resolved := spec.DeepCopy()
+
+	if resolved.Image.Name == "" {
+		resolved.Image.Name = template.Image.Name
+	}
+	if resolved.Image.Entrypoint == nil {
+		resolved.Image.Entrypoint = template.Image.Entrypoint
+	}
+	if resolved.Image.Cmd == nil {
+		resolved.Image.Cmd = template.Image.Cmd
+	}
+
+	resolved.Pipeline = resolvePipeline(resolved.Pipeline, template.Pipeline)
+	if resolved.PipelinePath == "" {
+		resolved.PipelinePath = template.PipelinePath
+	}
+
+	resolved.Env = resolveEnv(template.Env, resolved.Env)
+	if template.EnvFrom != nil {
+		resolved.EnvFrom = append(template.EnvFrom, resolved.EnvFrom...)
+	}
+	resolved.SecretMounts = resolveSecretMounts(template.SecretMounts, resolved.SecretMounts)
+
+	if resolved.TrustedKeys == nil {
+		resolved.TrustedKeys = template.TrustedKeys
+	}
+
+	if resolved.PodTemplate == nil {
+		resolved.PodTemplate = template.PodTemplate
+	} else if template.PodTemplate != nil {
+		resolved.PodTemplate = resolvePodTemplate(*resolved.PodTemplate, *template.PodTemplate)
+	}
+
+	if resolved.CacheQuota == nil {
+		resolved.CacheQuota = template.CacheQuota
+	}
+	if resolved.PollInterval == nil {
+		resolved.PollInterval = template.PollInterval
+	}
+	if resolved.Timeout == nil {
+		resolved.Timeout = template.Timeout
+	}
+	if resolved.Retention == nil {
+		resolved.Retention = template.Retention
+	}
+	if resolved.Nix == nil {
+		resolved.Nix = template.Nix
+	}
+	if resolved.Provenance == nil {
+		resolved.Provenance = template.Provenance
+	}
+	if resolved.Signing == nil {
+		resolved.Signing = template.Signing
+	}
+
+	return *resolved
+}
+
+// resolvePipeline overrides the fields of the template pipeline that the
+// project pipeline sets
+func resolvePipeline(pipeline Pipeline, template Pipeline) Pipeline {
+	if pipeline.Matrix == nil {
+		pipeline.Matrix = template.Matrix
+	}
+	if pipeline.Services == nil {
+		pipeline.Services = template.Services
+	}
+	if pipeline.Caches == nil {
+		pipeline.Caches = template.Caches
+	}
+	if pipeline.Artifacts == nil {
+		pipeline.Artifacts = template.Artifacts
+	}
+	if pipeline.Reports == nil {
+		pipeline.Reports = template.Reports
+	}
+	if pipeline.Coverage == nil {
+		pipeline.Coverage = template.Coverage
+	}
+	if pipeline.Images == nil {
+		pipeline.Images = template.Images
+	}
+	if pipeline.SBOM == nil {
+		pipeline.SBOM = template.SBOM
+	}
+
+	return pipeline
+}
+
+// resolveEnv returns the template variables the project does not redefine,
+// followed by the project variables
+func resolveEnv(template []EnvVar, env []EnvVar) []EnvVar {
+	if template == nil {
+		return env
+	}
+
+	names := map[string]bool{}
+	for _, envVar := range env {
+		names[envVar.Name] = true
+	}
+
+	resolved := []EnvVar{}
+	for _, envVar := range template {
+		if !names[envVar.Name] {
+			resolved = append(resolved, envVar)
+		}
+	}
+
+	return append(resolved, env...)
+}
+
+// resolveSecretMounts returns the template mounts at paths the project does
+// not mount, followed by the project mounts
+func resolveSecretMounts(template []SecretMount, mounts []SecretMount) []SecretMount {
+	if template == nil {
+		return mounts
+	}
+
+	paths := map[string]bool{}
+	for _, mount := range mounts {
+		paths[mount.MountPath] = true
+	}
+
+	resolved := []SecretMount{}
+	for _, mount := range template {
+		if !paths[mount.MountPath] {
+			resolved = append(resolved, mount)
+		}
+	}
+
+	return append(resolved, mounts...)
+}
+
+// resolvePodTemplate overrides the fields of the template pod template that
+// the project pod template sets
+func resolvePodTemplate(podTemplate PodTemplate, template PodTemplate) *PodTemplate {
+	if podTemplate.Resources.Limits == nil && podTemplate.Resources.Requests == nil {
+		podTemplate.Resources = template.Resources
+	}
+	if podTemplate.NodeSelector == nil {
+		podTemplate.NodeSelector = template.NodeSelector
+	}
+	if podTemplate.Tolerations == nil {
+		podTemplate.Tolerations = template.Tolerations
+	}
+	if podTemplate.Affinity == nil {
+		podTemplate.Affinity = template.Affinity
+	}
+	if podTemplate.ServiceAccountName == "" {
+		podTemplate.ServiceAccountName = template.ServiceAccountName
+	}
+	if podTemplate.SecurityContext == nil {
+		podTemplate.SecurityContext = template.SecurityContext
+	}
+	if podTemplate.PriorityClassName == "" {
+		podTemplate.PriorityClassName = template.PriorityClassName
+	}
+	if podTemplate.ImagePullSecrets == nil {
+		podTemplate.ImagePullSecrets = template.ImagePullSecrets
+	}
+
+	return &podTemplate
+}
@@ -0,0 +1,110 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+)
+
+var goTemplate = ProjectTemplate{Spec: ProjectTemplateSpec{
+	Image: Image{Name: "golang", Cmd: []string{"go", "test", "./..."}},
+	Pipeline: Pipeline{
+		Caches: []Cache{{Key: "go", Paths: []string{".cache/go-build"}}},
+	},
+	Env: []EnvVar{
+		{EnvVar: corev1.EnvVar{Name: "CGO_ENABLED", Value: "0"}},
+		{EnvVar: corev1.EnvVar{Name: "GOFLAGS", Value: "-mod=readonly"}},
+	},
+	SecretMounts: []SecretMount{
+		{SecretName: "netrc", MountPath: "/hedron/netrc", Protected: true},
+		{SecretName: "ca", MountPath: "/hedron/ca"},
+	},
+	TrustedKeys: []KeySource{{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "keys"},
+		Key:                  "release.asc",
+	}}},
+	PodTemplate: &PodTemplate{NodeSelector: map[string]string{"pool": "builds"}, PriorityClassName: "builds"},
+	Timeout:     &metav1.Duration{Duration: time.Hour},
+	Provenance: &Provenance{SigningKey: corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "provenance"},
+		Key:                  "key.pem",
+	}},
+	Signing: &Signing{SecretRef: corev1.LocalObjectReference{Name: "cosign"}},
+}}
+
+var resolveTests = []struct {
+	name     string
+	spec     ProjectSpec
+	resolved ProjectSpec
+}{
+	{
+		name: "empty project",
+		spec: ProjectSpec{},
+		resolved: ProjectSpec{
+			Image:        goTemplate.Spec.Image,
+			TrustedKeys:  goTemplate.Spec.TrustedKeys,
+			Pipeline:     goTemplate.Spec.Pipeline,
+			Env:          goTemplate.Spec.Env,
+			SecretMounts: goTemplate.Spec.SecretMounts,
+			PodTemplate:  goTemplate.Spec.PodTemplate,
+			Timeout:      goTemplate.Spec.Timeout,
+			Provenance:   goTemplate.Spec.Provenance,
+			Signing:      goTemplate.Spec.Signing,
+		},
+	},
+	{
+		name: "overrides",
+		spec: ProjectSpec{
+			Image:        Image{Cmd: []string{"make"}},
+			Pipeline:     Pipeline{Artifacts: []Artifact{{Name: "bin", Paths: []string{"bin"}}}},
+			Env:          []EnvVar{{EnvVar: corev1.EnvVar{Name: "CGO_ENABLED", Value: "1"}}},
+			SecretMounts: []SecretMount{{SecretName: "release-ca", MountPath: "/hedron/ca"}},
+			PodTemplate:  &PodTemplate{PriorityClassName: "releases"},
+			Timeout:      &metav1.Duration{Duration: time.Minute},
+			Signing:      &Signing{SecretRef: corev1.LocalObjectReference{Name: "release-cosign"}},
+		},
+		resolved: ProjectSpec{
+			Image: Image{Name: "golang", Cmd: []string{"make"}},
+			Pipeline: Pipeline{
+				Caches:    goTemplate.Spec.Pipeline.Caches,
+				Artifacts: []Artifact{{Name: "bin", Paths: []string{"bin"}}},
+			},
+			Env: []EnvVar{
+				{EnvVar: corev1.EnvVar{Name: "GOFLAGS", Value: "-mod=readonly"}},
+				{EnvVar: corev1.EnvVar{Name: "CGO_ENABLED", Value: "1"}},
+			},
+			SecretMounts: []SecretMount{
+				{SecretName: "netrc", MountPath: "/hedron/netrc", Protected: true},
+				{SecretName: "release-ca", MountPath: "/hedron/ca"},
+			},
+			TrustedKeys: goTemplate.Spec.TrustedKeys,
+			PodTemplate: &PodTemplate{NodeSelector: map[string]string{"pool": "builds"}, PriorityClassName: "releases"},
+			Timeout:     &metav1.Duration{Duration: time.Minute},
+			Provenance:  goTemplate.Spec.Provenance,
+			Signing:     &Signing{SecretRef: corev1.LocalObjectReference{Name: "release-cosign"}},
+		},
+	},
+}
+
+func TestResolve(t *testing.T) {
+	for _, test := range resolveTests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved := goTemplate.Resolve(test.spec)
+			if !apiequality.Semantic.DeepEqual(resolved, test.resolved) {
+				t.Errorf("unexpected spec: %s", diff.ObjectReflectDiff(test.resolved, resolved))
+			}
+		})
+	}
+
+	if goTemplate.Spec.Env[0].Value != "0" || goTemplate.Spec.PodTemplate.PriorityClassName != "builds" {
+		t.Error("expected the template to be left unchanged")
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// projectlog is for logging in this package.
+var projectlog = logf.Log.WithName("project-resource")
+
+func (r *Project) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-hedron-build-v1beta1-project,mutating=false,failurePolicy=fail,groups=core.hedron.build,resources=projects,versions=v1beta1,name=vproject.kb.io
+
+var _ webhook.Validator = &Project{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Project) ValidateCreate() error {
+	projectlog.Info("validate create", "name", r.Name)
+
+	return r.validateProject()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Project) ValidateUpdate(old runtime.Object) error {
+	projectlog.Info("validate update", "name", r.Name)
+
+	return r.validateProject()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Project) ValidateDelete() error {
+	return nil
+}
+
+func (r *Project) validateProject() error {
+	allErrs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	// Nix builds run in the manager Nix image by default, and templates
+	// provide the image of the projects referencing them
+	if r.Spec.Image.Name == "" && r.Spec.Nix == nil && r.Spec.TemplateRef == nil {
+		allErrs = append(allErrs, field.Required(specPath.Child("image", "name"), "required unless nix or templateRef is set"))
+	}
+
+	if r.Spec.TemplateRef != nil && r.Spec.TemplateRef.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("templateRef", "name"), ""))
+	}
+
+	allErrs = append(allErrs, validateRepositoryURL(r.Spec.Repository.URL, specPath.Child("repository", "url"))...)
+	if r.Spec.Repository.Ref != "" {
+		allErrs = append(allErrs, validateRef(r.Spec.Repository.Ref, specPath.Child("repository", "ref"))...)
+	}
+
+	allErrs = append(allErrs, validatePipeline(r.Spec.Pipeline, specPath.Child("pipeline"))...)
+	if r.Spec.PipelinePath != "" {
+		allErrs = append(allErrs, validateWorkspacePath(r.Spec.PipelinePath, specPath.Child("pipelinePath"))...)
+	}
+
+	if r.Spec.PodTemplate != nil {
+		allErrs = append(allErrs, validatePodTemplate(*r.Spec.PodTemplate, specPath.Child("podTemplate"))...)
+	}
+
+	if r.Spec.Nix != nil {
+		allErrs = append(allErrs, validateNix(*r.Spec.Nix, specPath.Child("nix"))...)
+	}
+
+	allErrs = append(allErrs, validateTimeout(r.Spec.Timeout, specPath.Child("timeout"))...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Project"}, r.Name, allErrs)
+}
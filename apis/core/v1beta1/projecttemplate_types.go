@@ -0,0 +1,70 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProjectTemplateSpec holds what projects share. Projects referencing the
+// template override its fields individually.
+type ProjectTemplateSpec struct {
+	Image Image `json:"image,omitempty"`
+
+	// TrustedKeys apply to projects that list none. Like the Secrets and
+	// ConfigMaps below, they are read from the namespace of each project.
+	TrustedKeys []KeySource `json:"trustedKeys,omitempty"`
+
+	Pipeline     Pipeline `json:"pipeline,omitempty"`
+	PipelinePath string   `json:"pipelinePath,omitempty"`
+
+	// Env is merged with the env of projects, whose variables take
+	// precedence
+	Env []EnvVar `json:"env,omitempty"`
+
+	// EnvFrom sources precede the sources of projects
+	EnvFrom []EnvFromSource `json:"envFrom,omitempty"`
+
+	// SecretMounts are mounted along with the mounts of projects, which
+	// replace the template mounts at the same path
+	SecretMounts []SecretMount `json:"secretMounts,omitempty"`
+
+	PodTemplate *PodTemplate `json:"podTemplate,omitempty"`
+
+	CacheQuota   *resource.Quantity `json:"cacheQuota,omitempty"`
+	PollInterval *metav1.Duration   `json:"pollInterval,omitempty"`
+	Timeout      *metav1.Duration   `json:"timeout,omitempty"`
+
+	// +kubebuilder:validation:Minimum=1
+	Retention *int32 `json:"retention,omitempty"`
+
+	Nix        *Nix        `json:"nix,omitempty"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+	Signing    *Signing    `json:"signing,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// ProjectTemplate is a cluster-wide recipe projects build with
+type ProjectTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProjectTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+type ProjectTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProjectTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProjectTemplate{}, &ProjectTemplateList{})
+}
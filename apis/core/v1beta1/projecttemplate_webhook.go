@@ -0,0 +1,73 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// projecttemplatelog is for logging in this package.
+var projecttemplatelog = logf.Log.WithName("projecttemplate-resource")
+
+func (r *ProjectTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-hedron-build-v1beta1-projecttemplate,mutating=false,failurePolicy=fail,groups=core.hedron.build,resources=projecttemplates,versions=v1beta1,name=vprojecttemplate.kb.io
+
+var _ webhook.Validator = &ProjectTemplate{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ProjectTemplate) ValidateCreate() error {
+	projecttemplatelog.Info("validate create", "name", r.Name)
+
+	return r.validateProjectTemplate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ProjectTemplate) ValidateUpdate(old runtime.Object) error {
+	projecttemplatelog.Info("validate update", "name", r.Name)
+
+	return r.validateProjectTemplate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ProjectTemplate) ValidateDelete() error {
+	return nil
+}
+
+func (r *ProjectTemplate) validateProjectTemplate() error {
+	allErrs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	allErrs = append(allErrs, validatePipeline(r.Spec.Pipeline, specPath.Child("pipeline"))...)
+	if r.Spec.PipelinePath != "" {
+		allErrs = append(allErrs, validateWorkspacePath(r.Spec.PipelinePath, specPath.Child("pipelinePath"))...)
+	}
+
+	if r.Spec.PodTemplate != nil {
+		allErrs = append(allErrs, validatePodTemplate(*r.Spec.PodTemplate, specPath.Child("podTemplate"))...)
+	}
+
+	if r.Spec.Nix != nil {
+		allErrs = append(allErrs, validateNix(*r.Spec.Nix, specPath.Child("nix"))...)
+	}
+
+	allErrs = append(allErrs, validateTimeout(r.Spec.Timeout, specPath.Child("timeout"))...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "ProjectTemplate"}, r.Name, allErrs)
+}
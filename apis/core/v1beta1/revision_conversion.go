@@ -0,0 +1,209 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	v1 "github.com/thmzlt/hedron/apis/core/v1"
+)
+
+var _ conversion.Convertible = &Revision{}
+
+// ConvertTo converts this Revision to the Hub version (v1).
+func (src *Revision) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1.Revision)
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec = v1.RevisionSpec{
+		ProjectRef:  src.Spec.ProjectRef,
+		Commit:      src.Spec.Revision,
+		Ref:         src.Spec.Ref,
+		BuildNumber: src.Spec.BuildNumber,
+		Directives:  src.Spec.Directives,
+		PullRequest: (*v1.PullRequest)(src.Spec.PullRequest),
+		Template:    (*v1.TemplateReference)(src.Spec.Template),
+	}
+	if src.Spec.Pipeline != nil {
+		pipeline := pipelineToV1(*src.Spec.Pipeline)
+		dst.Spec.Pipeline = &pipeline
+	}
+
+	status := src.Status
+	dst.Status = v1.RevisionStatus{
+		State:    v1.State(status.State),
+		Reason:   status.Reason,
+		Coverage: (*v1.CoverageStatus)(status.Coverage),
+		Attestations: v1.Attestations{
+			Provenance: (*v1.ArtifactStatus)(status.Provenance),
+		},
+	}
+
+	if status.Cells != nil {
+		dst.Status.Cells = make([]v1.CellStatus, len(status.Cells))
+		for i, cell := range status.Cells {
+			dst.Status.Cells[i] = v1.CellStatus{
+				Name:         cell.Name,
+				Values:       cell.Values,
+				Job:          cell.Job,
+				State:        v1.State(cell.State),
+				Reason:       cell.Reason,
+				AllowFailure: cell.AllowFailure,
+			}
+		}
+	}
+
+	if tests := status.Tests; tests != nil {
+		dst.Status.Tests = &v1.TestSummary{
+			Passed:   tests.Passed,
+			Failed:   tests.Failed,
+			Skipped:  tests.Skipped,
+			Duration: tests.Duration,
+		}
+		if tests.Failures != nil {
+			dst.Status.Tests.Failures = make([]v1.TestFailure, len(tests.Failures))
+			for i, failure := range tests.Failures {
+				dst.Status.Tests.Failures[i] = v1.TestFailure(failure)
+			}
+		}
+	}
+
+	if status.Artifacts != nil {
+		dst.Status.Outputs.Artifacts = make([]v1.ArtifactStatus, len(status.Artifacts))
+		for i, artifact := range status.Artifacts {
+			dst.Status.Outputs.Artifacts[i] = v1.ArtifactStatus(artifact)
+		}
+	}
+	if status.Images != nil {
+		dst.Status.Outputs.Images = make([]v1.ImageStatus, len(status.Images))
+		for i, image := range status.Images {
+			dst.Status.Outputs.Images[i] = v1.ImageStatus(image)
+		}
+	}
+	if status.Nix != nil {
+		dst.Status.Outputs.Nix = make([]v1.NixOutput, len(status.Nix))
+		for i, output := range status.Nix {
+			dst.Status.Outputs.Nix[i] = v1.NixOutput(output)
+		}
+	}
+	if status.SBOMs != nil {
+		dst.Status.Outputs.SBOMs = make([]v1.SBOMStatus, len(status.SBOMs))
+		for i, sbom := range status.SBOMs {
+			dst.Status.Outputs.SBOMs[i] = v1.SBOMStatus(sbom)
+		}
+	}
+
+	if status.Signatures != nil {
+		dst.Status.Attestations.Signatures = make([]v1.SignatureStatus, len(status.Signatures))
+		for i, signature := range status.Signatures {
+			dst.Status.Attestations.Signatures[i] = v1.SignatureStatus{
+				Kind:      v1.SignatureKind(signature.Kind),
+				Name:      signature.Name,
+				Digest:    signature.Digest,
+				Signature: signature.Signature,
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1) to this version.
+func (dst *Revision) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1.Revision)
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec = RevisionSpec{
+		ProjectRef:  src.Spec.ProjectRef,
+		Revision:    src.Spec.Commit,
+		Ref:         src.Spec.Ref,
+		BuildNumber: src.Spec.BuildNumber,
+		Directives:  src.Spec.Directives,
+		PullRequest: (*PullRequest)(src.Spec.PullRequest),
+		Template:    (*TemplateReference)(src.Spec.Template),
+	}
+	if src.Spec.Pipeline != nil {
+		pipeline := pipelineFromV1(*src.Spec.Pipeline)
+		dst.Spec.Pipeline = &pipeline
+	}
+
+	status := src.Status
+	dst.Status = RevisionStatus{
+		State:      State(status.State),
+		Reason:     status.Reason,
+		Coverage:   (*CoverageStatus)(status.Coverage),
+		Provenance: (*ArtifactStatus)(status.Attestations.Provenance),
+	}
+
+	if status.Cells != nil {
+		dst.Status.Cells = make([]CellStatus, len(status.Cells))
+		for i, cell := range status.Cells {
+			dst.Status.Cells[i] = CellStatus{
+				Name:         cell.Name,
+				Values:       cell.Values,
+				Job:          cell.Job,
+				State:        State(cell.State),
+				Reason:       cell.Reason,
+				AllowFailure: cell.AllowFailure,
+			}
+		}
+	}
+
+	if tests := status.Tests; tests != nil {
+		dst.Status.Tests = &TestSummary{
+			Passed:   tests.Passed,
+			Failed:   tests.Failed,
+			Skipped:  tests.Skipped,
+			Duration: tests.Duration,
+		}
+		if tests.Failures != nil {
+			dst.Status.Tests.Failures = make([]TestFailure, len(tests.Failures))
+			for i, failure := range tests.Failures {
+				dst.Status.Tests.Failures[i] = TestFailure(failure)
+			}
+		}
+	}
+
+	if outputs := status.Outputs; outputs.Artifacts != nil {
+		dst.Status.Artifacts = make([]ArtifactStatus, len(outputs.Artifacts))
+		for i, artifact := range outputs.Artifacts {
+			dst.Status.Artifacts[i] = ArtifactStatus(artifact)
+		}
+	}
+	if outputs := status.Outputs; outputs.Images != nil {
+		dst.Status.Images = make([]ImageStatus, len(outputs.Images))
+		for i, image := range outputs.Images {
+			dst.Status.Images[i] = ImageStatus(image)
+		}
+	}
+	if outputs := status.Outputs; outputs.Nix != nil {
+		dst.Status.Nix = make([]NixOutput, len(outputs.Nix))
+		for i, output := range outputs.Nix {
+			dst.Status.Nix[i] = NixOutput(output)
+		}
+	}
+	if outputs := status.Outputs; outputs.SBOMs != nil {
+		dst.Status.SBOMs = make([]SBOMStatus, len(outputs.SBOMs))
+		for i, sbom := range outputs.SBOMs {
+			dst.Status.SBOMs[i] = SBOMStatus(sbom)
+		}
+	}
+
+	if signatures := status.Attestations.Signatures; signatures != nil {
+		dst.Status.Signatures = make([]SignatureStatus, len(signatures))
+		for i, signature := range signatures {
+			dst.Status.Signatures[i] = SignatureStatus{
+				Kind:      SignatureKind(signature.Kind),
+				Name:      signature.Name,
+				Digest:    signature.Digest,
+				Signature: signature.Signature,
+			}
+		}
+	}
+
+	return nil
+}
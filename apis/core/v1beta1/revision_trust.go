@@ -0,0 +1,27 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Trusted reports whether a revision may see the protected secrets of its
+// project and write to its shared caches: it must be created by the project
+// for its ref, and pull requests must come from the project repository
+func (r *Revision) Trusted(project Project) bool {
+	owner := metav1.GetControllerOf(r)
+	if owner == nil || owner.Kind != "Project" || owner.Name != project.Name || owner.UID != project.UID {
+		return false
+	}
+
+	if r.Spec.Ref == "" || r.Spec.Ref != project.Spec.Repository.Ref {
+		return false
+	}
+
+	pullRequest := r.Spec.PullRequest
+
+	return pullRequest == nil || pullRequest.SourceURL != "" && pullRequest.SourceURL == project.Spec.Repository.URL
+}
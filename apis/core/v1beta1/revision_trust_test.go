@@ -0,0 +1,88 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var revisionTrustedTests = []struct {
+	name    string
+	mutate  func(*Revision)
+	trusted bool
+}{
+	{
+		name:    "project revision",
+		mutate:  func(revision *Revision) {},
+		trusted: true,
+	},
+	{
+		name:    "no owner",
+		mutate:  func(revision *Revision) { revision.OwnerReferences = nil },
+		trusted: false,
+	},
+	{
+		name:    "other owner",
+		mutate:  func(revision *Revision) { revision.OwnerReferences[0].UID = "other-uid" },
+		trusted: false,
+	},
+	{
+		name:    "other ref",
+		mutate:  func(revision *Revision) { revision.Spec.Ref = "refs/heads/feature" },
+		trusted: false,
+	},
+	{
+		name:    "no ref",
+		mutate:  func(revision *Revision) { revision.Spec.Ref = "" },
+		trusted: false,
+	},
+	{
+		name: "pull request from the repository",
+		mutate: func(revision *Revision) {
+			revision.Spec.PullRequest = &PullRequest{Number: 1, SourceURL: "https://example.com/app.git"}
+		},
+		trusted: true,
+	},
+	{
+		name: "pull request from a fork",
+		mutate: func(revision *Revision) {
+			revision.Spec.PullRequest = &PullRequest{Number: 1, SourceURL: "https://example.com/fork.git"}
+		},
+		trusted: false,
+	},
+	{
+		name: "pull request without source",
+		mutate: func(revision *Revision) {
+			revision.Spec.PullRequest = &PullRequest{Number: 1}
+		},
+		trusted: false,
+	},
+}
+
+func TestRevisionTrusted(t *testing.T) {
+	for _, test := range revisionTrustedTests {
+		t.Run(test.name, func(t *testing.T) {
+			project := Project{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app", UID: "project-uid"},
+				Spec:       ProjectSpec{Repository: Repository{URL: "https://example.com/app.git", Ref: "refs/heads/main"}},
+			}
+			revision := Revision{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       project.Namespace,
+					Name:            "app-0123abc",
+					OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(&project, GroupVersion.WithKind("Project"))},
+				},
+				Spec: RevisionSpec{Revision: "0123abc", Ref: project.Spec.Repository.Ref},
+			}
+			test.mutate(&revision)
+
+			if trusted := revision.Trusted(project); trusted != test.trusted {
+				t.Errorf("expected %v, got %v", test.trusted, trusted)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"context"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// revisionlog is for logging in this package.
+var revisionlog = logf.Log.WithName("revision-resource")
+
+// revisionReader looks up the projects of revisions. It reads from the API
+// server, as the manager cache may not have seen a project created just
+// before its revisions.
+var revisionReader client.Reader
+
+func (r *Revision) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	revisionReader = mgr.GetAPIReader()
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-hedron-build-v1beta1-revision,mutating=false,failurePolicy=fail,groups=core.hedron.build,resources=revisions,versions=v1beta1,name=vrevision.kb.io
+
+var _ webhook.Validator = &Revision{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Revision) ValidateCreate() error {
+	revisionlog.Info("validate create", "name", r.Name)
+
+	allErrs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	if !commitPattern.MatchString(r.Spec.Revision) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("revision"), r.Spec.Revision, "must be a full commit SHA"))
+	}
+
+	projectPath := specPath.Child("projectRef", "name")
+	if r.Spec.ProjectRef.Name == "" {
+		allErrs = append(allErrs, field.Required(projectPath, ""))
+	} else if revisionReader != nil {
+		var project Project
+		err := revisionReader.Get(context.Background(), client.ObjectKey{Namespace: r.Namespace, Name: r.Spec.ProjectRef.Name}, &project)
+		if apierrors.IsNotFound(err) {
+			allErrs = append(allErrs, field.NotFound(projectPath, r.Spec.ProjectRef.Name))
+		} else if err != nil {
+			allErrs = append(allErrs, field.InternalError(projectPath, err))
+		}
+	}
+
+	if r.Spec.Pipeline != nil {
+		allErrs = append(allErrs, validatePipeline(*r.Spec.Pipeline, specPath.Child("pipeline"))...)
+	}
+
+	// The status records what the controller verified and built
+	if !apiequality.Semantic.DeepEqual(r.Status, RevisionStatus{}) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("status"), "status is set by the controller"))
+	}
+
+	return r.invalid(allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Revision) ValidateUpdate(old runtime.Object) error {
+	revisionlog.Info("validate update", "name", r.Name)
+
+	allErrs := field.ErrorList{}
+
+	// Revisions record what was built, so only their status changes
+	if oldRevision, ok := old.(*Revision); ok && !apiequality.Semantic.DeepEqual(r.Spec, oldRevision.Spec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "spec is immutable"))
+	}
+
+	return r.invalid(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Revision) ValidateDelete() error {
+	return nil
+}
+
+func (r *Revision) invalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Revision"}, r.Name, allErrs)
+}
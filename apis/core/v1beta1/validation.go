@@ -0,0 +1,413 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	// scpURLPattern matches the scp-like syntax of SSH URLs, e.g.
+	// git@github.com:thmzlt/hedron.git
+	scpURLPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^/]`)
+
+	commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+	// maxDecreasePattern matches percentage points, as the CRD does
+	maxDecreasePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+	reportFormats   = []string{"junit", "go-test-json"}
+	coverageFormats = []string{"go", "cobertura", "lcov"}
+)
+
+// validateRepositoryURL rejects URLs that cannot be cloned
+func validateRepositoryURL(repositoryURL string, fldPath *field.Path) field.ErrorList {
+	if repositoryURL == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	if scpURLPattern.MatchString(repositoryURL) {
+		return nil
+	}
+
+	parsed, err := url.Parse(repositoryURL)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, repositoryURL, err.Error())}
+	}
+
+	switch parsed.Scheme {
+	case "http", "https", "ssh", "git":
+		if parsed.Host == "" {
+			return field.ErrorList{field.Invalid(fldPath, repositoryURL, "must have a host")}
+		}
+	case "file":
+	default:
+		return field.ErrorList{field.Invalid(fldPath, repositoryURL, "must be an http, https, ssh, git or file URL")}
+	}
+
+	if strings.Trim(parsed.Path, "/") == "" {
+		return field.ErrorList{field.Invalid(fldPath, repositoryURL, "must have a path")}
+	}
+
+	return nil
+}
+
+// validateRef rejects refs that are not fully qualified, such as main
+// instead of refs/heads/main, or that git would reject
+func validateRef(ref string, fldPath *field.Path) field.ErrorList {
+	if !strings.HasPrefix(ref, "refs/") {
+		return field.ErrorList{field.Invalid(fldPath, ref, "must be fully qualified, e.g. refs/heads/main")}
+	}
+
+	invalid := strings.HasSuffix(ref, "/") || strings.HasSuffix(ref, ".lock") || strings.HasSuffix(ref, ".") ||
+		strings.Contains(ref, "..") || strings.Contains(ref, "//") || strings.Contains(ref, "@{") ||
+		strings.ContainsAny(ref, " ~^:?*[\\\x7f")
+	for _, r := range ref {
+		invalid = invalid || r < 0x20
+	}
+	for _, component := range strings.Split(ref, "/") {
+		invalid = invalid || strings.HasPrefix(component, ".")
+	}
+
+	if invalid {
+		return field.ErrorList{field.Invalid(fldPath, ref, "is not a valid ref name")}
+	}
+
+	return nil
+}
+
+// validateWorkspacePath rejects paths outside of the workspace
+func validateWorkspacePath(workspacePath string, fldPath *field.Path) field.ErrorList {
+	cleaned := path.Clean(workspacePath)
+	if workspacePath == "" || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return field.ErrorList{field.Invalid(fldPath, workspacePath, "must be relative to the workspace")}
+	}
+
+	return nil
+}
+
+// validateUniqueName rejects names already in names
+func validateUniqueName(name string, names map[string]bool, fldPath *field.Path) field.ErrorList {
+	if names[name] {
+		return field.ErrorList{field.Duplicate(fldPath, name)}
+	}
+	names[name] = true
+
+	return nil
+}
+
+// validateFormat rejects formats the manager cannot parse
+func validateFormat(format string, formats []string, fldPath *field.Path) field.ErrorList {
+	for _, supported := range formats {
+		if format == supported {
+			return field.ErrorList{}
+		}
+	}
+
+	return field.ErrorList{field.NotSupported(fldPath, format, formats)}
+}
+
+// validatePipeline rejects pipeline options that builds cannot run with
+func validatePipeline(pipeline Pipeline, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if pipeline.Matrix != nil {
+		axes := map[string]bool{}
+		for i, axis := range pipeline.Matrix.Axes {
+			axisPath := fldPath.Child("matrix", "axes").Index(i)
+
+			if axis.Name == "" {
+				allErrs = append(allErrs, field.Required(axisPath.Child("name"), ""))
+			}
+			allErrs = append(allErrs, validateUniqueName(axis.Name, axes, axisPath.Child("name"))...)
+			if len(axis.Values) == 0 {
+				allErrs = append(allErrs, field.Required(axisPath.Child("values"), ""))
+			}
+		}
+
+		// An empty combination would match every cell
+		for i, combination := range pipeline.Matrix.Exclude {
+			if len(combination) == 0 {
+				allErrs = append(allErrs, field.Required(fldPath.Child("matrix", "exclude").Index(i), ""))
+			}
+		}
+		if len(pipeline.Matrix.Exclude) > 0 && excludesEverything(*pipeline.Matrix) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("matrix", "exclude"), pipeline.Matrix.Exclude, "excludes every combination"))
+		}
+		for i, combination := range pipeline.Matrix.AllowFailures {
+			if len(combination) == 0 {
+				allErrs = append(allErrs, field.Required(fldPath.Child("matrix", "allowFailures").Index(i), ""))
+			}
+		}
+	}
+
+	services := map[string]bool{}
+	for i, service := range pipeline.Services {
+		servicePath := fldPath.Child("services").Index(i)
+
+		for _, msg := range validation.IsDNS1123Label(service.Name) {
+			allErrs = append(allErrs, field.Invalid(servicePath.Child("name"), service.Name, msg))
+		}
+		allErrs = append(allErrs, validateUniqueName(service.Name, services, servicePath.Child("name"))...)
+		if service.Image == "" {
+			allErrs = append(allErrs, field.Required(servicePath.Child("image"), ""))
+		}
+	}
+
+	for i, cache := range pipeline.Caches {
+		cachePath := fldPath.Child("caches").Index(i)
+
+		if cache.Key == "" {
+			allErrs = append(allErrs, field.Required(cachePath.Child("key"), ""))
+		}
+		if len(cache.Paths) == 0 {
+			allErrs = append(allErrs, field.Required(cachePath.Child("paths"), ""))
+		}
+		for j, workspacePath := range cache.Paths {
+			allErrs = append(allErrs, validateWorkspacePath(workspacePath, cachePath.Child("paths").Index(j))...)
+		}
+	}
+
+	artifacts := map[string]bool{}
+	for i, artifact := range pipeline.Artifacts {
+		artifactPath := fldPath.Child("artifacts").Index(i)
+
+		allErrs = append(allErrs, validateUniqueName(artifact.Name, artifacts, artifactPath.Child("name"))...)
+		if len(artifact.Paths) == 0 {
+			allErrs = append(allErrs, field.Required(artifactPath.Child("paths"), ""))
+		}
+	}
+
+	reports := map[string]bool{}
+	for i, report := range pipeline.Reports {
+		reportPath := fldPath.Child("reports").Index(i)
+
+		allErrs = append(allErrs, validateUniqueName(report.Name, reports, reportPath.Child("name"))...)
+		allErrs = append(allErrs, validateFormat(string(report.Format), reportFormats, reportPath.Child("format"))...)
+		if len(report.Paths) == 0 {
+			allErrs = append(allErrs, field.Required(reportPath.Child("paths"), ""))
+		}
+	}
+
+	if pipeline.Coverage != nil {
+		coveragePath := fldPath.Child("coverage")
+
+		allErrs = append(allErrs, validateFormat(string(pipeline.Coverage.Format), coverageFormats, coveragePath.Child("format"))...)
+		if len(pipeline.Coverage.Paths) == 0 {
+			allErrs = append(allErrs, field.Required(coveragePath.Child("paths"), ""))
+		}
+		if maxDecrease := pipeline.Coverage.MaxDecrease; maxDecrease != "" && !maxDecreasePattern.MatchString(maxDecrease) {
+			allErrs = append(allErrs, field.Invalid(coveragePath.Child("maxDecrease"), maxDecrease, "must be a number of percentage points, e.g. 0.5"))
+		}
+	}
+
+	images := map[string]bool{}
+	for i, image := range pipeline.Images {
+		imagePath := fldPath.Child("images").Index(i)
+
+		allErrs = append(allErrs, validateUniqueName(image.Name, images, imagePath.Child("name"))...)
+		if len(image.Destinations) == 0 {
+			allErrs = append(allErrs, field.Required(imagePath.Child("destinations"), ""))
+		}
+		if image.Context != "" {
+			allErrs = append(allErrs, validateWorkspacePath(image.Context, imagePath.Child("context"))...)
+		}
+		if image.Dockerfile != "" {
+			allErrs = append(allErrs, validateWorkspacePath(image.Dockerfile, imagePath.Child("dockerfile"))...)
+		}
+	}
+
+	return allErrs
+}
+
+// excludesEverything reports whether a matrix without includes excludes every
+// combination of its axes
+func excludesEverything(matrix Matrix) bool {
+	if len(matrix.Include) > 0 {
+		return false
+	}
+
+	combinations := []map[string]string{{}}
+	for _, axis := range matrix.Axes {
+		expanded := []map[string]string{}
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				values := map[string]string{axis.Name: value}
+				for key, other := range combination {
+					values[key] = other
+				}
+				expanded = append(expanded, values)
+			}
+		}
+		combinations = expanded
+	}
+	if len(combinations) == 0 {
+		return false
+	}
+
+	for _, combination := range combinations {
+		excluded := false
+		for _, pattern := range matrix.Exclude {
+			matches := true
+			for key, value := range pattern {
+				if combination[key] != value {
+					matches = false
+				}
+			}
+			excluded = excluded || matches
+		}
+		if !excluded {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validatePodTemplate rejects pod template overrides that build pods must
+// not use or that would keep them from being scheduled
+func validatePodTemplate(podTemplate PodTemplate, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for name, limit := range podTemplate.Resources.Limits {
+		if request, ok := podTemplate.Resources.Requests[name]; ok && request.Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("resources", "requests").Key(string(name)), request.String(), "must not exceed its limit"))
+		}
+	}
+
+	for key, value := range podTemplate.NodeSelector {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("nodeSelector"), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("nodeSelector").Key(key), value, msg))
+		}
+	}
+
+	for i, toleration := range podTemplate.Tolerations {
+		tolerationPath := fldPath.Child("tolerations").Index(i)
+
+		switch toleration.Operator {
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				allErrs = append(allErrs, field.Invalid(tolerationPath.Child("value"), toleration.Value, "must be empty when operator is Exists"))
+			}
+		case corev1.TolerationOpEqual, "":
+			if toleration.Key == "" {
+				allErrs = append(allErrs, field.Required(tolerationPath.Child("key"), "required when operator is Equal"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(tolerationPath.Child("operator"), toleration.Operator, []string{string(corev1.TolerationOpExists), string(corev1.TolerationOpEqual)}))
+		}
+	}
+
+	if name := podTemplate.ServiceAccountName; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("serviceAccountName"), name, msg))
+		}
+		if !allowedServiceAccount(name) {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("serviceAccountName"), name+" is not an allowed build service account"))
+		}
+	}
+
+	if securityContext := podTemplate.SecurityContext; securityContext != nil {
+		securityPath := fldPath.Child("securityContext")
+
+		// Sysctls apply to the node
+		if len(securityContext.Sysctls) > 0 {
+			allErrs = append(allErrs, field.Forbidden(securityPath.Child("sysctls"), "sysctls are not allowed"))
+		}
+		if securityContext.RunAsUser != nil && *securityContext.RunAsUser == 0 {
+			allErrs = append(allErrs, field.Forbidden(securityPath.Child("runAsUser"), "builds must not run as root"))
+		}
+		if securityContext.RunAsGroup != nil && *securityContext.RunAsGroup == 0 {
+			allErrs = append(allErrs, field.Forbidden(securityPath.Child("runAsGroup"), "builds must not run as the root group"))
+		}
+		if securityContext.RunAsNonRoot != nil && !*securityContext.RunAsNonRoot {
+			allErrs = append(allErrs, field.Forbidden(securityPath.Child("runAsNonRoot"), "must not be false"))
+		}
+		for i, group := range securityContext.SupplementalGroups {
+			if group == 0 {
+				allErrs = append(allErrs, field.Forbidden(securityPath.Child("supplementalGroups").Index(i), "builds must not run as the root group"))
+			}
+		}
+		if securityContext.SELinuxOptions != nil {
+			allErrs = append(allErrs, field.Forbidden(securityPath.Child("seLinuxOptions"), "SELinux options are not allowed"))
+		}
+	}
+
+	// System priority classes are reserved for cluster components
+	if strings.HasPrefix(podTemplate.PriorityClassName, "system-") {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("priorityClassName"), podTemplate.PriorityClassName+" is reserved"))
+	}
+
+	for i, secret := range podTemplate.ImagePullSecrets {
+		if secret.Name == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("imagePullSecrets").Index(i).Child("name"), ""))
+		}
+	}
+
+	return allErrs
+}
+
+func validateNix(nix Nix, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if nix.File != "" {
+		allErrs = append(allErrs, validateWorkspacePath(nix.File, fldPath.Child("file"))...)
+	}
+
+	// Paths substituted from the shared cache must be signed
+	if nix.CacheClaimName != "" && nix.CacheSigningKey == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child("cacheSigningKey"), "required when cacheClaimName is set"))
+	}
+	if nix.CacheClaimName != "" && nix.CachePublicKey == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("cachePublicKey"), "required when cacheClaimName is set"))
+	}
+
+	return allErrs
+}
+
+// validateTimeout rejects timeouts below a second, which jobs cannot have
+func validateTimeout(timeout *metav1.Duration, fldPath *field.Path) field.ErrorList {
+	if timeout != nil && timeout.Duration < time.Second {
+		return field.ErrorList{field.Invalid(fldPath, timeout.Duration.String(), "must be at least 1s")}
+	}
+
+	return field.ErrorList{}
+}
+
+// ValidatePipeline checks a pipeline, such as a pipeline read from a
+// repository, which the webhooks never see
+func ValidatePipeline(pipeline Pipeline) error {
+	return validatePipeline(pipeline, field.NewPath("pipeline")).ToAggregate()
+}
+
+// BuildServiceAccounts lists the service accounts pod templates may run
+// builds as, which the manager configures
+var BuildServiceAccounts []string
+
+func allowedServiceAccount(name string) bool {
+	for _, allowed := range BuildServiceAccounts {
+		if name == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidatePodTemplate checks the pod template of a project
+func ValidatePodTemplate(podTemplate PodTemplate) error {
+	return validatePodTemplate(podTemplate, field.NewPath("podTemplate")).ToAggregate()
+}
@@ -0,0 +1,333 @@
+/*
+Unlicensed
+*/
+
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var validateProjectTests = []struct {
+	name    string
+	project Project
+	valid   bool
+}{
+	{
+		name: "valid",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron", Ref: "refs/heads/master"},
+		}},
+		valid: true,
+	},
+	{
+		name: "scp-like URL",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "git@github.com:thmzlt/hedron.git"},
+		}},
+		valid: true,
+	},
+	{
+		name: "nix without image",
+		project: Project{Spec: ProjectSpec{
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Nix:        &Nix{},
+		}},
+		valid: true,
+	},
+	{
+		name: "template without image",
+		project: Project{Spec: ProjectSpec{
+			TemplateRef: &corev1.LocalObjectReference{Name: "go"},
+			Repository:  Repository{URL: "https://github.com/thmzlt/hedron"},
+		}},
+		valid: true,
+	},
+	{
+		name: "empty image",
+		project: Project{Spec: ProjectSpec{
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+		}},
+	},
+	{
+		name: "URL without host",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https:///thmzlt/hedron"},
+		}},
+	},
+	{
+		name: "URL with unsupported scheme",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "ftp://github.com/thmzlt/hedron"},
+		}},
+	},
+	{
+		name: "short ref",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron", Ref: "master"},
+		}},
+	},
+	{
+		name: "invalid ref",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron", Ref: "refs/heads/a..b"},
+		}},
+	},
+	{
+		name: "duplicate services",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Pipeline: Pipeline{Services: []Service{
+				{Name: "postgres", Image: "postgres"},
+				{Name: "postgres", Image: "postgres"},
+			}},
+		}},
+	},
+	{
+		name: "empty allowed failure",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Pipeline: Pipeline{Matrix: &Matrix{
+				Axes:          []Axis{{Name: "go", Values: []string{"1.14", "1.15"}}},
+				AllowFailures: []map[string]string{{}},
+			}},
+		}},
+	},
+	{
+		name: "cache outside of the workspace",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Pipeline:   Pipeline{Caches: []Cache{{Key: "go", Paths: []string{"../go"}}}},
+		}},
+	},
+	{
+		name: "signed nix cache",
+		project: Project{Spec: ProjectSpec{
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Nix: &Nix{
+				CacheClaimName: "nix-cache",
+				CacheSigningKey: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "nix-cache"},
+					Key:                  "secret-key",
+				},
+				CachePublicKey: "cache-1:AAAA",
+			},
+		}},
+		valid: true,
+	},
+	{
+		name: "unsigned nix cache",
+		project: Project{Spec: ProjectSpec{
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Nix:        &Nix{CacheClaimName: "nix-cache"},
+		}},
+	},
+	{
+		name: "matrix excluding every combination",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Pipeline: Pipeline{Matrix: &Matrix{
+				Axes:    []Axis{{Name: "go", Values: []string{"1.14", "1.15"}}, {Name: "os", Values: []string{"linux"}}},
+				Exclude: []map[string]string{{"go": "1.14"}, {"os": "linux", "go": "1.15"}},
+			}},
+		}},
+	},
+	{
+		name: "matrix excluding some combinations",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Pipeline: Pipeline{Matrix: &Matrix{
+				Axes:    []Axis{{Name: "go", Values: []string{"1.14", "1.15"}}},
+				Exclude: []map[string]string{{"go": "1.14"}},
+			}},
+		}},
+		valid: true,
+	},
+	{
+		name: "sub-second timeout",
+		project: Project{Spec: ProjectSpec{
+			Image:      Image{Name: "golang"},
+			Repository: Repository{URL: "https://github.com/thmzlt/hedron"},
+			Timeout:    &metav1.Duration{Duration: 500 * time.Millisecond},
+		}},
+	},
+}
+
+func TestValidateProject(t *testing.T) {
+	for _, test := range validateProjectTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.project.validateProject()
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+var validatePipelineTests = []struct {
+	name     string
+	pipeline Pipeline
+	valid    bool
+}{
+	{
+		name: "coverage",
+		pipeline: Pipeline{
+			Reports:  []Report{{Name: "unit", Format: "junit", Paths: []string{"report.xml"}}},
+			Coverage: &Coverage{Format: "go", Paths: []string{"cover.out"}, MaxDecrease: "0.5"},
+		},
+		valid: true,
+	},
+	{
+		name:     "unknown report format",
+		pipeline: Pipeline{Reports: []Report{{Name: "unit", Format: "tap", Paths: []string{"report.tap"}}}},
+	},
+	{
+		name:     "unknown coverage format",
+		pipeline: Pipeline{Coverage: &Coverage{Format: "jacoco", Paths: []string{"jacoco.xml"}}},
+	},
+	{
+		name:     "percent sign in maxDecrease",
+		pipeline: Pipeline{Coverage: &Coverage{Format: "go", Paths: []string{"cover.out"}, MaxDecrease: "0.5%"}},
+	},
+	{
+		name:     "negative maxDecrease",
+		pipeline: Pipeline{Coverage: &Coverage{Format: "go", Paths: []string{"cover.out"}, MaxDecrease: "-1"}},
+	},
+}
+
+func TestValidatePipeline(t *testing.T) {
+	for _, test := range validatePipelineTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidatePipeline(test.pipeline)
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateRevision(t *testing.T) {
+	revision := Revision{Spec: RevisionSpec{
+		ProjectRef: corev1.LocalObjectReference{Name: "hedron"},
+		Revision:   "0123456789abcdef0123456789abcdef01234567",
+	}}
+	if err := revision.ValidateCreate(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	short := revision
+	short.Spec.Revision = "0123456"
+	if err := short.ValidateCreate(); err == nil {
+		t.Error("expected an error for a short revision")
+	}
+
+	built := revision
+	built.Status.Cells = []CellStatus{{Name: "default", State: "Succeeded"}}
+	if err := built.ValidateCreate(); err == nil {
+		t.Error("expected an error for a revision created with a status")
+	}
+
+	if err := short.ValidateUpdate(&revision); err == nil {
+		t.Error("expected an error for a spec update")
+	}
+	if err := revision.ValidateUpdate(revision.DeepCopy()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func int64Pointer(value int64) *int64 {
+	return &value
+}
+
+func boolPointer(value bool) *bool {
+	return &value
+}
+
+var validatePodTemplateTests = []struct {
+	name        string
+	podTemplate PodTemplate
+	valid       bool
+}{
+	{
+		name: "valid",
+		podTemplate: PodTemplate{
+			NodeSelector:       map[string]string{"kubernetes.io/os": "linux"},
+			ServiceAccountName: "builder",
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser:    int64Pointer(1000),
+				RunAsNonRoot: boolPointer(true),
+			},
+		},
+		valid: true,
+	},
+	{
+		name:        "service account not allowed",
+		podTemplate: PodTemplate{ServiceAccountName: "default"},
+	},
+	{
+		name:        "root user",
+		podTemplate: PodTemplate{SecurityContext: &corev1.PodSecurityContext{RunAsUser: int64Pointer(0)}},
+	},
+	{
+		name:        "root group",
+		podTemplate: PodTemplate{SecurityContext: &corev1.PodSecurityContext{RunAsGroup: int64Pointer(0)}},
+	},
+	{
+		name:        "root supplemental group",
+		podTemplate: PodTemplate{SecurityContext: &corev1.PodSecurityContext{SupplementalGroups: []int64{1000, 0}}},
+	},
+	{
+		name:        "root allowed",
+		podTemplate: PodTemplate{SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: boolPointer(false)}},
+	},
+	{
+		name:        "SELinux options",
+		podTemplate: PodTemplate{SecurityContext: &corev1.PodSecurityContext{SELinuxOptions: &corev1.SELinuxOptions{Type: "spc_t"}}},
+	},
+	{
+		name:        "sysctls",
+		podTemplate: PodTemplate{SecurityContext: &corev1.PodSecurityContext{Sysctls: []corev1.Sysctl{{Name: "net.core.somaxconn", Value: "1024"}}}},
+	},
+	{
+		name:        "system priority class",
+		podTemplate: PodTemplate{PriorityClassName: "system-cluster-critical"},
+	},
+}
+
+func TestValidatePodTemplate(t *testing.T) {
+	BuildServiceAccounts = []string{"builder"}
+	defer func() { BuildServiceAccounts = nil }()
+
+	for _, test := range validatePodTemplateTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidatePodTemplate(test.podTemplate)
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Unlicensed
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/thmzlt/hedron/pkg/flaky"
+)
+
+// runFlaky prints the flakiest tests of a project
+func runFlaky(cli *cli, args []string) error {
+	flags := flag.NewFlagSet("flaky", flag.ExitOnError)
+	namespace := flags.String("n", "default", "The namespace of the project.")
+	limit := flags.Int("limit", 20, "The number of tests listed.")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("flaky requires a project")
+	}
+	name := flags.Arg(0)
+
+	body, err := cli.get("/flaky/" + *namespace + "/" + name)
+	if err != nil {
+		return err
+	}
+
+	scores := []flaky.Score{}
+	if err := json.Unmarshal(body, &scores); err != nil {
+		return err
+	}
+
+	if len(scores) == 0 {
+		fmt.Fprintf(cli.out, "Project %s has no flaky tests\n", name)
+		return nil
+	}
+	if len(scores) > *limit {
+		scores = scores[:*limit]
+	}
+
+	writer := tabwriter.NewWriter(cli.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SCORE\tFLIPS\tSAME COMMIT\tRUNS\tTEST")
+	for _, score := range scores {
+		fmt.Fprintf(writer, "%.2f\t%d\t%d\t%d\t%s\n", score.Score, score.Flips, score.SameCommitFlips, score.Runs, testName(score.Cell, score.Suite, score.Name))
+	}
+
+	return writer.Flush()
+}
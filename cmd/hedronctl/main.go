@@ -0,0 +1,117 @@
+/*
+Unlicensed
+*/
+
+// hedronctl inspects the builds of a cluster running hedron and verifies
+// what they produced.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1beta1 "github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = corev1beta1.AddToScheme(scheme)
+}
+
+// command is a subcommand of hedronctl
+type command struct {
+	usage string
+	run   func(cli *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"tests":  {usage: "tests [-n namespace] [-all] REVISION", run: runTests},
+	"flaky":  {usage: "flaky [-n namespace] [-limit count] PROJECT", run: runFlaky},
+	"verify": {usage: "verify [-n namespace] -key PUBLIC_KEY PROJECT FILE|IMAGE@DIGEST", run: runVerify},
+}
+
+// cli holds the clients and options shared by commands
+type cli struct {
+	client           client.Client
+	clientset        kubernetes.Interface
+	managerNamespace string
+	managerService   string
+
+	// out is where commands print their results
+	out io.Writer
+}
+
+func main() {
+	var managerNamespace string
+	var managerService string
+	flag.StringVar(&managerNamespace, "manager-namespace", "hedron-system", "The namespace of the manager.")
+	flag.StringVar(&managerService, "manager-service", "hedron-controller-manager", "The service of the manager.")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		fail(err)
+	}
+
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		fail(err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fail(err)
+	}
+
+	if err := command.run(&cli{
+		client:           c,
+		clientset:        clientset,
+		managerNamespace: managerNamespace,
+		managerService:   managerService,
+		out:              os.Stdout,
+	}, flag.Args()[1:]); err != nil {
+		fail(err)
+	}
+}
+
+// get fetches a path from the manager server through the API server
+func (c *cli) get(path string) ([]byte, error) {
+	return c.clientset.CoreV1().Services(c.managerNamespace).ProxyGet("http", c.managerService, "http", path, nil).DoRaw()
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: hedronctl [flags] COMMAND\n\nCommands:\n")
+	for _, name := range []string{"tests", "flaky", "verify"} {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "hedronctl:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,115 @@
+/*
+Unlicensed
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/report"
+)
+
+// runTests prints the test summary of a revision, or all its test results
+func runTests(cli *cli, args []string) error {
+	flags := flag.NewFlagSet("tests", flag.ExitOnError)
+	namespace := flags.String("n", "default", "The namespace of the revision.")
+	all := flags.Bool("all", false, "List the results of all tests.")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("tests requires a revision")
+	}
+	name := flags.Arg(0)
+
+	var revision v1beta1.Revision
+	if err := cli.client.Get(context.Background(), client.ObjectKey{Namespace: *namespace, Name: name}, &revision); err != nil {
+		return err
+	}
+
+	summary := revision.Status.Tests
+	if summary == nil {
+		fmt.Fprintf(cli.out, "Revision %s has no test results\n", name)
+		return nil
+	}
+
+	fmt.Fprintf(cli.out, "%d passed, %d failed, %d skipped in %s\n", summary.Passed, summary.Failed, summary.Skipped, summary.Duration.Duration)
+
+	if *all {
+		return printResults(cli, revision)
+	}
+
+	if len(summary.Failures) > 0 {
+		fmt.Fprintln(cli.out)
+		for _, failure := range summary.Failures {
+			fmt.Fprintf(cli.out, "FAIL %s\n", testName(failure.Cell, failure.Suite, failure.Name))
+			for _, line := range strings.Split(failure.Message, "\n") {
+				fmt.Fprintf(cli.out, "    %s\n", line)
+			}
+		}
+		if summary.Failed > int64(len(summary.Failures)) {
+			fmt.Fprintf(cli.out, "\n%d more failed tests, see -all\n", summary.Failed-int64(len(summary.Failures)))
+		}
+	}
+
+	return nil
+}
+
+// printResults lists the per-test results stored by the manager
+func printResults(cli *cli, revision v1beta1.Revision) error {
+	body, err := cli.get("/tests/" + revision.Namespace + "/" + revision.Name)
+	if err != nil {
+		return err
+	}
+
+	jobs := map[string][]report.Result{}
+	if err := json.Unmarshal(body, &jobs); err != nil {
+		return err
+	}
+
+	// Name results after cells rather than jobs
+	cells := map[string]string{}
+	for _, cell := range revision.Status.Cells {
+		cells[cell.Job] = cell.Name
+	}
+
+	names := []string{}
+	for job := range jobs {
+		names = append(names, job)
+	}
+	sort.Strings(names)
+
+	writer := tabwriter.NewWriter(cli.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "STATUS\tTEST\tDURATION")
+	for _, job := range names {
+		for _, result := range jobs[job] {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", strings.ToUpper(string(result.Status)), testName(cells[job], result.Suite, result.Name), result.Duration)
+		}
+	}
+
+	return writer.Flush()
+}
+
+// testName names a test after its cell, unless it is the single build of
+// its revision, and its suite
+func testName(cell string, suite string, name string) string {
+	parts := []string{}
+	if cell != "" && cell != "build" {
+		parts = append(parts, cell)
+	}
+	if suite != "" && suite != name {
+		parts = append(parts, suite)
+	}
+
+	return strings.Join(append(parts, name), " ")
+}
@@ -0,0 +1,126 @@
+/*
+Unlicensed
+*/
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// proxyResponse is the body of a response proxied to the manager
+type proxyResponse []byte
+
+func (r proxyResponse) DoRaw() ([]byte, error) {
+	return r, nil
+}
+
+func (r proxyResponse) Stream() (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(r)), nil
+}
+
+var runTestsTests = []struct {
+	name    string
+	args    []string
+	status  v1beta1.RevisionStatus
+	results string
+	output  string
+}{
+	{
+		name:   "no results",
+		args:   []string{"app-0123abc"},
+		output: "Revision app-0123abc has no test results\n",
+	},
+	{
+		name: "failures",
+		args: []string{"app-0123abc"},
+		status: v1beta1.RevisionStatus{Tests: &v1beta1.TestSummary{
+			Passed:   3,
+			Failed:   2,
+			Duration: metav1.Duration{Duration: 2 * time.Second},
+			Failures: []v1beta1.TestFailure{{Cell: "go=1.15", Suite: "api", Name: "TestCreate", Message: "expected 204\ngot 500"}},
+		}},
+		output: `3 passed, 2 failed, 0 skipped in 2s
+
+FAIL go=1.15 api TestCreate
+    expected 204
+    got 500
+
+1 more failed tests, see -all
+`,
+	},
+	{
+		name: "all results",
+		args: []string{"-all", "app-0123abc"},
+		status: v1beta1.RevisionStatus{
+			Cells: []v1beta1.CellStatus{{Name: "build", Job: "app-0123abc-build"}},
+			Tests: &v1beta1.TestSummary{Passed: 1, Failed: 1, Duration: metav1.Duration{Duration: time.Second}},
+		},
+		results: `{"app-0123abc-build":[
+			{"suite":"api","name":"TestCreate","status":"passed","duration":500000000},
+			{"suite":"TestDelete","name":"TestDelete","status":"failed","duration":500000000}
+		]}`,
+		output: `1 passed, 1 failed, 0 skipped in 1s
+
+STATUS  TEST            DURATION
+PASSED  api TestCreate  500ms
+FAILED  TestDelete      500ms
+`,
+	},
+}
+
+func TestRunTests(t *testing.T) {
+	for _, test := range runTestsTests {
+		t.Run(test.name, func(t *testing.T) {
+			revision := &v1beta1.Revision{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-0123abc"},
+				Status:     test.status,
+			}
+
+			clientset := kubefake.NewSimpleClientset()
+			clientset.AddProxyReactor("services", func(action clienttesting.Action) (bool, restclient.ResponseWrapper, error) {
+				return true, proxyResponse(test.results), nil
+			})
+
+			var out bytes.Buffer
+			if err := runTests(&cli{client: fake.NewFakeClientWithScheme(scheme, revision), clientset: clientset, out: &out}, test.args); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.output {
+				t.Errorf("expected %q, got %q", test.output, out.String())
+			}
+		})
+	}
+}
+
+var testNameTests = []struct {
+	cell     string
+	suite    string
+	name     string
+	expected string
+}{
+	{cell: "build", suite: "api", name: "TestCreate", expected: "api TestCreate"},
+	{cell: "go=1.15", suite: "api", name: "TestCreate", expected: "go=1.15 api TestCreate"},
+	{cell: "go=1.15", suite: "TestCreate", name: "TestCreate", expected: "go=1.15 TestCreate"},
+	{name: "TestCreate", expected: "TestCreate"},
+}
+
+func TestTestName(t *testing.T) {
+	for _, test := range testNameTests {
+		if name := testName(test.cell, test.suite, test.name); name != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, name)
+		}
+	}
+}
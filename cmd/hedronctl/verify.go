@@ -0,0 +1,124 @@
+/*
+Unlicensed
+*/
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/signing"
+)
+
+// runVerify checks that a file or an image was signed by a successful
+// revision of a project
+func runVerify(cli *cli, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	namespace := flags.String("n", "default", "The namespace of the project.")
+	keyFile := flags.String("key", "", "The cosign public key of the project.")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 || *keyFile == "" {
+		return fmt.Errorf("verify requires a key, a project and a file or image")
+	}
+	project, subject := flags.Arg(0), flags.Arg(1)
+
+	keyData, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	publicKey, err := signing.ParsePublicKey(keyData)
+	if err != nil {
+		return err
+	}
+
+	kind, repository, digest, err := verifySubject(subject)
+	if err != nil {
+		return err
+	}
+
+	var revisions v1beta1.RevisionList
+	if err := cli.client.List(context.Background(), &revisions, client.InNamespace(*namespace)); err != nil {
+		return err
+	}
+
+	for _, revision := range revisions.Items {
+		if revision.Spec.ProjectRef.Name != project || revision.Status.State != "Succeeded" {
+			continue
+		}
+
+		for _, signature := range revision.Status.Signatures {
+			if signature.Kind != kind || signature.Digest != digest || (kind == v1beta1.ImageSignature && signature.Name != repository) {
+				continue
+			}
+
+			if err := verifySignature(publicKey, signature); err != nil {
+				continue
+			}
+
+			fmt.Fprintf(cli.out, "Verified %s: %s of revision %s, commit %s\n", subject, signature.Name, revision.Name, revision.Spec.Revision)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is not signed by a successful revision of project %s", subject, project)
+}
+
+// verifySubject returns the kind and digest of what is verified: images are
+// referenced by digest, e.g. registry.example.com/app@sha256:..., and files
+// are hashed
+func verifySubject(subject string) (v1beta1.SignatureKind, string, string, error) {
+	if at := strings.Index(subject, "@sha256:"); at > 0 {
+		return v1beta1.ImageSignature, subject[:at], subject[at+1:], nil
+	}
+
+	file, err := os.Open(subject)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", "", "", err
+	}
+
+	return v1beta1.ArtifactSignature, "", "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifySignature checks a signature of a revision, which is made over the
+// digest of artifacts and over the cosign payload of images
+func verifySignature(publicKey crypto.PublicKey, signature v1beta1.SignatureStatus) error {
+	decoded, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return err
+	}
+
+	var digest []byte
+	if signature.Kind == v1beta1.ImageSignature {
+		payload, err := signing.ImagePayload(signature.Name, signature.Digest)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(payload)
+		digest = sum[:]
+	} else if digest, err = hex.DecodeString(strings.TrimPrefix(signature.Digest, "sha256:")); err != nil {
+		return err
+	}
+
+	return signing.VerifyDigest(publicKey, digest, decoded)
+}
@@ -0,0 +1,151 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/mirror"
+	"github.com/thmzlt/hedron/pkg/server"
+)
+
+const (
+	cacheRestoreContainer = "cache-restore"
+	cacheSaveContainer    = "cache-save"
+)
+
+// renderCaches expands the keys of caches and checks their paths
+func (r *RevisionReconciler) renderCaches(ctx context.Context, caches []v1beta1.Cache) ([]v1beta1.Cache, error) {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	var remote *mirror.Remote
+	var readErr error
+
+	data := newTemplateData(project, revision, cell)
+	data.readFile = func(filePath string) ([]byte, error) {
+		if remote == nil {
+			fetched, err := fetchRemote(ctx, r.Client, project)
+			if err != nil {
+				readErr = err
+				return nil, err
+			}
+			remote = &fetched
+		}
+
+		contents, err := r.Mirrors.ReadFile(ctx, *remote, revision.Spec.Revision, filePath)
+		if err == object.ErrFileNotFound {
+			return nil, nil
+		} else if err != nil {
+			readErr = err
+		}
+
+		return contents, err
+	}
+
+	rendered := make([]v1beta1.Cache, len(caches))
+	for i, cache := range caches {
+		key, err := renderTemplate(cache.Key, data)
+		if readErr != nil {
+			return nil, readErr
+		} else if err != nil {
+			return nil, invalidSpec("Invalid cache: %s", err)
+		}
+		if _, err := server.CacheKey(project.Namespace, project.Name, key); err != nil {
+			return nil, invalidSpec("Invalid cache: %s", err)
+		}
+
+		for _, cachePath := range cache.Paths {
+			if err := checkWorkspacePath(cachePath); err != nil {
+				return nil, invalidSpec("Invalid cache: %s", err)
+			}
+		}
+
+		rendered[i] = v1beta1.Cache{Key: key, Paths: cache.Paths}
+	}
+
+	return rendered, nil
+}
+
+// cacheEnv points cache containers to the cache endpoint of their revision
+func (r *RevisionReconciler) cacheEnv(revision v1beta1.Revision) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: "HOME", Value: "/tmp"},
+		{Name: "HEDRON_CACHE_URL", Value: strings.TrimSuffix(r.ServerURL, "/") + "/caches/" + revision.Namespace + "/" + revision.Name},
+		{Name: "HEDRON_TOKEN", Value: r.Tokens.Sign(revision.Namespace, revision.Name)},
+	}
+}
+
+// cacheRestoreContainer returns the init container that restores caches into
+// the workspace after the checkout. Missing entries are not an error.
+func (r *RevisionReconciler) cacheRestoreContainer(revision v1beta1.Revision, caches []v1beta1.Cache) corev1.Container {
+	script := []string{"set -u"}
+	for _, cache := range caches {
+		key := shellQuote(cache.Key)
+
+		script = append(script, fmt.Sprintf(
+			`if curl -fsS -H "Authorization: Bearer $HEDRON_TOKEN" -o /tmp/cache.tar.gz "$HEDRON_CACHE_URL/"%s && tar -xzf /tmp/cache.tar.gz; then echo "Restored cache "%s; else echo "Cache "%s" not restored"; fi`,
+			key, key, key,
+		))
+		script = append(script, "rm -f /tmp/cache.tar.gz")
+	}
+
+	return corev1.Container{
+		Name:       cacheRestoreContainer,
+		Image:      r.RunnerImage,
+		Command:    []string{"/bin/sh", "-c", strings.Join(script, "\n")},
+		WorkingDir: workspacePath,
+		Env:        r.cacheEnv(revision),
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workspaceVolume, MountPath: workspacePath},
+		},
+	}
+}
+
+// cacheSaveContainer returns the container that saves caches once the build
+// container succeeds. Existing entries are kept as they are, and failing to
+// save a cache does not fail the build.
+func (r *RevisionReconciler) cacheSaveContainer(revision v1beta1.Revision, caches []v1beta1.Cache) corev1.Container {
+	script := []string{
+		"set -u",
+		waitForBuildScript,
+		fmt.Sprintf(`grep -qs '^%s="0"$' %s/annotations || exit 0`, buildExitCodeAnnotation, podInfoPath),
+	}
+	for _, cache := range caches {
+		key := shellQuote(cache.Key)
+
+		paths := make([]string, len(cache.Paths))
+		for i, cachePath := range cache.Paths {
+			paths[i] = shellQuote(cachePath)
+		}
+
+		script = append(script, fmt.Sprintf(
+			`if curl -fsS -I -H "Authorization: Bearer $HEDRON_TOKEN" "$HEDRON_CACHE_URL/"%s > /dev/null 2>&1; then echo "Cache "%s" is up to date"; `+
+				`elif tar -czf /tmp/cache.tar.gz -- %s && curl -fsS -T /tmp/cache.tar.gz -H "Authorization: Bearer $HEDRON_TOKEN" "$HEDRON_CACHE_URL/"%s; then echo "Saved cache "%s; `+
+				`else echo "Cache "%s" not saved"; fi`,
+			key, key, strings.Join(paths, " "), key, key, key,
+		))
+		script = append(script, "rm -f /tmp/cache.tar.gz")
+	}
+
+	return corev1.Container{
+		Name:       cacheSaveContainer,
+		Image:      r.RunnerImage,
+		Command:    []string{"/bin/sh", "-c", strings.Join(script, "\n")},
+		WorkingDir: workspacePath,
+		Env:        r.cacheEnv(revision),
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workspaceVolume, MountPath: workspacePath, ReadOnly: true},
+			{Name: podInfoVolume, MountPath: podInfoPath, ReadOnly: true},
+		},
+	}
+}
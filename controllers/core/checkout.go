@@ -0,0 +1,164 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+const (
+	workspaceVolume = "workspace"
+	workspacePath   = "/workspace"
+
+	sshVolume = "ssh"
+	sshPath   = "/etc/hedron/ssh"
+)
+
+// checkoutContainer returns the init container that checks out the revision
+// into the workspace volume shared with the build containers.
+func checkoutContainer(project v1beta1.Project, revision v1beta1.Revision, image string) corev1.Container {
+	repository := project.Spec.Repository
+
+	container := corev1.Container{
+		Name:       "checkout",
+		Image:      image,
+		Command:    []string{"/bin/sh", "-c", checkoutScript(repository)},
+		WorkingDir: workspacePath,
+		Env: []corev1.EnvVar{
+			{Name: "HOME", Value: "/tmp"},
+			{Name: "HEDRON_REPOSITORY_URL", Value: repository.URL},
+			{Name: "HEDRON_COMMIT_SHA", Value: revision.Spec.Revision},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workspaceVolume, MountPath: workspacePath},
+		},
+	}
+
+	if repository.SecretRef == nil || !revision.Trusted(project) {
+		return container
+	}
+
+	if isSSHURL(repository.URL) {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "GIT_SSH_COMMAND",
+			Value: "ssh -i " + sshPath + "/" + corev1.SSHAuthPrivateKey + " -o UserKnownHostsFile=" + sshPath + "/known_hosts -o StrictHostKeyChecking=yes",
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      sshVolume,
+			MountPath: sshPath,
+			ReadOnly:  true,
+		})
+
+		return container
+	}
+
+	container.Env = append(container.Env,
+		corev1.EnvVar{
+			Name: "GIT_USERNAME",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: *repository.SecretRef,
+				Key:                  corev1.BasicAuthUsernameKey,
+			}},
+		},
+		corev1.EnvVar{
+			Name: "GIT_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: *repository.SecretRef,
+				Key:                  corev1.BasicAuthPasswordKey,
+			}},
+		},
+	)
+
+	return container
+}
+
+// checkoutVolumes returns the volumes needed by the checkout container
+func checkoutVolumes(project v1beta1.Project, revision v1beta1.Revision) []corev1.Volume {
+	repository := project.Spec.Repository
+	volumes := []corev1.Volume{
+		{
+			Name:         workspaceVolume,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
+	}
+
+	if repository.SecretRef != nil && isSSHURL(repository.URL) && revision.Trusted(project) {
+		mode := int32(0400)
+		volumes = append(volumes, corev1.Volume{
+			Name: sshVolume,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  repository.SecretRef.Name,
+				DefaultMode: &mode,
+			}},
+		})
+	}
+
+	return volumes
+}
+
+// checkoutScript clones HEDRON_COMMIT_SHA of HEDRON_REPOSITORY_URL into the
+// working directory. Credentials are configured globally so that submodules
+// and LFS objects are fetched with the same ones as the main repository.
+func checkoutScript(repository v1beta1.Repository) string {
+	script := []string{
+		"set -eu",
+		`if [ -n "${GIT_USERNAME:-}" ]; then git config --global credential.helper '!f() { echo "username=${GIT_USERNAME}"; echo "password=${GIT_PASSWORD}"; }; f'; fi`,
+	}
+
+	if repository.LFS {
+		script = append(script, "git lfs install --skip-repo")
+	}
+
+	fetch := "git fetch -q"
+	if repository.Depth > 0 {
+		fetch = fmt.Sprintf("%s --depth %d", fetch, repository.Depth)
+	}
+
+	script = append(script,
+		"git init -q .",
+		`git remote add origin "$HEDRON_REPOSITORY_URL"`,
+	)
+
+	if len(repository.SparsePaths) > 0 {
+		paths := make([]string, len(repository.SparsePaths))
+		for i, path := range repository.SparsePaths {
+			paths[i] = shellQuote(path)
+		}
+
+		script = append(script,
+			"git sparse-checkout init --cone",
+			"git sparse-checkout set "+strings.Join(paths, " "),
+		)
+	}
+
+	script = append(script,
+		// Servers refusing to fetch unadvertised commits get a full fetch,
+		// which a shallow one could miss older commits with
+		fetch+` origin "$HEDRON_COMMIT_SHA" || git fetch -q origin '+refs/heads/*:refs/remotes/origin/*' '+refs/tags/*:refs/tags/*'`,
+		`git checkout -q --detach "$HEDRON_COMMIT_SHA"`,
+	)
+
+	if repository.Depth > 0 && repository.History {
+		script = append(script, "if [ -f .git/shallow ]; then git fetch -q --unshallow --tags origin; fi")
+	}
+
+	switch repository.Submodules {
+	case "shallow":
+		script = append(script, "git submodule update -q --init")
+	case "recursive":
+		script = append(script, "git submodule update -q --init --recursive")
+	}
+
+	return strings.Join(script, "\n")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -7,7 +7,9 @@ package controllers
 type contextKey string
 
 var (
-	contextKeyRequest  = contextKey("Request")
-	contextKeyProject  = contextKey("Project")
-	contextKeyRevision = contextKey("Revision")
+	contextKeyRequest         = contextKey("Request")
+	contextKeyProject         = contextKey("Project")
+	contextKeyProjectTemplate = contextKey("ProjectTemplate")
+	contextKeyRevision        = contextKey("Revision")
+	contextKeyCell            = contextKey("Cell")
 )
@@ -0,0 +1,130 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/coverage"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// reconcileCoverage returns the coverage of a finished revision, merged from
+// the coverage reports of its cells, along with a reason to fail the revision
+// when coverage decreased by more than allowed
+func (r *RevisionReconciler) reconcileCoverage(ctx context.Context, settings v1beta1.Coverage) (*v1beta1.CoverageStatus, string, error) {
+	if r.Store == nil {
+		return nil, "", nil
+	}
+
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	profile := coverage.NewProfile()
+	for _, cell := range revision.Status.Cells {
+		if cell.Job == "" {
+			continue
+		}
+
+		prefix, err := storage.Key(revision.Namespace, revision.Name, "coverage", cell.Job)
+		if err != nil {
+			return nil, "", err
+		}
+
+		objects, err := r.Store.List(ctx, prefix+"/")
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, object := range objects {
+			reader, err := r.Store.Get(ctx, object.Key)
+			if err != nil {
+				return nil, "", err
+			}
+
+			err = profile.Parse(coverage.Format(settings.Format), reader)
+			reader.Close()
+			if err != nil {
+				r.Log.Info("Ignoring coverage report", "key", object.Key, "error", err.Error())
+			}
+		}
+	}
+
+	covered, total := profile.Totals()
+	if total == 0 {
+		return nil, "", nil
+	}
+
+	percentage := coverage.Percentage(covered, total)
+	status := &v1beta1.CoverageStatus{
+		Covered:    covered,
+		Total:      total,
+		Percentage: fmt.Sprintf("%.2f", percentage),
+	}
+
+	base, err := r.fetchBaseRevision(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if base == nil {
+		return status, "", nil
+	}
+
+	delta := percentage - coverage.Percentage(base.Status.Coverage.Covered, base.Status.Coverage.Total)
+	status.BaseRevision = base.Name
+	status.Delta = fmt.Sprintf("%+.2f", delta)
+
+	if settings.MaxDecrease == "" {
+		return status, "", nil
+	}
+
+	// Retrying cannot fix a bad setting, so it fails the revision
+	maxDecrease, err := strconv.ParseFloat(settings.MaxDecrease, 64)
+	if err != nil {
+		return status, fmt.Sprintf("Invalid coverage maxDecrease %q", settings.MaxDecrease), nil
+	}
+	if -delta > maxDecrease {
+		return status, fmt.Sprintf("Coverage decreased by %.2f points from %s", -delta, base.Name), nil
+	}
+
+	return status, "", nil
+}
+
+// fetchBaseRevision returns the last successful revision with coverage of
+// the branch tracked by the project, if any
+func (r *RevisionReconciler) fetchBaseRevision(ctx context.Context) (*v1beta1.Revision, error) {
+	var revisions v1beta1.RevisionList
+
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	if err := r.List(ctx, &revisions, client.InNamespace(revision.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var base *v1beta1.Revision
+	for i := range revisions.Items {
+		candidate := &revisions.Items[i]
+
+		if candidate.Name == revision.Name || candidate.Spec.ProjectRef.Name != project.Name {
+			continue
+		}
+		if candidate.Spec.PullRequest != nil || candidate.Spec.Ref != project.Spec.Repository.Ref {
+			continue
+		}
+		if candidate.Status.State != "Succeeded" || candidate.Status.Coverage == nil {
+			continue
+		}
+
+		if base == nil || candidate.Spec.BuildNumber > base.Spec.BuildNumber {
+			base = candidate
+		}
+	}
+	return base, nil
+}
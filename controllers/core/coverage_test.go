@@ -0,0 +1,195 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/coverage"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// testProfile covers 8 of 10 statements
+const testProfile = `mode: set
+example.com/app/main.go:1.1,2.2 8 1
+example.com/app/main.go:3.1,4.2 2 0
+`
+
+// testBaseRevision returns a successful revision of the tracked branch built
+// before the test revision, with 85% coverage
+func testBaseRevision(project v1beta1.Project, mutate func(*v1beta1.Revision)) *v1beta1.Revision {
+	base := testRevision(project)
+	base.Name = project.Name + "-4567def"
+	base.Spec.Revision = "4567def"
+	base.Spec.BuildNumber = 6
+	base.Status = v1beta1.RevisionStatus{
+		State:    "Succeeded",
+		Coverage: &v1beta1.CoverageStatus{Covered: 85, Total: 100, Percentage: "85.00"},
+	}
+	mutate(&base)
+
+	return &base
+}
+
+var reconcileCoverageTests = []struct {
+	name        string
+	maxDecrease string
+	base        func(*v1beta1.Revision)
+	status      *v1beta1.CoverageStatus
+	reason      string
+}{
+	{
+		name:   "no base revision",
+		status: &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00"},
+	},
+	{
+		name:   "base without coverage",
+		base:   func(base *v1beta1.Revision) { base.Status.Coverage = nil },
+		status: &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00"},
+	},
+	{
+		name:   "failed base",
+		base:   func(base *v1beta1.Revision) { base.Status.State = "Failed" },
+		status: &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00"},
+	},
+	{
+		name:   "base of another branch",
+		base:   func(base *v1beta1.Revision) { base.Spec.Ref = "refs/heads/feature" },
+		status: &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00"},
+	},
+	{
+		name:   "decrease without maxDecrease",
+		base:   func(base *v1beta1.Revision) {},
+		status: &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00", BaseRevision: "app-4567def", Delta: "-5.00"},
+	},
+	{
+		name:        "decrease below maxDecrease",
+		maxDecrease: "10",
+		base:        func(base *v1beta1.Revision) {},
+		status:      &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00", BaseRevision: "app-4567def", Delta: "-5.00"},
+	},
+	{
+		name:        "decrease of exactly maxDecrease",
+		maxDecrease: "5",
+		base:        func(base *v1beta1.Revision) {},
+		status:      &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00", BaseRevision: "app-4567def", Delta: "-5.00"},
+	},
+	{
+		name:        "decrease above maxDecrease",
+		maxDecrease: "1.5",
+		base:        func(base *v1beta1.Revision) {},
+		status:      &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00", BaseRevision: "app-4567def", Delta: "-5.00"},
+		reason:      "Coverage decreased by 5.00 points from app-4567def",
+	},
+	{
+		name:        "increase",
+		maxDecrease: "0",
+		base: func(base *v1beta1.Revision) {
+			base.Status.Coverage = &v1beta1.CoverageStatus{Covered: 1, Total: 2, Percentage: "50.00"}
+		},
+		status: &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00", BaseRevision: "app-4567def", Delta: "+30.00"},
+	},
+	{
+		name:        "invalid maxDecrease",
+		maxDecrease: "5%",
+		base:        func(base *v1beta1.Revision) {},
+		status:      &v1beta1.CoverageStatus{Covered: 8, Total: 10, Percentage: "80.00", BaseRevision: "app-4567def", Delta: "-5.00"},
+		reason:      `Invalid coverage maxDecrease "5%"`,
+	},
+}
+
+func TestReconcileCoverage(t *testing.T) {
+	for _, test := range reconcileCoverageTests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "hedron-storage-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			project := testProject()
+			revision := testRevision(project)
+			revision.Status.Cells = []v1beta1.CellStatus{{Name: "build", Job: revision.Name + "-build", State: "Succeeded"}}
+
+			store := &storage.FileStore{Dir: dir}
+			key, err := coverage.Key(revision.Namespace, revision.Name, revision.Status.Cells[0].Job, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Put(context.Background(), key, strings.NewReader(testProfile)); err != nil {
+				t.Fatal(err)
+			}
+
+			c := fake.NewFakeClientWithScheme(testScheme(t), &revision)
+			if test.base != nil {
+				if err := c.Create(context.Background(), testBaseRevision(project, test.base)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reconciler := RevisionReconciler{Client: c, Log: logf.NullLogger{}, Store: store}
+
+			ctx := context.WithValue(context.Background(), contextKeyProject, project)
+			ctx = context.WithValue(ctx, contextKeyRevision, revision)
+
+			status, reason, err := reconciler.reconcileCoverage(ctx, v1beta1.Coverage{Format: "go", Paths: []string{"cover.out"}, MaxDecrease: test.maxDecrease})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(status, test.status) {
+				t.Errorf("expected %v, got %v", test.status, status)
+			}
+			if reason != test.reason {
+				t.Errorf("expected the reason %q, got %q", test.reason, reason)
+			}
+		})
+	}
+}
+
+func TestFetchBaseRevision(t *testing.T) {
+	project := testProject()
+	revision := testRevision(project)
+
+	older := testBaseRevision(project, func(base *v1beta1.Revision) {})
+	newer := testBaseRevision(project, func(base *v1beta1.Revision) {
+		base.Name = project.Name + "-89abcde"
+		base.Spec.BuildNumber = 8
+	})
+	pullRequest := testBaseRevision(project, func(base *v1beta1.Revision) {
+		base.Name = project.Name + "-pr-1"
+		base.Spec.BuildNumber = 9
+		base.Spec.PullRequest = &v1beta1.PullRequest{Number: 1}
+	})
+	otherProject := testBaseRevision(project, func(base *v1beta1.Revision) {
+		base.Name = "other-0123abc"
+		base.Spec.ProjectRef.Name = "other"
+		base.Spec.BuildNumber = 10
+	})
+
+	reconciler := RevisionReconciler{
+		Client: fake.NewFakeClientWithScheme(testScheme(t), &revision, older, newer, pullRequest, otherProject),
+		Log:    logf.NullLogger{},
+	}
+
+	ctx := context.WithValue(context.Background(), contextKeyProject, project)
+	ctx = context.WithValue(ctx, contextKeyRevision, revision)
+
+	base, err := reconciler.fetchBaseRevision(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base == nil || base.Name != newer.Name {
+		t.Errorf("expected the base revision %s, got %v", newer.Name, base)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/mirror"
+)
+
+// isSSHURL reports whether a repository URL uses the SSH transport
+func isSSHURL(url string) bool {
+	if strings.HasPrefix(url, "ssh://") {
+		return true
+	}
+
+	// scp-like syntax, e.g. git@github.com:org/repo.git
+	return !strings.Contains(url, "://") && strings.Contains(url, ":")
+}
+
+// fetchRemote describes how the controller reads the repository of a project
+func fetchRemote(ctx context.Context, c client.Client, project v1beta1.Project) (mirror.Remote, error) {
+	repository := project.Spec.Repository
+
+	remote := mirror.Remote{
+		URL: repository.URL,
+		LFS: repository.LFS,
+	}
+
+	switch repository.Submodules {
+	case "shallow":
+		remote.SubmoduleDepth = 1
+	case "recursive":
+		remote.SubmoduleDepth = -1
+	}
+
+	if repository.SecretRef == nil {
+		return remote, nil
+	}
+
+	var secret corev1.Secret
+	if err := c.Get(ctx, client.ObjectKey{Namespace: project.Namespace, Name: repository.SecretRef.Name}, &secret); err != nil {
+		return remote, err
+	}
+
+	auth, err := secretAuth(repository.URL, secret)
+	if err != nil {
+		return remote, fmt.Errorf("secret %s: %w", secret.Name, err)
+	}
+	remote.Auth = auth
+
+	return remote, nil
+}
+
+func secretAuth(url string, secret corev1.Secret) (transport.AuthMethod, error) {
+	if !isSSHURL(url) {
+		return &githttp.BasicAuth{
+			Username: string(secret.Data[corev1.BasicAuthUsernameKey]),
+			Password: string(secret.Data[corev1.BasicAuthPasswordKey]),
+		}, nil
+	}
+
+	auth, err := gitssh.NewPublicKeys("git", secret.Data[corev1.SSHAuthPrivateKey], "")
+	if err != nil {
+		return nil, err
+	}
+
+	knownHosts, ok := secret.Data["known_hosts"]
+	if !ok {
+		return nil, fmt.Errorf("known_hosts is required for SSH repositories")
+	}
+
+	file, err := ioutil.TempFile("", "hedron-known-hosts-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(knownHosts)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	auth.HostKeyCallback, err = knownhosts.New(file.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
@@ -0,0 +1,87 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// skipMarkers are the commit message markers that prevent a build
+var skipMarkers = []string{"[skip ci]", "[ci skip]", "[no ci]", "[skip hedron]", "[hedron skip]"}
+
+// directivePrefix starts a Hedron directive line, e.g. "hedron: stage=lint"
+const directivePrefix = "hedron:"
+
+// parseDirectives returns the directives of the "hedron:" lines of a commit
+// message, and the reason for skipping the commit if it asks not to be built
+func parseDirectives(message string) (map[string]string, string) {
+	directives := map[string]string{}
+	skipReason := ""
+
+	lowerMessage := strings.ToLower(message)
+	for _, marker := range skipMarkers {
+		if strings.Contains(lowerMessage, marker) {
+			skipReason = fmt.Sprintf("Commit message contains %s", marker)
+			break
+		}
+	}
+
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(strings.ToLower(line), directivePrefix) {
+			continue
+		}
+
+		for _, field := range strings.Fields(line[len(directivePrefix):]) {
+			key, value := field, "true"
+			if i := strings.Index(field, "="); i >= 0 {
+				key, value = field[:i], field[i+1:]
+			}
+			key = strings.ToLower(key)
+			if key == "" {
+				continue
+			}
+
+			directives[key] = value
+		}
+	}
+
+	if skipReason == "" && directives["skip"] == "true" {
+		skipReason = "Commit message contains hedron: skip"
+	}
+	if len(directives) == 0 {
+		directives = nil
+	}
+
+	return directives, skipReason
+}
+
+// selectCells keeps the cells that agree with the directives naming one of
+// their values
+func selectCells(cells []v1beta1.CellStatus, directives map[string]string) []v1beta1.CellStatus {
+	pattern := map[string]string{}
+	for _, cell := range cells {
+		for key := range cell.Values {
+			if value, ok := directives[key]; ok {
+				pattern[key] = value
+			}
+		}
+	}
+	if len(pattern) == 0 {
+		return cells
+	}
+
+	selected := []v1beta1.CellStatus{}
+	for _, cell := range cells {
+		if matchesAxes(cell.Values, pattern, nil) {
+			selected = append(selected, cell)
+		}
+	}
+
+	return selected
+}
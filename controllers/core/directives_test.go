@@ -0,0 +1,120 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+var parseDirectivesTests = []struct {
+	name       string
+	message    string
+	directives map[string]string
+	skipReason string
+}{
+	{
+		name:    "plain",
+		message: "Fix the parser\n\nIt dropped the last line.",
+	},
+	{
+		name:       "skip ci",
+		message:    "Update the README [skip ci]",
+		skipReason: "Commit message contains [skip ci]",
+	},
+	{
+		name:       "ci skip",
+		message:    "Update the README\n\n[CI SKIP]",
+		skipReason: "Commit message contains [ci skip]",
+	},
+	{
+		name:       "no ci",
+		message:    "Bump the version [no ci]",
+		skipReason: "Commit message contains [no ci]",
+	},
+	{
+		name:       "skip hedron",
+		message:    "Bump the version [skip hedron]",
+		skipReason: "Commit message contains [skip hedron]",
+	},
+	{
+		name:       "hedron skip",
+		message:    "Bump the version\n\nhedron: skip",
+		directives: map[string]string{"skip": "true"},
+		skipReason: "Commit message contains hedron: skip",
+	},
+	{
+		name:       "directives",
+		message:    "Lint only\n\nHedron: stage=lint Verbose\nhedron: os=linux",
+		directives: map[string]string{"stage": "lint", "verbose": "true", "os": "linux"},
+	},
+}
+
+func TestParseDirectives(t *testing.T) {
+	for _, test := range parseDirectivesTests {
+		t.Run(test.name, func(t *testing.T) {
+			directives, skipReason := parseDirectives(test.message)
+			if !reflect.DeepEqual(directives, test.directives) {
+				t.Errorf("expected directives %v, got %v", test.directives, directives)
+			}
+			if skipReason != test.skipReason {
+				t.Errorf("expected skip reason %q, got %q", test.skipReason, skipReason)
+			}
+		})
+	}
+}
+
+var selectCellsTests = []struct {
+	name       string
+	directives map[string]string
+	cells      []string
+}{
+	{
+		name:  "no directives",
+		cells: []string{"stage=lint,os=linux", "stage=lint,os=darwin", "stage=test,os=linux", "stage=test,os=darwin"},
+	},
+	{
+		name:       "unrelated directive",
+		directives: map[string]string{"verbose": "true"},
+		cells:      []string{"stage=lint,os=linux", "stage=lint,os=darwin", "stage=test,os=linux", "stage=test,os=darwin"},
+	},
+	{
+		name:       "one axis",
+		directives: map[string]string{"stage": "lint"},
+		cells:      []string{"stage=lint,os=linux", "stage=lint,os=darwin"},
+	},
+	{
+		name:       "two axes",
+		directives: map[string]string{"stage": "test", "os": "darwin"},
+		cells:      []string{"stage=test,os=darwin"},
+	},
+	{
+		name:       "no match",
+		directives: map[string]string{"stage": "deploy"},
+		cells:      []string{},
+	},
+}
+
+func TestSelectCells(t *testing.T) {
+	matrix := &v1beta1.Matrix{Axes: []v1beta1.Axis{
+		{Name: "stage", Values: []string{"lint", "test"}},
+		{Name: "os", Values: []string{"linux", "darwin"}},
+	}}
+
+	for _, test := range selectCellsTests {
+		t.Run(test.name, func(t *testing.T) {
+			names := []string{}
+			for _, cell := range selectCells(expandMatrix(matrix), test.directives) {
+				names = append(names, cell.Name)
+			}
+
+			if !reflect.DeepEqual(names, test.cells) {
+				t.Errorf("expected %v, got %v", test.cells, names)
+			}
+		})
+	}
+}
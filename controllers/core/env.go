@@ -0,0 +1,101 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// buildEnv returns the environment of the build container: the built-in
+// HEDRON_* variables followed by the project variables
+func buildEnv(project v1beta1.Project, revision v1beta1.Revision, cell v1beta1.CellStatus) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{Name: "HEDRON_PROJECT", Value: project.Name},
+		{Name: "HEDRON_REVISION", Value: revision.Name},
+		{Name: "HEDRON_COMMIT_SHA", Value: revision.Spec.Revision},
+		{Name: "HEDRON_REF", Value: revision.Spec.Ref},
+		{Name: "HEDRON_BUILD_NUMBER", Value: fmt.Sprint(revision.Spec.BuildNumber)},
+	}
+
+	if revision.Spec.PullRequest != nil {
+		env = append(env, corev1.EnvVar{Name: "HEDRON_PULL_REQUEST", Value: fmt.Sprint(revision.Spec.PullRequest.Number)})
+	}
+
+	keys := []string{}
+	for key := range revision.Spec.Directives {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		env = append(env, corev1.EnvVar{
+			Name:  "HEDRON_DIRECTIVE_" + strings.ToUpper(envNameInvalidChars.ReplaceAllString(key, "_")),
+			Value: revision.Spec.Directives[key],
+		})
+	}
+
+	env = append(env, matrixEnv(cell)...)
+
+	trusted := revision.Trusted(project)
+	for _, envVar := range project.Spec.Env {
+		if envVar.Protected && !trusted {
+			continue
+		}
+		env = append(env, envVar.EnvVar)
+	}
+
+	return env
+}
+
+// buildEnvFrom returns the Secrets and ConfigMaps the build environment is
+// populated from
+func buildEnvFrom(project v1beta1.Project, revision v1beta1.Revision) []corev1.EnvFromSource {
+	envFrom := []corev1.EnvFromSource{}
+
+	trusted := revision.Trusted(project)
+	for _, source := range project.Spec.EnvFrom {
+		if source.Protected && !trusted {
+			continue
+		}
+		envFrom = append(envFrom, source.EnvFromSource)
+	}
+
+	return envFrom
+}
+
+// secretMounts returns the volumes and mounts of the project secret files
+func secretMounts(project v1beta1.Project, revision v1beta1.Revision) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{}
+	mounts := []corev1.VolumeMount{}
+
+	trusted := revision.Trusted(project)
+	for i, secretMount := range project.Spec.SecretMounts {
+		if secretMount.Protected && !trusted {
+			continue
+		}
+
+		name := fmt.Sprintf("secret-%d", i)
+		volumes = append(volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName: secretMount.SecretName,
+				Items:      secretMount.Items,
+			}},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: secretMount.MountPath,
+			ReadOnly:  true,
+		})
+	}
+
+	return volumes, mounts
+}
@@ -0,0 +1,133 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+func testProject() v1beta1.Project {
+	return v1beta1.Project{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app", UID: "project-uid"},
+		Spec: v1beta1.ProjectSpec{
+			Repository: v1beta1.Repository{URL: "https://example.com/app.git", Ref: "refs/heads/main"},
+			Env: []v1beta1.EnvVar{
+				{EnvVar: corev1.EnvVar{Name: "PUBLIC", Value: "public"}},
+				{EnvVar: corev1.EnvVar{Name: "TOKEN", Value: "secret"}, Protected: true},
+			},
+		},
+	}
+}
+
+func testRevision(project v1beta1.Project) v1beta1.Revision {
+	controller := true
+
+	return v1beta1.Revision{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: project.Namespace,
+			Name:      project.Name + "-0123abc",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: v1beta1.GroupVersion.String(),
+				Kind:       "Project",
+				Name:       project.Name,
+				UID:        project.UID,
+				Controller: &controller,
+			}},
+		},
+		Spec: v1beta1.RevisionSpec{
+			ProjectRef:  corev1.LocalObjectReference{Name: project.Name},
+			Revision:    "0123abc",
+			Ref:         project.Spec.Repository.Ref,
+			BuildNumber: 7,
+		},
+	}
+}
+
+// testScheme returns a scheme with the built-in and the v1beta1 types
+func testScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return scheme
+}
+
+var buildEnvTests = []struct {
+	name   string
+	mutate func(*v1beta1.Revision)
+	env    []corev1.EnvVar
+}{
+	{
+		name:   "trusted",
+		mutate: func(revision *v1beta1.Revision) {},
+		env: []corev1.EnvVar{
+			{Name: "HEDRON_PROJECT", Value: "app"},
+			{Name: "HEDRON_REVISION", Value: "app-0123abc"},
+			{Name: "HEDRON_COMMIT_SHA", Value: "0123abc"},
+			{Name: "HEDRON_REF", Value: "refs/heads/main"},
+			{Name: "HEDRON_BUILD_NUMBER", Value: "7"},
+			{Name: "PUBLIC", Value: "public"},
+			{Name: "TOKEN", Value: "secret"},
+		},
+	},
+	{
+		name: "untrusted",
+		mutate: func(revision *v1beta1.Revision) {
+			revision.OwnerReferences = nil
+			revision.Spec.Directives = map[string]string{"skip-tests": "true"}
+		},
+		env: []corev1.EnvVar{
+			{Name: "HEDRON_PROJECT", Value: "app"},
+			{Name: "HEDRON_REVISION", Value: "app-0123abc"},
+			{Name: "HEDRON_COMMIT_SHA", Value: "0123abc"},
+			{Name: "HEDRON_REF", Value: "refs/heads/main"},
+			{Name: "HEDRON_BUILD_NUMBER", Value: "7"},
+			{Name: "HEDRON_DIRECTIVE_SKIP_TESTS", Value: "true"},
+			{Name: "PUBLIC", Value: "public"},
+		},
+	},
+	{
+		name: "fork",
+		mutate: func(revision *v1beta1.Revision) {
+			revision.Spec.PullRequest = &v1beta1.PullRequest{Number: 12, SourceURL: "https://example.com/fork.git"}
+		},
+		env: []corev1.EnvVar{
+			{Name: "HEDRON_PROJECT", Value: "app"},
+			{Name: "HEDRON_REVISION", Value: "app-0123abc"},
+			{Name: "HEDRON_COMMIT_SHA", Value: "0123abc"},
+			{Name: "HEDRON_REF", Value: "refs/heads/main"},
+			{Name: "HEDRON_BUILD_NUMBER", Value: "7"},
+			{Name: "HEDRON_PULL_REQUEST", Value: "12"},
+			{Name: "PUBLIC", Value: "public"},
+		},
+	},
+}
+
+func TestBuildEnv(t *testing.T) {
+	for _, test := range buildEnvTests {
+		t.Run(test.name, func(t *testing.T) {
+			project := testProject()
+			revision := testRevision(project)
+			test.mutate(&revision)
+
+			env := buildEnv(project, revision, v1beta1.CellStatus{Name: "default"})
+			if !reflect.DeepEqual(env, test.env) {
+				t.Errorf("expected %v, got %v", test.env, env)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+const (
+	imageContainerPrefix = "image-"
+
+	dockerConfigPath = "/kaniko/.docker"
+)
+
+// digestPattern matches the digests of pushed images
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// renderImageBuild expands the templates of an image build and checks its
+// paths
+func renderImageBuild(image v1beta1.ImageBuild, data templateData) (v1beta1.ImageBuild, error) {
+	rendered := image
+	if rendered.Context == "" {
+		rendered.Context = "."
+	}
+	if rendered.Dockerfile == "" {
+		rendered.Dockerfile = path.Join(rendered.Context, "Dockerfile")
+	}
+
+	for _, workspacePath := range []string{rendered.Context, rendered.Dockerfile} {
+		if err := checkWorkspacePath(workspacePath); err != nil {
+			return rendered, err
+		}
+	}
+
+	var err error
+	if rendered.Destinations, err = renderTemplates(image.Destinations, data); err != nil {
+		return rendered, err
+	}
+	if len(rendered.Destinations) == 0 {
+		return rendered, fmt.Errorf("image %s has no destinations", image.Name)
+	}
+
+	rendered.BuildArgs = map[string]string{}
+	for name, value := range image.BuildArgs {
+		if rendered.BuildArgs[name], err = renderTemplate(value, data); err != nil {
+			return rendered, err
+		}
+	}
+
+	return rendered, nil
+}
+
+// checkWorkspacePath rejects paths outside of the workspace
+func checkWorkspacePath(workspacePath string) error {
+	cleaned := path.Clean(workspacePath)
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("path %s is outside of the workspace", workspacePath)
+	}
+
+	return nil
+}
+
+// imageScript holds the image build until the build container exits, and
+// skips it unless the build succeeded
+var imageScript = strings.Join([]string{
+	waitForBuildScript,
+	fmt.Sprintf(`grep -qs '^%s="0"$' %s/annotations || exit 0`, buildExitCodeAnnotation, podInfoPath),
+	`exec /kaniko/executor "$@"`,
+}, "\n")
+
+// dockerConfigVolume is the volume of the registry credentials of an image
+func dockerConfigVolume(image v1beta1.ImageBuild) string {
+	return imageContainerPrefix + image.Name + "-docker"
+}
+
+// imageContainer returns the container building an image, which is pushed
+// when push is set, its digest being the termination message
+func (r *RevisionReconciler) imageContainer(image v1beta1.ImageBuild, sbom bool, push bool) (corev1.Container, []corev1.Volume) {
+	args := []string{
+		"--context=dir://" + path.Join(workspacePath, image.Context),
+		"--dockerfile=" + path.Join(workspacePath, image.Dockerfile),
+	}
+	if !push {
+		args = append(args, "--no-push")
+	} else {
+		args = append(args, "--digest-file=/dev/termination-log")
+		if sbom {
+			args = append(args, "--image-name-with-digest-file="+imageReferenceFile(image))
+		}
+	}
+	for _, destination := range image.Destinations {
+		args = append(args, "--destination="+destination)
+	}
+
+	names := []string{}
+	for name := range image.BuildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--build-arg="+name+"="+image.BuildArgs[name])
+	}
+
+	if image.Insecure {
+		args = append(args, "--insecure", "--insecure-pull", "--skip-tls-verify", "--skip-tls-verify-pull")
+	}
+
+	container := corev1.Container{
+		Name:       imageContainerPrefix + image.Name,
+		Image:      r.ImageBuilderImage,
+		Command:    []string{"/busybox/sh", "-c", imageScript, "hedron-image"},
+		Args:       args,
+		WorkingDir: workspacePath,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workspaceVolume, MountPath: workspacePath, ReadOnly: true},
+			{Name: podInfoVolume, MountPath: podInfoPath, ReadOnly: true},
+		},
+	}
+
+	if sbom {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: sbomVolume, MountPath: sbomPath})
+	}
+
+	if image.SecretRef == nil || !push {
+		return container, nil
+	}
+
+	volume := corev1.Volume{
+		Name: dockerConfigVolume(image),
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: image.SecretRef.Name,
+			Items:      []corev1.KeyToPath{{Key: corev1.DockerConfigJsonKey, Path: "config.json"}},
+		}},
+	}
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      volume.Name,
+		MountPath: dockerConfigPath,
+		ReadOnly:  true,
+	})
+
+	return container, []corev1.Volume{volume}
+}
+
+// imageContainers returns the containers building the images of a pipeline.
+// Only trusted revisions get the registry credentials and push images.
+func (r *RevisionReconciler) imageContainers(pipeline v1beta1.Pipeline, data templateData, trusted bool) ([]corev1.Container, []corev1.Volume, error) {
+	containers := []corev1.Container{}
+	volumes := []corev1.Volume{}
+
+	for _, image := range pipeline.Images {
+		rendered, err := renderImageBuild(image, data)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		container, imageVolumes := r.imageContainer(rendered, pipeline.SBOM != nil, trusted)
+		containers = append(containers, container)
+		volumes = append(volumes, imageVolumes...)
+	}
+
+	return containers, volumes, nil
+}
+
+// collectImages returns the images pushed by the pod of a finished cell,
+// whose digests are the termination messages of their containers
+func (r *RevisionReconciler) collectImages(ctx context.Context, images []v1beta1.ImageBuild) ([]v1beta1.ImageStatus, error) {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	if !revision.Trusted(project) {
+		return nil, nil
+	}
+
+	pod, err := r.fetchPod(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	digests := map[string]string{}
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if !strings.HasPrefix(status.Name, imageContainerPrefix) || terminated == nil || terminated.ExitCode != 0 {
+			continue
+		}
+
+		digest := strings.TrimSpace(terminated.Message)
+		if !digestPattern.MatchString(digest) {
+			r.Log.Info("Ignoring image with an invalid digest", "pod", pod.Name, "container", status.Name)
+			continue
+		}
+		digests[strings.TrimPrefix(status.Name, imageContainerPrefix)] = digest
+	}
+
+	data := newTemplateData(project, revision, cell)
+
+	statuses := []v1beta1.ImageStatus{}
+	for _, image := range images {
+		digest, ok := digests[image.Name]
+		if !ok {
+			continue
+		}
+
+		rendered, err := renderImageBuild(image, data)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, v1beta1.ImageStatus{
+			Name:         image.Name,
+			Cell:         cell.Name,
+			Destinations: rendered.Destinations,
+			Digest:       digest,
+		})
+	}
+
+	return statuses, nil
+}
+
+// imagesDone reports whether the image containers of a pod have all
+// terminated
+func imagesDone(pod corev1.Pod) bool {
+	found := false
+	for _, status := range pod.Status.ContainerStatuses {
+		if !strings.HasPrefix(status.Name, imageContainerPrefix) {
+			continue
+		}
+		if status.State.Terminated == nil {
+			return false
+		}
+		found = true
+	}
+
+	return found
+}
+
+// imageFailure returns why an image build of a pod failed, if it did. Pushed
+// images must also report their digest.
+func imageFailure(pod corev1.Pod, pushed bool) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if !strings.HasPrefix(status.Name, imageContainerPrefix) || terminated == nil {
+			continue
+		}
+		name := strings.TrimPrefix(status.Name, imageContainerPrefix)
+
+		if terminated.ExitCode != 0 {
+			return fmt.Sprintf("Image %s build exited with code %d", name, terminated.ExitCode)
+		}
+		if pushed && !digestPattern.MatchString(strings.TrimSpace(terminated.Message)) {
+			return fmt.Sprintf("Image %s build reported an invalid digest", name)
+		}
+	}
+
+	return ""
+}
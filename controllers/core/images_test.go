@@ -0,0 +1,159 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+const testDigest = "sha256:d1b2a59fbea7e20077af9f91b27e95e865061b270be03ff539ab3b73587882e8"
+
+func imagePod(exitCode int32, message string) corev1.Pod {
+	return corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+		Name: imageContainerPrefix + "app",
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: exitCode,
+			Message:  message,
+		}},
+	}}}}
+}
+
+var imageFailureTests = []struct {
+	name   string
+	pod    corev1.Pod
+	pushed bool
+	reason string
+}{
+	{
+		name:   "pushed",
+		pod:    imagePod(0, testDigest+"\n"),
+		pushed: true,
+		reason: "",
+	},
+	{
+		name:   "failed",
+		pod:    imagePod(1, ""),
+		pushed: true,
+		reason: "Image app build exited with code 1",
+	},
+	{
+		name:   "invalid digest",
+		pod:    imagePod(0, "sha256:0123"),
+		pushed: true,
+		reason: "Image app build reported an invalid digest",
+	},
+	{
+		name:   "uppercase digest",
+		pod:    imagePod(0, strings.ToUpper(testDigest)),
+		pushed: true,
+		reason: "Image app build reported an invalid digest",
+	},
+	{
+		name:   "built without push",
+		pod:    imagePod(0, ""),
+		pushed: false,
+		reason: "",
+	},
+}
+
+func TestImageFailure(t *testing.T) {
+	for _, test := range imageFailureTests {
+		t.Run(test.name, func(t *testing.T) {
+			if reason := imageFailure(test.pod, test.pushed); reason != test.reason {
+				t.Errorf("expected %q, got %q", test.reason, reason)
+			}
+		})
+	}
+}
+
+func TestImageContainerPush(t *testing.T) {
+	r := RevisionReconciler{}
+	image := v1beta1.ImageBuild{
+		Name:         "app",
+		Context:      ".",
+		Dockerfile:   "Dockerfile",
+		Destinations: []string{"registry.example.com/app"},
+		SecretRef:    &corev1.LocalObjectReference{Name: "registry"},
+	}
+
+	for _, push := range []bool{true, false} {
+		container, volumes := r.imageContainer(image, true, push)
+		args := strings.Join(container.Args, " ")
+
+		if noPush := strings.Contains(args, "--no-push"); noPush == push {
+			t.Errorf("push %v: unexpected arguments %s", push, args)
+		}
+		if digest := strings.Contains(args, "--digest-file="); digest != push {
+			t.Errorf("push %v: unexpected arguments %s", push, args)
+		}
+		if hasCredentials := len(volumes) > 0; hasCredentials != push {
+			t.Errorf("push %v: unexpected volumes %v", push, volumes)
+		}
+	}
+}
+
+func TestReconcileRetriesImages(t *testing.T) {
+	project := testProject()
+	project.Spec.Pipeline.Images = []v1beta1.ImageBuild{{Name: "app", Destinations: []string{"registry.example.com/app:latest"}}}
+	revision := testRevision(project)
+	cell := v1beta1.CellStatus{Name: "build", Job: revision.Name + "-build", State: "Pending"}
+	revision.Status = v1beta1.RevisionStatus{State: "Pending", Cells: []v1beta1.CellStatus{cell}}
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: revision.Namespace, Name: cell.Job},
+		Status:     batchv1.JobStatus{Succeeded: 1},
+	}
+
+	// The pod is not in the cache yet
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	reconciler := RevisionReconciler{
+		Client: fake.NewFakeClientWithScheme(testScheme(t), &project, &revision, &job),
+		Log:    logf.NullLogger{},
+		pods:   corelisters.NewPodLister(indexer),
+	}
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: revision.Namespace, Name: revision.Name}}
+
+	if _, err := reconciler.Reconcile(request); err == nil {
+		t.Error("expected an error while the images cannot be collected")
+	}
+
+	var reconciled v1beta1.Revision
+	if err := reconciler.Get(context.Background(), request.NamespacedName, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.Status.State != "Pending" || reconciled.Status.Cells[0].State != "Pending" {
+		t.Errorf("expected the revision to stay pending, got %v", reconciled.Status)
+	}
+
+	pod := imagePod(0, testDigest)
+	pod.ObjectMeta = metav1.ObjectMeta{Namespace: revision.Namespace, Name: cell.Job + "-abcde", Labels: map[string]string{"job-name": cell.Job}}
+	if err := indexer.Add(&pod); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reconciler.Get(context.Background(), request.NamespacedName, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.Status.State != "Succeeded" || len(reconciled.Status.Images) != 1 || reconciled.Status.Images[0].Digest != testDigest {
+		t.Errorf("expected the revision to succeed with its image, got %v", reconciled.Status)
+	}
+}
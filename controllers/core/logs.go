@@ -0,0 +1,54 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/buildlog"
+)
+
+// captureLogs stores the logs of the containers of the pod of a finished
+// cell, with the secrets injected into the build masked
+func (r *RevisionReconciler) captureLogs(ctx context.Context) error {
+	if r.Store == nil || r.Clientset == nil {
+		return nil
+	}
+
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	pod, err := r.fetchPod(ctx)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := buildlog.SecretValues(ctx, r, project, revision)
+	if err != nil {
+		return err
+	}
+
+	containers := append([]corev1.Container{}, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+
+	for _, container := range containers {
+		key, err := buildlog.Key(revision.Namespace, revision.Name, cell.Job, container.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := buildlog.Capture(ctx, r.Clientset, r.Store, pod, container.Name, key, secrets); err != nil {
+			return err
+		}
+	}
+
+	r.Log.Info("Captured build logs", "pod", pod.Name)
+
+	return nil
+}
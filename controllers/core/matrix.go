@@ -0,0 +1,204 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// defaultCell is the name of the only cell of a revision without a matrix
+const defaultCell = "build"
+
+// expandMatrix returns one pending cell per combination of the matrix
+func expandMatrix(matrix *v1beta1.Matrix) []v1beta1.CellStatus {
+	if matrix == nil || (len(matrix.Axes) == 0 && len(matrix.Include) == 0) {
+		return []v1beta1.CellStatus{{Name: defaultCell, State: "Pending"}}
+	}
+
+	combinations := []map[string]string{{}}
+	for _, axis := range matrix.Axes {
+		if len(axis.Values) == 0 {
+			continue
+		}
+
+		expanded := []map[string]string{}
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				values := copyValues(combination)
+				values[axis.Name] = value
+				expanded = append(expanded, values)
+			}
+		}
+		combinations = expanded
+	}
+	if len(combinations) == 1 && len(combinations[0]) == 0 {
+		combinations = nil
+	}
+
+	kept := []map[string]string{}
+	for _, combination := range combinations {
+		if !matchesAny(combination, matrix.Exclude) {
+			kept = append(kept, combination)
+		}
+	}
+	combinations = kept
+
+	axisNames := map[string]bool{}
+	for _, axis := range matrix.Axes {
+		axisNames[axis.Name] = true
+	}
+
+	for _, include := range matrix.Include {
+		extended := false
+		for _, combination := range combinations {
+			if matchesAxes(combination, include, axisNames) {
+				for key, value := range include {
+					combination[key] = value
+				}
+				extended = true
+			}
+		}
+		if !extended {
+			combinations = append(combinations, copyValues(include))
+		}
+	}
+
+	cells := make([]v1beta1.CellStatus, len(combinations))
+	for i, combination := range combinations {
+		cells[i] = v1beta1.CellStatus{
+			Name:         cellName(combination, matrix.Axes),
+			Values:       combination,
+			State:        "Pending",
+			AllowFailure: matchesAny(combination, matrix.AllowFailures),
+		}
+	}
+
+	return cells
+}
+
+// cellName joins the values of a combination, axis values first
+func cellName(values map[string]string, axes []v1beta1.Axis) string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, axis := range axes {
+		if _, ok := values[axis.Name]; ok && !seen[axis.Name] {
+			keys = append(keys, axis.Name)
+			seen[axis.Name] = true
+		}
+	}
+
+	extra := []string{}
+	for key := range values {
+		if !seen[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+
+	parts := []string{}
+	for _, key := range append(keys, extra...) {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, values[key]))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// cellJobName names the job of the cell at index, keeping the revision name
+// for revisions without a matrix.
+func cellJobName(revision v1beta1.Revision, index int) string {
+	if len(revision.Status.Cells) == 1 && revision.Status.Cells[0].Name == defaultCell {
+		return revision.Name
+	}
+
+	return fmt.Sprintf("%s-%d", revision.Name, index)
+}
+
+var envNameInvalidChars = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// matrixEnv exposes the values of a cell as HEDRON_MATRIX_<NAME> variables
+func matrixEnv(cell v1beta1.CellStatus) []corev1.EnvVar {
+	keys := []string{}
+	for key := range cell.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := []corev1.EnvVar{}
+	for _, key := range keys {
+		env = append(env, corev1.EnvVar{
+			Name:  "HEDRON_MATRIX_" + strings.ToUpper(envNameInvalidChars.ReplaceAllString(key, "_")),
+			Value: cell.Values[key],
+		})
+	}
+
+	return env
+}
+
+// cellsState aggregates the state of the cells into the revision state. The
+// revision is pending until every cell is done, and then fails if any cell
+// that is not allowed to fail did.
+func cellsState(cells []v1beta1.CellStatus) v1beta1.State {
+	failed := false
+
+	for _, cell := range cells {
+		switch cell.State {
+		case "Succeeded":
+		case "Failed":
+			if !cell.AllowFailure {
+				failed = true
+			}
+		default:
+			return "Pending"
+		}
+	}
+
+	if failed {
+		return "Failed"
+	}
+
+	return "Succeeded"
+}
+
+// matchesAny reports whether values contains all the values of any pattern
+func matchesAny(values map[string]string, patterns []map[string]string) bool {
+	for _, pattern := range patterns {
+		if matchesAxes(values, pattern, nil) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesAxes reports whether values agrees with all the values of pattern,
+// only considering the keys in axisNames when it is not nil.
+func matchesAxes(values map[string]string, pattern map[string]string, axisNames map[string]bool) bool {
+	for key, value := range pattern {
+		if axisNames != nil && !axisNames[key] {
+			continue
+		}
+		if values[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func copyValues(values map[string]string) map[string]string {
+	copied := make(map[string]string, len(values))
+	for key, value := range values {
+		copied[key] = value
+	}
+
+	return copied
+}
@@ -0,0 +1,139 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+var expandMatrixTests = []struct {
+	name          string
+	matrix        *v1beta1.Matrix
+	cells         []string
+	allowFailures []string
+}{
+	{
+		name:   "no matrix",
+		matrix: nil,
+		cells:  []string{"build"},
+	},
+	{
+		name: "axes",
+		matrix: &v1beta1.Matrix{Axes: []v1beta1.Axis{
+			{Name: "go", Values: []string{"1.14", "1.15"}},
+			{Name: "os", Values: []string{"linux", "darwin"}},
+		}},
+		cells: []string{"go=1.14,os=linux", "go=1.14,os=darwin", "go=1.15,os=linux", "go=1.15,os=darwin"},
+	},
+	{
+		name: "exclude",
+		matrix: &v1beta1.Matrix{
+			Axes: []v1beta1.Axis{
+				{Name: "go", Values: []string{"1.14", "1.15"}},
+				{Name: "os", Values: []string{"linux", "darwin"}},
+			},
+			Exclude: []map[string]string{{"go": "1.14", "os": "darwin"}},
+		},
+		cells: []string{"go=1.14,os=linux", "go=1.15,os=linux", "go=1.15,os=darwin"},
+	},
+	{
+		name: "include",
+		matrix: &v1beta1.Matrix{
+			Axes: []v1beta1.Axis{{Name: "go", Values: []string{"1.14", "1.15"}}},
+			Include: []map[string]string{
+				{"go": "1.15", "race": "true"},
+				{"go": "tip"},
+			},
+		},
+		cells: []string{"go=1.14", "go=1.15,race=true", "go=tip"},
+	},
+	{
+		name: "allow failures",
+		matrix: &v1beta1.Matrix{
+			Axes:          []v1beta1.Axis{{Name: "go", Values: []string{"1.15", "tip"}}},
+			AllowFailures: []map[string]string{{"go": "tip"}},
+		},
+		cells:         []string{"go=1.15", "go=tip"},
+		allowFailures: []string{"go=tip"},
+	},
+}
+
+func TestExpandMatrix(t *testing.T) {
+	for _, test := range expandMatrixTests {
+		t.Run(test.name, func(t *testing.T) {
+			cells := []string{}
+			allowFailures := []string{}
+			for _, cell := range expandMatrix(test.matrix) {
+				if cell.State != "Pending" {
+					t.Errorf("expected cell %s to be pending, got %s", cell.Name, cell.State)
+				}
+
+				cells = append(cells, cell.Name)
+				if cell.AllowFailure {
+					allowFailures = append(allowFailures, cell.Name)
+				}
+			}
+
+			if !reflect.DeepEqual(cells, test.cells) {
+				t.Errorf("expected cells %v, got %v", test.cells, cells)
+			}
+			if test.allowFailures == nil {
+				test.allowFailures = []string{}
+			}
+			if !reflect.DeepEqual(allowFailures, test.allowFailures) {
+				t.Errorf("expected allowed failures %v, got %v", test.allowFailures, allowFailures)
+			}
+		})
+	}
+}
+
+func TestCellName(t *testing.T) {
+	axes := []v1beta1.Axis{{Name: "os"}, {Name: "go"}}
+	values := map[string]string{"go": "1.15", "os": "linux", "race": "true", "cgo": "0"}
+
+	if name := cellName(values, axes); name != "os=linux,go=1.15,cgo=0,race=true" {
+		t.Errorf("unexpected name %s", name)
+	}
+}
+
+var cellsStateTests = []struct {
+	name  string
+	cells []v1beta1.CellStatus
+	state v1beta1.State
+}{
+	{
+		name:  "pending",
+		cells: []v1beta1.CellStatus{{State: "Succeeded"}, {State: "Pending"}, {State: "Failed"}},
+		state: "Pending",
+	},
+	{
+		name:  "succeeded",
+		cells: []v1beta1.CellStatus{{State: "Succeeded"}, {State: "Succeeded"}},
+		state: "Succeeded",
+	},
+	{
+		name:  "failed",
+		cells: []v1beta1.CellStatus{{State: "Succeeded"}, {State: "Failed"}},
+		state: "Failed",
+	},
+	{
+		name:  "allowed failure",
+		cells: []v1beta1.CellStatus{{State: "Succeeded"}, {State: "Failed", AllowFailure: true}},
+		state: "Succeeded",
+	},
+}
+
+func TestCellsState(t *testing.T) {
+	for _, test := range cellsStateTests {
+		t.Run(test.name, func(t *testing.T) {
+			if state := cellsState(test.cells); state != test.state {
+				t.Errorf("expected %s, got %s", test.state, state)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/server"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+const (
+	// nixOutputsContainer records the outputs of Nix builds, and copies them
+	// to the shared cache
+	nixOutputsContainer = "nix-outputs"
+
+	// The build container exports its outputs to the nix-outputs container
+	// through a file binary cache
+	nixOutputsVolume = "nix-outputs"
+	nixOutputsPath   = "/hedron/nix"
+
+	nixCacheVolume = "nix-cache"
+	nixCachePath   = "/nix-cache"
+
+	nixCacheKeyVolume = "nix-cache-key"
+	nixCacheKeyPath   = "/etc/hedron/nix-cache-key"
+
+	nixBinaryCacheVolume = "nix-binary-cache"
+
+	nixSigningKeyVolume = "nix-signing-key"
+	nixSigningKeyPath   = "/etc/hedron/nix-signing-key"
+)
+
+// nixUploadURL returns the store URL outputs are copied to, which signs them
+// as they are uploaded
+func nixUploadURL(binaryCache v1beta1.NixBinaryCache) (string, error) {
+	cacheURL, err := url.Parse(binaryCache.URL)
+	if err != nil {
+		return "", err
+	}
+
+	switch cacheURL.Scheme {
+	case "s3":
+		if cacheURL.Host == "" {
+			return "", fmt.Errorf("binary cache %s has no bucket", binaryCache.URL)
+		}
+	case "file":
+		if !path.IsAbs(cacheURL.Path) || path.Clean(cacheURL.Path) == "/" {
+			return "", fmt.Errorf("binary cache %s has no absolute path", binaryCache.URL)
+		}
+	default:
+		return "", fmt.Errorf("binary cache %s is neither an s3 nor a file URL", binaryCache.URL)
+	}
+
+	query := cacheURL.Query()
+	query.Set("secret-key", path.Join(nixSigningKeyPath, "key"))
+	cacheURL.RawQuery = query.Encode()
+
+	return cacheURL.String(), nil
+}
+
+// nixScript builds with Nix, then writes the output paths along with their
+// NAR hashes for the nix-outputs container, which also gets the outputs when
+// export is set
+func nixScript(nix v1beta1.Nix, export bool) (string, error) {
+	config := []string{"experimental-features = nix-command flakes"}
+	if nix.CacheClaimName != "" {
+		config = append(config, "extra-substituters = file://"+nixCachePath, "extra-trusted-public-keys = "+nix.CachePublicKey)
+	}
+
+	args := make([]string, len(nix.Args))
+	for i, arg := range nix.Args {
+		args[i] = shellQuote(arg)
+	}
+
+	var build string
+	if nix.Flake {
+		installable := "."
+		if nix.Attribute != "" {
+			installable += "#" + nix.Attribute
+		}
+
+		build = fmt.Sprintf("nix build --no-link --print-out-paths %s", shellQuote(installable))
+	} else {
+		file := nix.File
+		if file == "" {
+			file = "default.nix"
+		}
+		if err := checkWorkspacePath(file); err != nil {
+			return "", err
+		}
+
+		build = fmt.Sprintf("nix-build --no-out-link %s", shellQuote(file))
+		if nix.Attribute != "" {
+			build += " -A " + shellQuote(nix.Attribute)
+		}
+	}
+	if len(args) > 0 {
+		build += " " + strings.Join(args, " ")
+	}
+
+	script := []string{
+		"set -eu",
+		"export NIX_CONFIG=" + shellQuote(strings.Join(config, "\n")),
+		build + " > /tmp/nix-out-paths",
+		"cat /tmp/nix-out-paths",
+		fmt.Sprintf(`for path in $(cat /tmp/nix-out-paths); do echo "$path $(nix-store --query --hash "$path")"; done > %s/outputs`, nixOutputsPath),
+	}
+	if export {
+		script = append(script,
+			fmt.Sprintf(`nix copy --to file://%s/store $(cat /tmp/nix-out-paths) || echo "Nix outputs not exported"`, nixOutputsPath),
+		)
+	}
+
+	return strings.Join(script, "\n"), nil
+}
+
+// nixOutputsScript records the outputs of a successful Nix build, once
+// copied to the shared cache when cache is set, and to the binary cache of
+// nix. Failing to copy outputs does not fail the build.
+func nixOutputsScript(nix v1beta1.Nix, cache bool) (string, error) {
+	script := []string{
+		"set -u",
+		waitForBuildScript,
+		fmt.Sprintf(`grep -qs '^%s="0"$' %s/annotations || exit 0`, buildExitCodeAnnotation, podInfoPath),
+		"export NIX_CONFIG='experimental-features = nix-command'",
+		fmt.Sprintf("cp %s/outputs /tmp/nix-outputs", nixOutputsPath),
+		`paths=$(grep '^/nix/store/' /tmp/nix-outputs | cut -d ' ' -f 1)`,
+	}
+
+	if cache {
+		cacheURL := fmt.Sprintf("file://%s?secret-key=%s", nixCachePath, path.Join(nixCacheKeyPath, "key"))
+
+		// Exported paths are unsigned until copied to the shared cache
+		script = append(script, fmt.Sprintf(
+			`if nix copy --no-check-sigs --from file://%s/store --to %s $paths; then echo "Cached Nix outputs"; else echo "Nix outputs not cached"; fi`,
+			nixOutputsPath, shellQuote(cacheURL),
+		))
+	}
+
+	if nix.BinaryCache != nil {
+		uploadURL, err := nixUploadURL(*nix.BinaryCache)
+		if err != nil {
+			return "", err
+		}
+
+		script = append(script, fmt.Sprintf(
+			`if nix copy --no-check-sigs --from file://%s/store --to %s $paths; then echo cache %s >> /tmp/nix-outputs; else echo "Nix outputs not uploaded"; fi`,
+			nixOutputsPath, shellQuote(uploadURL), shellQuote(nix.BinaryCache.URL),
+		))
+	}
+
+	script = append(script,
+		`if curl -fsS -T /tmp/nix-outputs -H "Authorization: Bearer $HEDRON_TOKEN" "$HEDRON_NIX_URL"; then echo "Recorded Nix outputs"; else echo "Nix outputs not recorded"; fi`,
+	)
+
+	return strings.Join(script, "\n"), nil
+}
+
+// nixOutputsContainer returns the container recording the Nix outputs of the
+// job of a cell, and alone copying them to the caches for trusted revisions
+func (r *RevisionReconciler) nixOutputsContainer(revision v1beta1.Revision, cell v1beta1.CellStatus, nix v1beta1.Nix, trusted bool) (corev1.Container, error) {
+	cache := nix.CacheClaimName != "" && trusted
+	if !trusted {
+		nix.BinaryCache = nil
+	}
+
+	script, err := nixOutputsScript(nix, cache)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	container := corev1.Container{
+		Name:    nixOutputsContainer,
+		Image:   r.NixImage,
+		Command: []string{"/bin/sh", "-c", script},
+		Env: []corev1.EnvVar{
+			{Name: "HEDRON_NIX_URL", Value: strings.TrimSuffix(r.ServerURL, "/") + "/nix/" + revision.Namespace + "/" + revision.Name + "/" + cell.Job},
+			{Name: "HEDRON_TOKEN", Value: r.Tokens.Sign(revision.Namespace, revision.Name)},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: nixOutputsVolume, MountPath: nixOutputsPath, ReadOnly: true},
+			{Name: podInfoVolume, MountPath: podInfoPath, ReadOnly: true},
+		},
+	}
+
+	if cache {
+		container.VolumeMounts = append(container.VolumeMounts,
+			corev1.VolumeMount{Name: nixCacheVolume, MountPath: nixCachePath},
+			corev1.VolumeMount{Name: nixCacheKeyVolume, MountPath: nixCacheKeyPath, ReadOnly: true},
+		)
+	}
+
+	binaryCache := nix.BinaryCache
+	if binaryCache == nil {
+		return container, nil
+	}
+
+	container.EnvFrom = nixEnvFrom(nix)
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: nixSigningKeyVolume, MountPath: nixSigningKeyPath, ReadOnly: true})
+	if cacheURL, err := url.Parse(binaryCache.URL); err == nil && cacheURL.Scheme == "file" && binaryCache.ClaimName != "" {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: nixBinaryCacheVolume, MountPath: cacheURL.Path})
+	}
+
+	return container, nil
+}
+
+// nixVolumes returns the volumes of Nix builds and the mounts of the build
+// container, where the shared cache is read-only
+func nixVolumes(nix v1beta1.Nix, trusted bool) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{{
+		Name:         nixOutputsVolume,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}}
+	mounts := []corev1.VolumeMount{{Name: nixOutputsVolume, MountPath: nixOutputsPath}}
+
+	if nix.CacheClaimName != "" {
+		volumes = append(volumes, corev1.Volume{
+			Name: nixCacheVolume,
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: nix.CacheClaimName,
+			}},
+		})
+		mounts = append(mounts, corev1.VolumeMount{Name: nixCacheVolume, MountPath: nixCachePath, ReadOnly: true})
+
+		if trusted && nix.CacheSigningKey != nil {
+			volumes = append(volumes, corev1.Volume{
+				Name: nixCacheKeyVolume,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+					SecretName: nix.CacheSigningKey.Name,
+					Items:      []corev1.KeyToPath{{Key: nix.CacheSigningKey.Key, Path: "key"}},
+				}},
+			})
+		}
+	}
+
+	binaryCache := nix.BinaryCache
+	if binaryCache == nil || !trusted {
+		return volumes, mounts
+	}
+
+	volumes = append(volumes, corev1.Volume{
+		Name: nixSigningKeyVolume,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+			SecretName: binaryCache.SigningKey.Name,
+			Items:      []corev1.KeyToPath{{Key: binaryCache.SigningKey.Key, Path: "key"}},
+		}},
+	})
+
+	if cacheURL, err := url.Parse(binaryCache.URL); err == nil && cacheURL.Scheme == "file" && binaryCache.ClaimName != "" {
+		volumes = append(volumes, corev1.Volume{
+			Name: nixBinaryCacheVolume,
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: binaryCache.ClaimName,
+			}},
+		})
+	}
+
+	return volumes, mounts
+}
+
+// nixEnvFrom returns the credentials of the binary cache of Nix builds
+func nixEnvFrom(nix v1beta1.Nix) []corev1.EnvFromSource {
+	if nix.BinaryCache == nil || nix.BinaryCache.CredentialsSecretRef == nil {
+		return nil
+	}
+
+	return []corev1.EnvFromSource{
+		{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: *nix.BinaryCache.CredentialsSecretRef}},
+	}
+}
+
+// collectNixOutputs returns the store paths built by the pod of a finished
+// cell, as recorded by its nix-outputs container
+func (r *RevisionReconciler) collectNixOutputs(ctx context.Context) ([]v1beta1.NixOutput, error) {
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	key, err := server.NixOutputsKey(revision.Namespace, revision.Name, cell.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := r.Store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseNixOutputs(string(contents), cell.Name), nil
+}
+
+// parseNixOutputs parses lines of store paths and NAR hashes, along with the
+// binary cache the paths were copied to
+func parseNixOutputs(contents string, cell string) []v1beta1.NixOutput {
+	outputs := []v1beta1.NixOutput{}
+	cache := ""
+
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "cache" {
+			cache = fields[1]
+		}
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], "/nix/store/") || !strings.HasPrefix(fields[1], "sha256:") {
+			continue
+		}
+
+		outputs = append(outputs, v1beta1.NixOutput{Cell: cell, Path: fields[0], NarHash: fields[1]})
+	}
+
+	for i := range outputs {
+		outputs[i].Cache = cache
+	}
+
+	return outputs
+}
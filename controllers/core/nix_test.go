@@ -0,0 +1,281 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+var nixUploadURLTests = []struct {
+	name      string
+	url       string
+	uploadURL string
+	valid     bool
+}{
+	{
+		name:      "s3",
+		url:       "s3://cache?region=us-east-1",
+		uploadURL: "s3://cache?region=us-east-1&secret-key=%2Fetc%2Fhedron%2Fnix-signing-key%2Fkey",
+		valid:     true,
+	},
+	{
+		name:      "file",
+		url:       "file:///cache",
+		uploadURL: "file:///cache?secret-key=%2Fetc%2Fhedron%2Fnix-signing-key%2Fkey",
+		valid:     true,
+	},
+	{
+		name: "s3 without bucket",
+		url:  "s3:///cache",
+	},
+	{
+		name: "file at the root",
+		url:  "file:///",
+	},
+	{
+		name: "https",
+		url:  "https://cache.example.com",
+	},
+}
+
+func TestNixUploadURL(t *testing.T) {
+	for _, test := range nixUploadURLTests {
+		t.Run(test.name, func(t *testing.T) {
+			uploadURL, err := nixUploadURL(v1beta1.NixBinaryCache{URL: test.url})
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+			if test.valid && uploadURL != test.uploadURL {
+				t.Errorf("expected %s, got %s", test.uploadURL, uploadURL)
+			}
+		})
+	}
+}
+
+var nixScriptTests = []struct {
+	name     string
+	nix      v1beta1.Nix
+	export   bool
+	contains []string
+	excludes []string
+	valid    bool
+}{
+	{
+		name:     "default file",
+		nix:      v1beta1.Nix{},
+		contains: []string{"nix-build --no-out-link 'default.nix'"},
+		excludes: []string{"extra-substituters", "nix copy"},
+		valid:    true,
+	},
+	{
+		name:     "flake attribute",
+		nix:      v1beta1.Nix{Flake: true, Attribute: "app", Args: []string{"--impure"}},
+		contains: []string{"nix build --no-link --print-out-paths '.#app' '--impure'"},
+		valid:    true,
+	},
+	{
+		name:     "signed shared cache",
+		nix:      v1beta1.Nix{CacheClaimName: "nix-cache", CachePublicKey: "cache-1:AAAA"},
+		export:   true,
+		contains: []string{"extra-substituters = file:///nix-cache", "extra-trusted-public-keys = cache-1:AAAA", "nix copy --to file:///hedron/nix/store"},
+		excludes: []string{"require-sigs"},
+		valid:    true,
+	},
+	{
+		name:     "shared cache without export",
+		nix:      v1beta1.Nix{CacheClaimName: "nix-cache", CachePublicKey: "cache-1:AAAA"},
+		contains: []string{"extra-substituters = file:///nix-cache"},
+		excludes: []string{"nix copy"},
+		valid:    true,
+	},
+	{
+		name:  "file outside of the workspace",
+		nix:   v1beta1.Nix{File: "../default.nix"},
+		valid: false,
+	},
+}
+
+func TestNixScript(t *testing.T) {
+	for _, test := range nixScriptTests {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := nixScript(test.nix, test.export)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+
+			for _, text := range test.contains {
+				if !strings.Contains(script, text) {
+					t.Errorf("expected %q in script:\n%s", text, script)
+				}
+			}
+			for _, text := range test.excludes {
+				if strings.Contains(script, text) {
+					t.Errorf("unexpected %q in script:\n%s", text, script)
+				}
+			}
+		})
+	}
+}
+
+var nixOutputsScriptTests = []struct {
+	name     string
+	nix      v1beta1.Nix
+	cache    bool
+	contains []string
+	excludes []string
+	valid    bool
+}{
+	{
+		name:     "record only",
+		nix:      v1beta1.Nix{CacheClaimName: "nix-cache"},
+		contains: []string{`curl -fsS -T /tmp/nix-outputs`},
+		excludes: []string{"nix copy"},
+		valid:    true,
+	},
+	{
+		name:     "shared cache",
+		nix:      v1beta1.Nix{CacheClaimName: "nix-cache"},
+		cache:    true,
+		contains: []string{"--to 'file:///nix-cache?secret-key=/etc/hedron/nix-cache-key/key'"},
+		valid:    true,
+	},
+	{
+		name:     "binary cache",
+		nix:      v1beta1.Nix{BinaryCache: &v1beta1.NixBinaryCache{URL: "s3://cache"}},
+		contains: []string{"--to 's3://cache?secret-key=%2Fetc%2Fhedron%2Fnix-signing-key%2Fkey'", "echo cache 's3://cache' >> /tmp/nix-outputs"},
+		valid:    true,
+	},
+	{
+		name:  "invalid binary cache",
+		nix:   v1beta1.Nix{BinaryCache: &v1beta1.NixBinaryCache{URL: "https://cache.example.com"}},
+		valid: false,
+	},
+}
+
+func TestNixOutputsScript(t *testing.T) {
+	for _, test := range nixOutputsScriptTests {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := nixOutputsScript(test.nix, test.cache)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+
+			for _, text := range test.contains {
+				if !strings.Contains(script, text) {
+					t.Errorf("expected %q in script:\n%s", text, script)
+				}
+			}
+			for _, text := range test.excludes {
+				if strings.Contains(script, text) {
+					t.Errorf("unexpected %q in script:\n%s", text, script)
+				}
+			}
+		})
+	}
+}
+
+func TestNixSigningKeys(t *testing.T) {
+	r := RevisionReconciler{}
+	nix := v1beta1.Nix{
+		CacheClaimName: "nix-cache",
+		CacheSigningKey: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "nix-cache"},
+			Key:                  "secret-key",
+		},
+		BinaryCache: &v1beta1.NixBinaryCache{
+			URL: "s3://cache",
+			SigningKey: corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "nix-binary-cache"},
+				Key:                  "secret-key",
+			},
+		},
+	}
+	keys := map[string]bool{nixCacheKeyVolume: true, nixSigningKeyVolume: true}
+
+	for _, trusted := range []bool{true, false} {
+		volumes, mounts := nixVolumes(nix, trusted)
+
+		signed := 0
+		for _, volume := range volumes {
+			if keys[volume.Name] {
+				signed++
+			}
+		}
+		if trusted && signed != 2 || !trusted && signed != 0 {
+			t.Errorf("trusted %v: unexpected volumes %v", trusted, volumes)
+		}
+
+		// The build container never gets the signing keys
+		for _, mount := range mounts {
+			if keys[mount.Name] || mount.Name == nixCacheVolume && !mount.ReadOnly {
+				t.Errorf("trusted %v: unexpected build mount %v", trusted, mount)
+			}
+		}
+
+		container, err := r.nixOutputsContainer(testRevision(testProject()), v1beta1.CellStatus{Job: "app-0123abc-default"}, nix, trusted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signed = 0
+		for _, mount := range container.VolumeMounts {
+			if keys[mount.Name] {
+				signed++
+			}
+		}
+		if trusted && signed != 2 || !trusted && signed != 0 {
+			t.Errorf("trusted %v: unexpected mounts %v", trusted, container.VolumeMounts)
+		}
+	}
+}
+
+var parseNixOutputsTests = []struct {
+	name     string
+	contents string
+	outputs  []v1beta1.NixOutput
+}{
+	{
+		name:     "empty",
+		contents: "",
+		outputs:  []v1beta1.NixOutput{},
+	},
+	{
+		name:     "outputs",
+		contents: "/nix/store/abc-app sha256:0123\n/nix/store/def-doc sha256:4567\n",
+		outputs: []v1beta1.NixOutput{
+			{Cell: "default", Path: "/nix/store/abc-app", NarHash: "sha256:0123"},
+			{Cell: "default", Path: "/nix/store/def-doc", NarHash: "sha256:4567"},
+		},
+	},
+	{
+		name:     "uploaded outputs",
+		contents: "/nix/store/abc-app sha256:0123\ncache s3://cache\n",
+		outputs: []v1beta1.NixOutput{
+			{Cell: "default", Path: "/nix/store/abc-app", NarHash: "sha256:0123", Cache: "s3://cache"},
+		},
+	},
+	{
+		name:     "invalid lines",
+		contents: "/tmp/app sha256:0123\n/nix/store/abc-app\n/nix/store/abc-app md5:0123\n",
+		outputs:  []v1beta1.NixOutput{},
+	},
+}
+
+func TestParseNixOutputs(t *testing.T) {
+	for _, test := range parseNixOutputsTests {
+		t.Run(test.name, func(t *testing.T) {
+			outputs := parseNixOutputs(test.contents, "default")
+			if !reflect.DeepEqual(outputs, test.outputs) {
+				t.Errorf("expected %v, got %v", test.outputs, outputs)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"sigs.k8s.io/yaml"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// revisionPipeline returns the pipeline a revision was created with, falling
+// back to the project pipeline for revisions created by hand.
+func revisionPipeline(project v1beta1.Project, revision v1beta1.Revision) v1beta1.Pipeline {
+	if revision.Spec.Pipeline != nil {
+		return *revision.Spec.Pipeline
+	}
+
+	return project.Spec.Pipeline
+}
+
+// resolvePipeline returns the pipeline to build commit with: the pipeline
+// file of the project when the commit contains it, or the project pipeline.
+func (r *ProjectReconciler) resolvePipeline(ctx context.Context, commit *object.Commit) (v1beta1.Pipeline, error) {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+
+	if project.Spec.PipelinePath == "" {
+		return project.Spec.Pipeline, nil
+	}
+
+	remote, err := fetchRemote(ctx, r.Client, project)
+	if err != nil {
+		return v1beta1.Pipeline{}, err
+	}
+
+	contents, err := r.Mirrors.ReadFile(ctx, remote, commit.Hash.String(), project.Spec.PipelinePath)
+	if err == object.ErrFileNotFound {
+		return project.Spec.Pipeline, nil
+	} else if err != nil {
+		return v1beta1.Pipeline{}, err
+	}
+
+	var pipeline v1beta1.Pipeline
+	if err := yaml.UnmarshalStrict(contents, &pipeline); err != nil {
+		return v1beta1.Pipeline{}, err
+	}
+
+	// Pipeline files are not seen by the webhooks
+	if err := v1beta1.ValidatePipeline(pipeline); err != nil {
+		return v1beta1.Pipeline{}, err
+	}
+
+	return pipeline, nil
+}
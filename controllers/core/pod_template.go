@@ -0,0 +1,52 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// applyPodTemplate merges pod template overrides into the spec of a build
+// pod whose first container is the build container
+func applyPodTemplate(spec *corev1.PodSpec, podTemplate v1beta1.PodTemplate) {
+	if podTemplate.Resources.Limits != nil || podTemplate.Resources.Requests != nil {
+		spec.Containers[0].Resources = podTemplate.Resources
+	}
+
+	spec.NodeSelector = podTemplate.NodeSelector
+	spec.Tolerations = podTemplate.Tolerations
+	spec.Affinity = podTemplate.Affinity
+	spec.ServiceAccountName = podTemplate.ServiceAccountName
+	spec.PriorityClassName = podTemplate.PriorityClassName
+	spec.ImagePullSecrets = podTemplate.ImagePullSecrets
+
+	applySecurityContext(spec, podTemplate.SecurityContext)
+}
+
+// applySecurityContext runs the build container as the user of a pod
+// security context. The sidecars building images and copying Nix outputs run
+// as root, so only the groups apply to the whole pod.
+func applySecurityContext(spec *corev1.PodSpec, securityContext *corev1.PodSecurityContext) {
+	if securityContext == nil {
+		spec.SecurityContext = nil
+		return
+	}
+
+	spec.SecurityContext = &corev1.PodSecurityContext{
+		SupplementalGroups: securityContext.SupplementalGroups,
+		FSGroup:            securityContext.FSGroup,
+	}
+
+	build := &spec.Containers[0]
+	if build.SecurityContext == nil {
+		build.SecurityContext = &corev1.SecurityContext{}
+	}
+	build.SecurityContext.RunAsUser = securityContext.RunAsUser
+	build.SecurityContext.RunAsGroup = securityContext.RunAsGroup
+	build.SecurityContext.RunAsNonRoot = securityContext.RunAsNonRoot
+	build.SecurityContext.WindowsOptions = securityContext.WindowsOptions
+}
@@ -0,0 +1,74 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+func TestApplyPodTemplate(t *testing.T) {
+	defaults := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+	}
+
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "build", Resources: defaults}}}
+	applyPodTemplate(&spec, v1beta1.PodTemplate{ServiceAccountName: "builder"})
+
+	if !spec.Containers[0].Resources.Requests.Memory().Equal(resource.MustParse("256Mi")) {
+		t.Errorf("expected the build resources to be kept, got %v", spec.Containers[0].Resources)
+	}
+	if spec.ServiceAccountName != "builder" {
+		t.Errorf("expected service account builder, got %s", spec.ServiceAccountName)
+	}
+
+	applyPodTemplate(&spec, v1beta1.PodTemplate{Resources: corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+	}})
+
+	if spec.Containers[0].Resources.Requests != nil {
+		t.Errorf("expected the build resources to be replaced, got %v", spec.Containers[0].Resources)
+	}
+	if !spec.Containers[0].Resources.Limits.Cpu().Equal(resource.MustParse("2")) {
+		t.Errorf("expected a CPU limit of 2, got %v", spec.Containers[0].Resources)
+	}
+}
+
+func TestApplySecurityContext(t *testing.T) {
+	nonRoot := true
+	user := int64(1000)
+	group := int64(2000)
+
+	spec := corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "build"},
+		{Name: imageContainerPrefix + "app"},
+		{Name: nixOutputsContainer},
+	}}
+	applyPodTemplate(&spec, v1beta1.PodTemplate{SecurityContext: &corev1.PodSecurityContext{
+		RunAsNonRoot: &nonRoot,
+		RunAsUser:    &user,
+		FSGroup:      &group,
+	}})
+
+	build := spec.Containers[0].SecurityContext
+	if build == nil || !*build.RunAsNonRoot || *build.RunAsUser != user {
+		t.Errorf("expected the build container to run as user %d, got %v", user, build)
+	}
+	if spec.SecurityContext.RunAsNonRoot != nil || spec.SecurityContext.RunAsUser != nil {
+		t.Errorf("expected the pod to leave the user of sidecars, got %v", spec.SecurityContext)
+	}
+	if spec.SecurityContext.FSGroup == nil || *spec.SecurityContext.FSGroup != group {
+		t.Errorf("expected the pod to have group %d, got %v", group, spec.SecurityContext)
+	}
+	for _, sidecar := range spec.Containers[1:] {
+		if sidecar.SecurityContext != nil {
+			t.Errorf("expected %s to run as its image user, got %v", sidecar.Name, sidecar.SecurityContext)
+		}
+	}
+}
@@ -7,31 +7,44 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"sort"
 	"strings"
 
-	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/mirror"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ownerKey indexes revisions by the name of their project
+const ownerKey = ".metadata.controller"
+
+// templateKey indexes projects by the name of their template
+const templateKey = ".spec.templateRef.name"
+
 // ProjectReconciler reconciles a Project object
 type ProjectReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+	Mirrors *mirror.Cache
 }
 
 // +kubebuilder:rbac:groups=core.hedron.build,resources=projects,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core.hedron.build,resources=projects/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core.hedron.build,resources=projecttemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 func (r *ProjectReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error) {
 	requestCtx := context.WithValue(context.Background(), contextKeyRequest, request)
@@ -39,34 +52,66 @@ func (r *ProjectReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error)
 	project, err := r.fetchProject(requestCtx)
 	if err != nil && strings.Contains(err.Error(), "not found") {
 		r.Log.Info("Project no longer exists", "project", request.NamespacedName)
+
+		return ctrl.Result{}, nil
 	} else if err != nil {
 		r.Log.Error(err, "Failed to fetch project")
 
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	project, template, err := v1beta1.ResolveProject(requestCtx, r.Client, project)
+	if err != nil {
+		r.Log.Error(err, "Failed to fetch project template")
+
+		// Projects are reconciled again once their template is created
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
 	projectCtx := context.WithValue(requestCtx, contextKeyProject, project)
+	projectCtx = context.WithValue(projectCtx, contextKeyProjectTemplate, template)
 
 	head, err := r.getRepoHead(projectCtx)
 	if err != nil {
 		r.Log.Error(err, "Failed to get repository HEAD")
+
+		return ctrl.Result{}, err
 	}
 
-	_, err = r.fetchRevision(projectCtx, head.Hash())
+	revision, err := r.fetchRevision(projectCtx, head.Hash)
 	if err != nil && strings.Contains(err.Error(), "not found") {
-		_, err = r.createRevision(projectCtx, head.Hash())
+		revision, err = r.createRevision(projectCtx, head)
 		if err != nil {
 			r.Log.Error(err, "Failed to create revision")
+
+			return ctrl.Result{}, err
 		}
 	} else if err != nil {
 		r.Log.Error(err, "Failed to fetch revision")
+
+		return ctrl.Result{}, err
+	}
+
+	if err := r.recordRevision(projectCtx, revision); err != nil {
+		r.Log.Error(err, "Failed to record revision")
+
+		return ctrl.Result{}, err
+	}
+
+	if project.Spec.Retention != nil {
+		if err := r.pruneRevisions(projectCtx, int(*project.Spec.Retention)); err != nil {
+			r.Log.Error(err, "Failed to prune revisions")
+		}
+	}
+
+	if project.Spec.PollInterval != nil {
+		return ctrl.Result{RequeueAfter: project.Spec.PollInterval.Duration}, nil
 	}
 
 	return ctrl.Result{}, nil
 }
 
 func (r *ProjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	ownerKey := ".metadata.controller"
 	apiGVStr := v1beta1.GroupVersion.String()
 
 	if err := mgr.GetFieldIndexer().IndexField(&v1beta1.Revision{}, ownerKey, func(object runtime.Object) []string {
@@ -87,15 +132,56 @@ func (r *ProjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	if err := mgr.GetFieldIndexer().IndexField(&v1beta1.Project{}, templateKey, func(object runtime.Object) []string {
+		project := object.(*v1beta1.Project)
+		if project.Spec.TemplateRef == nil {
+			return nil
+		}
+
+		return []string{project.Spec.TemplateRef.Name}
+	}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1beta1.Project{}).
 		Owns(&v1beta1.Revision{}).
+		Watches(&source.Kind{Type: &v1beta1.ProjectTemplate{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+				var projects v1beta1.ProjectList
+				if err := r.List(context.Background(), &projects, client.MatchingFields{templateKey: object.Meta.GetName()}); err != nil {
+					r.Log.Error(err, "Failed to list projects of template", "template", object.Meta.GetName())
+
+					return nil
+				}
+
+				requests := []reconcile.Request{}
+				for _, project := range projects.Items {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+						Namespace: project.Namespace,
+						Name:      project.Name,
+					}})
+				}
+
+				return requests
+			}),
+		}).
 		Complete(r)
 }
 
-func (r *ProjectReconciler) createRevision(ctx context.Context, gitHash plumbing.Hash) (v1beta1.Revision, error) {
+func (r *ProjectReconciler) createRevision(ctx context.Context, commit *object.Commit) (v1beta1.Revision, error) {
 	project := ctx.Value(contextKeyProject).(v1beta1.Project)
-	revisionName := fmt.Sprintf("%s-%s", project.Name, gitHash.String())
+	template := ctx.Value(contextKeyProjectTemplate).(*v1beta1.ProjectTemplate)
+	revisionName := fmt.Sprintf("%s-%s", project.Name, commit.Hash.String())
+
+	directives, skipReason := parseDirectives(commit.Message)
+
+	// The project in the context has the fields of its template, which are
+	// not written back
+	stored, err := r.fetchProject(ctx)
+	if err != nil {
+		return v1beta1.Revision{}, err
+	}
 
 	revision := v1beta1.Revision{
 		ObjectMeta: metav1.ObjectMeta{
@@ -103,19 +189,74 @@ func (r *ProjectReconciler) createRevision(ctx context.Context, gitHash plumbing
 			Name:      revisionName,
 		},
 		Spec: v1beta1.RevisionSpec{
-			ProjectRef: corev1.LocalObjectReference{Name: project.Name},
-			Revision:   gitHash.String(),
+			ProjectRef:  corev1.LocalObjectReference{Name: project.Name},
+			Revision:    commit.Hash.String(),
+			Ref:         project.Spec.Repository.Ref,
+			BuildNumber: stored.Status.BuildNumber + 1,
+			Directives:  directives,
 		},
 		Status: v1beta1.RevisionStatus{
 			State: "Pending",
 		},
 	}
 
-	if err := ctrl.SetControllerReference(&project, &revision, r.Scheme); err != nil {
+	if template != nil {
+		revision.Spec.Template = &v1beta1.TemplateReference{
+			Name:       template.Name,
+			Generation: template.Generation,
+		}
+	}
+
+	if skipReason != "" {
+		revision.Status.State = "Skipped"
+		revision.Status.Reason = skipReason
+	} else if pipeline, err := r.resolvePipeline(ctx, commit); err != nil {
+		revision.Status.State = "Failed"
+		revision.Status.Reason = fmt.Sprintf("Invalid pipeline: %s", err)
+	} else {
+		revision.Spec.Pipeline = &pipeline
+	}
+
+	if err := ctrl.SetControllerReference(&stored, &revision, r.Scheme); err != nil {
+		return revision, err
+	}
+
+	// Revisions are created without status, which only the status
+	// subresource sets
+	status := revision.Status
+	revision.Status = v1beta1.RevisionStatus{}
+	if err := r.Create(ctx, &revision); err != nil {
+		return revision, err
+	}
+
+	revision.Status = status
+	if err := r.Status().Update(ctx, &revision); err != nil {
+		if deleteErr := r.Delete(ctx, &revision); deleteErr != nil {
+			r.Log.Error(deleteErr, "Failed to delete uninitialized revision", "revision", revision.Name)
+		}
+
 		return revision, err
 	}
 
-	return revision, r.Create(ctx, &revision)
+	return revision, nil
+}
+
+// recordRevision advances the build number of the project to the one of its
+// latest revision, which is only allocated once the revision exists
+func (r *ProjectReconciler) recordRevision(ctx context.Context, revision v1beta1.Revision) error {
+	stored, err := r.fetchProject(ctx)
+	if err != nil {
+		return err
+	}
+
+	if stored.Status.BuildNumber >= revision.Spec.BuildNumber {
+		return nil
+	}
+
+	stored.Status.BuildNumber = revision.Spec.BuildNumber
+	stored.Status.LastRevision = revision.Name
+
+	return r.Update(ctx, &stored)
 }
 
 func (r *ProjectReconciler) fetchProject(ctx context.Context) (v1beta1.Project, error) {
@@ -138,24 +279,48 @@ func (r *ProjectReconciler) fetchRevision(ctx context.Context, gitHash plumbing.
 	}, &revision)
 }
 
-func (r *ProjectReconciler) getRepoHead(ctx context.Context) (*plumbing.Reference, error) {
+func (r *ProjectReconciler) getRepoHead(ctx context.Context) (*object.Commit, error) {
 	project := ctx.Value(contextKeyProject).(v1beta1.Project)
 
-	// Create temporary directory
-	tempDir, err := ioutil.TempDir("", fmt.Sprintf("hedron-%s-", project.Name))
+	remote, err := fetchRemote(ctx, r.Client, project)
 	if err != nil {
-		r.Log.Error(err, "Failed to create temporary directory", "tempDir", tempDir)
+		return nil, err
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Clone project repository
-	repo, err := git.PlainClone(tempDir, false, &git.CloneOptions{
-		URL:           project.Spec.Repository.URL,
-		ReferenceName: plumbing.ReferenceName(project.Spec.Repository.Ref),
+	return r.Mirrors.Resolve(ctx, remote, project.Spec.Repository.Ref)
+}
+
+// pruneRevisions deletes the finished revisions of a project but the retained
+// ones with the highest build numbers
+func (r *ProjectReconciler) pruneRevisions(ctx context.Context, retention int) error {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+
+	var revisions v1beta1.RevisionList
+	if err := r.List(ctx, &revisions, client.InNamespace(project.Namespace), client.MatchingFields{ownerKey: project.Name}); err != nil {
+		return err
+	}
+
+	finished := []v1beta1.Revision{}
+	for _, revision := range revisions.Items {
+		switch revision.Status.State {
+		case "Failed", "Succeeded", "Skipped", "Rejected":
+			finished = append(finished, revision)
+		}
+	}
+	if len(finished) <= retention {
+		return nil
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Spec.BuildNumber > finished[j].Spec.BuildNumber
 	})
-	if err != nil {
-		r.Log.Error(err, "Failed to clone repository")
+
+	for _, revision := range finished[retention:] {
+		if err := r.Delete(ctx, &revision, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.Log.Info("Deleted revision past retention", "revision", revision.Name)
 	}
 
-	return repo.Head()
+	return nil
 }
@@ -0,0 +1,120 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/mirror"
+)
+
+const projectDefaulterPath = "/mutate-core-hedron-build-v1beta1-project"
+
+// defaultBranchTimeout bounds listing the remote refs, well within the
+// timeout of admission webhooks
+const defaultBranchTimeout = 5 * time.Second
+
+// ProjectDefaulter defaults the fields of projects to the project defaults
+// ConfigMap, and their ref to the default branch of their repository
+type ProjectDefaulter struct {
+	Client   client.Client
+	Log      logr.Logger
+	Defaults types.NamespacedName
+
+	decoder *admission.Decoder
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-core-hedron-build-v1beta1-project,mutating=true,failurePolicy=fail,groups=core.hedron.build,resources=projects,versions=v1beta1,name=mproject.kb.io
+
+func (d *ProjectDefaulter) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(projectDefaulterPath, &webhook.Admission{Handler: d})
+
+	return nil
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (d *ProjectDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+
+	return nil
+}
+
+// Handle implements admission.Handler
+func (d *ProjectDefaulter) Handle(ctx context.Context, request admission.Request) admission.Response {
+	var project v1beta1.Project
+	if err := d.decoder.Decode(request, &project); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	defaults, err := d.fetchDefaults(ctx)
+	if err != nil {
+		d.Log.Error(err, "Failed to fetch project defaults")
+
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	project.ApplyDefaults(defaults)
+
+	if project.Spec.Repository.Ref == "" && project.Spec.Repository.URL != "" {
+		project.Spec.Repository.Ref = d.defaultBranch(ctx, project, request.Namespace)
+	}
+
+	marshaled, err := json.Marshal(project)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(request.Object.Raw, marshaled)
+}
+
+// fetchDefaults returns the project defaults, which are empty when the
+// ConfigMap does not exist
+func (d *ProjectDefaulter) fetchDefaults(ctx context.Context) (v1beta1.ProjectDefaults, error) {
+	var configMap corev1.ConfigMap
+
+	err := d.Client.Get(ctx, d.Defaults, &configMap)
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return v1beta1.ProjectDefaults{}, nil
+	} else if err != nil {
+		return v1beta1.ProjectDefaults{}, err
+	}
+
+	return v1beta1.ParseProjectDefaults(configMap.Data)
+}
+
+// defaultBranch returns the ref HEAD of the project repository points to,
+// or v1beta1.DefaultRef when it cannot be resolved. Projects being created
+// may not have their namespace set yet.
+func (d *ProjectDefaulter) defaultBranch(ctx context.Context, project v1beta1.Project, namespace string) string {
+	project.Namespace = namespace
+
+	remote, err := fetchRemote(ctx, d.Client, project)
+	if err == nil {
+		listCtx, cancel := context.WithTimeout(ctx, defaultBranchTimeout)
+		defer cancel()
+
+		var ref string
+		if ref, err = mirror.DefaultBranch(listCtx, remote); err == nil {
+			return ref
+		}
+	}
+
+	d.Log.Info("Failed to resolve default branch", "project", project.Name, "ref", v1beta1.DefaultRef, "error", err.Error())
+
+	return v1beta1.DefaultRef
+}
@@ -0,0 +1,30 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// templateChange returns why the template a project now resolves to differs
+// from the template a revision was created with, or "" if it does not
+func templateChange(reference *v1beta1.TemplateReference, template *v1beta1.ProjectTemplate) string {
+	switch {
+	case reference == nil && template == nil:
+		return ""
+	case reference == nil:
+		return fmt.Sprintf("Project template %s was set during the build", template.Name)
+	case template == nil:
+		return fmt.Sprintf("Project template %s was unset during the build", reference.Name)
+	case reference.Name != template.Name:
+		return fmt.Sprintf("Project template changed from %s to %s during the build", reference.Name, template.Name)
+	case reference.Generation != template.Generation:
+		return fmt.Sprintf("Project template %s changed from generation %d to %d during the build", reference.Name, reference.Generation, template.Generation)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+var templateChangeTests = []struct {
+	name      string
+	reference *v1beta1.TemplateReference
+	template  *v1beta1.ProjectTemplate
+	reason    string
+}{
+	{
+		name: "no template",
+	},
+	{
+		name:      "same generation",
+		reference: &v1beta1.TemplateReference{Name: "go", Generation: 2},
+		template:  &v1beta1.ProjectTemplate{ObjectMeta: metav1.ObjectMeta{Name: "go", Generation: 2}},
+	},
+	{
+		name:      "new generation",
+		reference: &v1beta1.TemplateReference{Name: "go", Generation: 2},
+		template:  &v1beta1.ProjectTemplate{ObjectMeta: metav1.ObjectMeta{Name: "go", Generation: 3}},
+		reason:    "Project template go changed from generation 2 to 3 during the build",
+	},
+	{
+		name:      "other template",
+		reference: &v1beta1.TemplateReference{Name: "go", Generation: 2},
+		template:  &v1beta1.ProjectTemplate{ObjectMeta: metav1.ObjectMeta{Name: "rust", Generation: 2}},
+		reason:    "Project template changed from go to rust during the build",
+	},
+	{
+		name:     "template set",
+		template: &v1beta1.ProjectTemplate{ObjectMeta: metav1.ObjectMeta{Name: "go", Generation: 1}},
+		reason:   "Project template go was set during the build",
+	},
+	{
+		name:      "template unset",
+		reference: &v1beta1.TemplateReference{Name: "go", Generation: 2},
+		reason:    "Project template go was unset during the build",
+	},
+}
+
+func TestTemplateChange(t *testing.T) {
+	for _, test := range templateChangeTests {
+		t.Run(test.name, func(t *testing.T) {
+			if reason := templateChange(test.reference, test.template); reason != test.reason {
+				t.Errorf("expected %q, got %q", test.reason, reason)
+			}
+		})
+	}
+}
+
+func TestReconcileTemplateChange(t *testing.T) {
+	template := v1beta1.ProjectTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "go", Generation: 3},
+		Spec:       v1beta1.ProjectTemplateSpec{Image: v1beta1.Image{Name: "golang"}},
+	}
+
+	project := testProject()
+	project.Spec.TemplateRef = &corev1.LocalObjectReference{Name: template.Name}
+	revision := testRevision(project)
+	revision.Spec.Template = &v1beta1.TemplateReference{Name: template.Name, Generation: 2}
+	revision.Status = v1beta1.RevisionStatus{State: "Pending", Cells: []v1beta1.CellStatus{
+		{Name: "go=1.14", Job: revision.Name + "-0", State: "Pending"},
+		{Name: "go=1.15", State: "Pending"},
+	}}
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: revision.Namespace, Name: revision.Name + "-0"},
+		Status:     batchv1.JobStatus{Active: 1},
+	}
+
+	reconciler := RevisionReconciler{
+		Client: fake.NewFakeClientWithScheme(testScheme(t), &template, &project, &revision, &job),
+		Log:    logf.NullLogger{},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: revision.Namespace, Name: revision.Name}}
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	var reconciled v1beta1.Revision
+	if err := reconciler.Get(context.Background(), request.NamespacedName, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.Status.State != "Pending" {
+		t.Errorf("expected the revision to keep running, got %v", reconciled.Status)
+	}
+
+	started, pending := reconciled.Status.Cells[0], reconciled.Status.Cells[1]
+	if started.State != "Pending" {
+		t.Errorf("expected the started cell to keep running, got %v", started)
+	}
+	if pending.State != "Failed" || pending.Reason != "Project template go changed from generation 2 to 3 during the build" {
+		t.Errorf("expected the cell without a job to fail, got %v", pending)
+	}
+
+	var jobs batchv1.JobList
+	if err := reconciler.List(context.Background(), &jobs, client.InNamespace(revision.Namespace)); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs.Items) != 1 {
+		t.Errorf("expected no job to be created, got %d jobs", len(jobs.Items))
+	}
+}
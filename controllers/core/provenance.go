@@ -0,0 +1,175 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/provenance"
+	"github.com/thmzlt/hedron/pkg/signing"
+)
+
+// reconcileProvenance stores the signed provenance of a successful trusted
+// revision and returns its status. Only the artifacts recorded by the server
+// are listed as subjects.
+func (r *RevisionReconciler) reconcileProvenance(ctx context.Context, settings v1beta1.Provenance) (*v1beta1.ArtifactStatus, error) {
+	if r.Store == nil {
+		return nil, nil
+	}
+
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	// The project key vouches for trusted builds only
+	if !revision.Trusted(project) {
+		return nil, nil
+	}
+
+	artifacts, err := r.recordedArtifacts(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKey := settings.SigningKey
+	keyData, err := fetchKey(ctx, r.Client, project.Namespace, v1beta1.KeySource{SecretKeyRef: &signingKey})
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := signing.ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := r.provenanceStatement(project, revision, artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := signing.SignEnvelope(signer, provenance.PayloadType, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+
+	key, err := provenance.Key(revision.Namespace, revision.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := r.Store.Put(ctx, key, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	r.Log.Info("Stored provenance", "revision", revision.Name, "subjects", len(statement.Subject))
+
+	return &v1beta1.ArtifactStatus{
+		Name:   provenance.Name,
+		Job:    revision.Name,
+		Size:   object.Size,
+		Digest: "sha256:" + hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// provenanceStatement describes the build of a revision, its artifacts and
+// its images
+func (r *RevisionReconciler) provenanceStatement(project v1beta1.Project, revision v1beta1.Revision, artifacts []v1beta1.ArtifactStatus) (provenance.Statement, error) {
+	subjects := []provenance.Subject{}
+	for _, artifact := range artifacts {
+		if digest, ok := sha256Digest(artifact.Digest); ok {
+			subjects = append(subjects, provenance.Subject{Name: artifact.Job + "/" + artifact.Name, Digest: digest})
+		}
+	}
+	for _, image := range revision.Status.Images {
+		digest, ok := sha256Digest(image.Digest)
+		if !ok {
+			continue
+		}
+
+		for _, destination := range image.Destinations {
+			subjects = append(subjects, provenance.Subject{Name: imageRepository(destination), Digest: digest})
+		}
+	}
+
+	pipelineDigest, err := provenance.SHA256(revisionPipeline(project, revision))
+	if err != nil {
+		return provenance.Statement{}, err
+	}
+
+	source := "git+" + project.Spec.Repository.URL
+	if revision.Spec.Ref != "" {
+		source += "@" + revision.Spec.Ref
+	}
+	commit := provenance.DigestSet{"sha1": revision.Spec.Revision}
+
+	environment := map[string]interface{}{
+		"namespace":      revision.Namespace,
+		"project":        project.Name,
+		"revision":       revision.Name,
+		"buildNumber":    revision.Spec.BuildNumber,
+		"pipelineDigest": pipelineDigest,
+	}
+	if revision.Spec.PullRequest != nil {
+		environment["pullRequest"] = revision.Spec.PullRequest.Number
+	}
+
+	started := revision.CreationTimestamp.UTC()
+	finished := time.Now().UTC()
+
+	return provenance.NewStatement(subjects, provenance.Predicate{
+		Builder:   provenance.Builder{ID: r.BuilderID},
+		BuildType: provenance.BuildType,
+		Invocation: provenance.Invocation{
+			ConfigSource: provenance.ConfigSource{URI: source, Digest: commit, EntryPoint: project.Spec.PipelinePath},
+			Parameters:   revision.Spec.Directives,
+			Environment:  environment,
+		},
+		Metadata: provenance.Metadata{
+			BuildInvocationID: string(revision.UID),
+			BuildStartedOn:    &started,
+			BuildFinishedOn:   &finished,
+			Completeness:      provenance.Completeness{Parameters: true},
+		},
+		Materials: []provenance.Material{{URI: source, Digest: commit}},
+	}), nil
+}
+
+// sha256Digest turns a "sha256:<hex>" digest into a digest set
+func sha256Digest(digest string) (provenance.DigestSet, bool) {
+	if !digestPattern.MatchString(digest) {
+		return nil, false
+	}
+
+	return provenance.DigestSet{"sha256": strings.TrimPrefix(digest, "sha256:")}, true
+}
+
+// imageRepository strips the tag from an image reference
+func imageRepository(reference string) string {
+	slash := strings.LastIndex(reference, "/")
+	if colon := strings.LastIndex(reference, ":"); colon > slash {
+		return reference[:colon]
+	}
+
+	return reference
+}
@@ -0,0 +1,149 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/provenance"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+var imageRepositoryTests = []struct {
+	reference  string
+	repository string
+}{
+	{reference: "registry.example.com/app:1.0", repository: "registry.example.com/app"},
+	{reference: "registry.example.com:5000/app", repository: "registry.example.com:5000/app"},
+	{reference: "registry.example.com:5000/app:latest", repository: "registry.example.com:5000/app"},
+	{reference: "app", repository: "app"},
+}
+
+func TestImageRepository(t *testing.T) {
+	for _, test := range imageRepositoryTests {
+		if repository := imageRepository(test.reference); repository != test.repository {
+			t.Errorf("%s: expected %s, got %s", test.reference, test.repository, repository)
+		}
+	}
+}
+
+var sha256DigestTests = []struct {
+	digest string
+	valid  bool
+}{
+	{digest: testDigest, valid: true},
+	{digest: "sha256:0123", valid: false},
+	{digest: "sha512:" + testDigest[len("sha256:"):], valid: false},
+	{digest: "", valid: false},
+}
+
+func TestSHA256Digest(t *testing.T) {
+	for _, test := range sha256DigestTests {
+		digest, ok := sha256Digest(test.digest)
+		if ok != test.valid {
+			t.Errorf("%q: expected valid %v", test.digest, test.valid)
+		}
+		if ok && digest["sha256"] != test.digest[len("sha256:"):] {
+			t.Errorf("%q: unexpected digest set %v", test.digest, digest)
+		}
+	}
+}
+
+func TestProvenanceStatement(t *testing.T) {
+	r := RevisionReconciler{BuilderID: "https://example.com/builder"}
+
+	project := testProject()
+	project.Spec.PipelinePath = "hedron.yaml"
+
+	revision := testRevision(project)
+	revision.UID = "revision-uid"
+	revision.Spec.PullRequest = &v1beta1.PullRequest{Number: 12, SourceURL: project.Spec.Repository.URL}
+	revision.Spec.Directives = map[string]string{"go": "1.15"}
+	artifacts := []v1beta1.ArtifactStatus{
+		{Name: "app.tar.gz", Job: "app-0123abc-default", Digest: testDigest},
+		{Name: "truncated", Job: "app-0123abc-default", Digest: "sha256:0123"},
+	}
+	revision.Status.Images = []v1beta1.ImageStatus{
+		{Name: "app", Destinations: []string{"registry.example.com/app:1.0", "registry.example.com/app:latest"}, Digest: testDigest},
+	}
+
+	statement, err := r.provenanceStatement(project, revision, artifacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := provenance.DigestSet{"sha256": testDigest[len("sha256:"):]}
+	subjects := []provenance.Subject{
+		{Name: "app-0123abc-default/app.tar.gz", Digest: digest},
+		{Name: "registry.example.com/app", Digest: digest},
+		{Name: "registry.example.com/app", Digest: digest},
+	}
+	if !reflect.DeepEqual(statement.Subject, subjects) {
+		t.Errorf("expected subjects %v, got %v", subjects, statement.Subject)
+	}
+
+	predicate := statement.Predicate
+	if predicate.Builder.ID != r.BuilderID {
+		t.Errorf("unexpected builder %s", predicate.Builder.ID)
+	}
+
+	source := provenance.ConfigSource{
+		URI:        "git+https://example.com/app.git@refs/heads/main",
+		Digest:     provenance.DigestSet{"sha1": "0123abc"},
+		EntryPoint: "hedron.yaml",
+	}
+	if !reflect.DeepEqual(predicate.Invocation.ConfigSource, source) {
+		t.Errorf("expected config source %v, got %v", source, predicate.Invocation.ConfigSource)
+	}
+	if !reflect.DeepEqual(predicate.Invocation.Parameters, revision.Spec.Directives) {
+		t.Errorf("unexpected parameters %v", predicate.Invocation.Parameters)
+	}
+
+	environment := predicate.Invocation.Environment
+	if environment["pullRequest"] != int64(12) || environment["buildNumber"] != int64(7) || environment["revision"] != "app-0123abc" {
+		t.Errorf("unexpected environment %v", environment)
+	}
+	if predicate.Metadata.BuildInvocationID != "revision-uid" {
+		t.Errorf("unexpected invocation ID %s", predicate.Metadata.BuildInvocationID)
+	}
+}
+
+func TestReconcileProvenanceUntrusted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hedron-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	reconciler := RevisionReconciler{
+		Client: fake.NewFakeClientWithScheme(clientgoscheme.Scheme),
+		Log:    logf.NullLogger{},
+		Store:  &storage.FileStore{Dir: dir},
+	}
+
+	project := testProject()
+	revision := testRevision(project)
+	revision.OwnerReferences = nil
+	revision.Status.Artifacts = []v1beta1.ArtifactStatus{{Name: "app.tar.gz", Job: "app-0123abc-build", Digest: testDigest}}
+
+	ctx := context.WithValue(context.Background(), contextKeyProject, project)
+	ctx = context.WithValue(ctx, contextKeyRevision, revision)
+
+	settings := v1beta1.Provenance{SigningKey: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "provenance"}, Key: "key.pem"}}
+	status, err := reconciler.reconcileProvenance(ctx, settings)
+	if err != nil || status != nil {
+		t.Errorf("expected no provenance for an untrusted revision, got %v and %v", status, err)
+	}
+}
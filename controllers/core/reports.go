@@ -0,0 +1,118 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/report"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// maxTestFailures is the number of failed tests listed on revisions
+const maxTestFailures = 10
+
+// collectReports parses the test reports uploaded by the pod of a finished
+// cell and stores their per-test results
+func (r *RevisionReconciler) collectReports(ctx context.Context, reports []v1beta1.Report) ([]report.Result, error) {
+	if r.Store == nil {
+		return nil, nil
+	}
+
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	results := []report.Result{}
+	for _, testReport := range reports {
+		prefix, err := storage.Key(revision.Namespace, revision.Name, "reports", cell.Job, testReport.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		objects, err := r.Store.List(ctx, prefix+"/")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			reader, err := r.Store.Get(ctx, object.Key)
+			if err != nil {
+				return nil, err
+			}
+
+			parsed, err := report.Parse(report.Format(testReport.Format), reader)
+			reader.Close()
+			if err != nil {
+				// A malformed file does not invalidate the other ones
+				r.Log.Info("Ignoring test report", "key", object.Key, "error", err.Error())
+				continue
+			}
+
+			results = append(results, parsed...)
+		}
+	}
+
+	key, err := report.ResultsKey(revision.Namespace, revision.Name, cell.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Store.Put(ctx, key, bytes.NewReader(contents)); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// addTestResults adds the results of the reports of a cell to the test
+// summary of a revision
+func addTestResults(summary *v1beta1.TestSummary, cell v1beta1.CellStatus, results []report.Result) *v1beta1.TestSummary {
+	if len(results) == 0 {
+		return summary
+	}
+	if summary == nil {
+		summary = &v1beta1.TestSummary{}
+	}
+
+	totals := report.Summarize(results)
+	summary.Passed += totals.Passed
+	summary.Failed += totals.Failed
+	summary.Skipped += totals.Skipped
+	summary.Duration = metav1.Duration{Duration: summary.Duration.Duration + totals.Duration}
+
+	for _, result := range results {
+		if len(summary.Failures) >= maxTestFailures {
+			break
+		}
+		if result.Status != report.Failed {
+			continue
+		}
+
+		// Only keep the gist of failures, the whole output is in the logs
+		message := result.Message
+		if len(message) > 512 {
+			message = message[:512]
+		}
+
+		summary.Failures = append(summary.Failures, v1beta1.TestFailure{
+			Cell:    cell.Name,
+			Suite:   result.Suite,
+			Name:    result.Name,
+			Message: strings.TrimSpace(message),
+		})
+	}
+
+	return summary
+}
@@ -0,0 +1,116 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/report"
+)
+
+// cellResults are the test results of a cell
+type cellResults struct {
+	cell    string
+	results []report.Result
+}
+
+var addTestResultsTests = []struct {
+	name    string
+	cells   []cellResults
+	summary *v1beta1.TestSummary
+}{
+	{
+		name:  "no results",
+		cells: []cellResults{{cell: "build"}},
+	},
+	{
+		name: "single cell",
+		cells: []cellResults{{cell: "build", results: []report.Result{
+			{Suite: "api", Name: "TestCreate", Status: report.Passed, Duration: time.Second},
+			{Suite: "api", Name: "TestDelete", Status: report.Failed, Duration: time.Second, Message: "  expected 204\n"},
+			{Suite: "api", Name: "TestUpdate", Status: report.Skipped},
+		}}},
+		summary: &v1beta1.TestSummary{
+			Passed:   1,
+			Failed:   1,
+			Skipped:  1,
+			Duration: metav1.Duration{Duration: 2 * time.Second},
+			Failures: []v1beta1.TestFailure{{Cell: "build", Suite: "api", Name: "TestDelete", Message: "expected 204"}},
+		},
+	},
+	{
+		name: "suite repeated across cells",
+		cells: []cellResults{
+			{cell: "go=1.14", results: []report.Result{
+				{Suite: "api", Name: "TestCreate", Status: report.Failed, Duration: time.Second},
+			}},
+			{cell: "go=1.15", results: []report.Result{
+				{Suite: "api", Name: "TestCreate", Status: report.Failed, Duration: time.Second},
+				{Suite: "api", Name: "TestDelete", Status: report.Passed, Duration: time.Second},
+			}},
+		},
+		summary: &v1beta1.TestSummary{
+			Passed:   1,
+			Failed:   2,
+			Duration: metav1.Duration{Duration: 3 * time.Second},
+			Failures: []v1beta1.TestFailure{
+				{Cell: "go=1.14", Suite: "api", Name: "TestCreate"},
+				{Cell: "go=1.15", Suite: "api", Name: "TestCreate"},
+			},
+		},
+	},
+	{
+		name: "suite repeated in a cell",
+		cells: []cellResults{{cell: "build", results: []report.Result{
+			{Suite: "api", Name: "TestCreate", Status: report.Passed},
+			{Suite: "api", Name: "TestDelete", Status: report.Passed},
+			{Suite: "api", Name: "TestCreate", Status: report.Failed},
+		}}},
+		summary: &v1beta1.TestSummary{
+			Passed:   2,
+			Failed:   1,
+			Failures: []v1beta1.TestFailure{{Cell: "build", Suite: "api", Name: "TestCreate"}},
+		},
+	},
+}
+
+func TestAddTestResults(t *testing.T) {
+	for _, test := range addTestResultsTests {
+		t.Run(test.name, func(t *testing.T) {
+			var summary *v1beta1.TestSummary
+			for _, cell := range test.cells {
+				summary = addTestResults(summary, v1beta1.CellStatus{Name: cell.cell}, cell.results)
+			}
+
+			if !reflect.DeepEqual(summary, test.summary) {
+				t.Errorf("expected %v, got %v", test.summary, summary)
+			}
+		})
+	}
+}
+
+func TestAddTestResultsLimits(t *testing.T) {
+	results := []report.Result{}
+	for i := 0; i < maxTestFailures+5; i++ {
+		results = append(results, report.Result{Name: "TestFlaky", Status: report.Failed, Message: strings.Repeat("x", 1024)})
+	}
+
+	summary := addTestResults(nil, v1beta1.CellStatus{Name: "build"}, results)
+	if summary.Failed != int64(len(results)) {
+		t.Errorf("expected %d failed tests, got %d", len(results), summary.Failed)
+	}
+	if len(summary.Failures) != maxTestFailures {
+		t.Errorf("expected %d failures, got %d", maxTestFailures, len(summary.Failures))
+	}
+	if len(summary.Failures[0].Message) != 512 {
+		t.Errorf("expected the message to be truncated, got %d bytes", len(summary.Failures[0].Message))
+	}
+}
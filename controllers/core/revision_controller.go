@@ -12,23 +12,68 @@ import (
 	"github.com/go-logr/logr"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	v1beta1 "github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/mirror"
+	"github.com/thmzlt/hedron/pkg/server"
+	"github.com/thmzlt/hedron/pkg/storage"
 )
 
+// revisionLabel is set on build pods to the name of their revision
+const revisionLabel = "hedron.build/revision"
+
 // RevisionReconciler reconciles a Revision object
 type RevisionReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	Mirrors     *mirror.Cache
+	RunnerImage string
+	Store       storage.Store
+	Clientset   kubernetes.Interface
+
+	// BuilderID identifies the manager in the provenance of revisions
+	BuilderID string
+
+	// NixImage builds projects with Nix unless they set an image
+	NixImage string
+
+	// ImageBuilderImage builds the images of pipelines, and must provide
+	// /kaniko/executor and /busybox/sh
+	ImageBuilderImage string
+
+	// SBOMImage generates SBOMs, and must provide /syft and /busybox/sh
+	SBOMImage string
+
+	// ServerURL is where build pods reach the manager server, which
+	// authenticates them with Tokens
+	ServerURL string
+	Tokens    server.Tokens
+
+	// pods lists the build pods, which are the only pods watched
+	pods corelisters.PodLister
 }
 
 // +kubebuilder:rbac:groups=core.hedron.build,resources=revisions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core.hedron.build,resources=revisions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 func (r *RevisionReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error) {
 	requestCtx := context.WithValue(context.Background(), contextKeyRequest, request)
@@ -46,43 +91,299 @@ func (r *RevisionReconciler) Reconcile(request ctrl.Request) (ctrl.Result, error
 
 	revisionCtx := context.WithValue(requestCtx, contextKeyRevision, revision)
 
-	if revision.Status.State == "Failed" || revision.Status.State == "Succeeded" {
+	if revision.Status.State == "Failed" || revision.Status.State == "Succeeded" || revision.Status.State == "Skipped" || revision.Status.State == "Rejected" {
 		state := strings.ToLower(string(revision.Status.State))
 		r.Log.Info(fmt.Sprintf("Revision is %s", state))
 
 		return ctrl.Result{}, nil
 	}
 
-	job, err := r.fetchJob(revisionCtx)
-	if err != nil && strings.Contains(err.Error(), "not found") {
-		job, err = r.createJob(revisionCtx)
-		if err != nil {
-			r.Log.Error(err, "Failed to create job")
-		}
-	} else if err != nil {
-		r.Log.Error(err, "Failed to fetch job")
+	// The project controller sets the state of revisions right after
+	// creating them
+	if revision.Status.State == "" {
+		r.Log.Info("Revision is not initialized yet")
+
+		return ctrl.Result{}, nil
 	}
 
-	if job.Status.Active == 1 {
-		revision.Status.State = "Pending"
+	project, err := r.fetchProject(revisionCtx)
+	if err != nil {
+		r.Log.Error(err, "Failed to fetch project")
+
+		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	if job.Status.Failed == 1 {
+
+	project, template, err := v1beta1.ResolveProject(revisionCtx, r.Client, project)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		r.Log.Error(err, "Failed to fetch project template")
+
+		return ctrl.Result{}, err
+	} else if err != nil {
+		// Cells cannot be built without the template of their project
+		reason := fmt.Sprintf("Project template %s no longer exists", project.Spec.TemplateRef.Name)
 		revision.Status.State = "Failed"
+		revision.Status.Reason = reason
+
+		if err = r.Status().Update(revisionCtx, &revision); err != nil {
+			r.Log.Error(err, "Failed to update revision state")
+
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Failed revision", "reason", reason)
+
+		return ctrl.Result{}, nil
 	}
-	if job.Status.Succeeded == 1 {
-		revision.Status.State = "Succeeded"
+
+	projectCtx := context.WithValue(revisionCtx, contextKeyProject, project)
+	pipeline := revisionPipeline(project, revision)
+
+	// Revisions are built with the template generation they were created
+	// with: once it changes, the cells that have a job finish with it and
+	// the other ones fail, leaving the new generation to new revisions
+	templateReason := templateChange(revision.Spec.Template, template)
+
+	if len(revision.Status.Cells) == 0 {
+		if len(project.Spec.TrustedKeys) > 0 {
+			reason, err := r.verifyCommit(projectCtx)
+			if err != nil {
+				r.Log.Error(err, "Failed to verify commit signature")
+
+				return ctrl.Result{}, err
+			}
+			if reason != "" {
+				revision.Status.State = "Rejected"
+				revision.Status.Reason = reason
+
+				if err = r.Status().Update(projectCtx, &revision); err != nil {
+					r.Log.Error(err, "Failed to update revision state")
+				}
+				r.Log.Info("Rejected revision", "reason", reason)
+
+				return ctrl.Result{}, nil
+			}
+		}
+
+		cells := expandMatrix(pipeline.Matrix)
+		revision.Status.Cells = selectCells(cells, revision.Spec.Directives)
+		if len(revision.Status.Cells) == 0 {
+			revision.Status.State = "Skipped"
+			revision.Status.Reason = "No matrix cell matches the commit directives"
+			if len(cells) == 0 {
+				revision.Status.Reason = "The matrix excludes every combination"
+			}
+
+			if err = r.Status().Update(projectCtx, &revision); err != nil {
+				r.Log.Error(err, "Failed to update revision state")
+
+				return ctrl.Result{}, err
+			}
+			r.Log.Info("Skipped revision", "reason", revision.Status.Reason)
+
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// retryErr requeues the revision once the progress of the other cells
+	// is saved
+	var retryErr error
+
+	for i := range revision.Status.Cells {
+		cell := &revision.Status.Cells[i]
+		if cell.State == "Failed" || cell.State == "Succeeded" {
+			continue
+		}
+		if cell.Job == "" {
+			cell.Job = cellJobName(revision, i)
+		}
+
+		cellCtx := context.WithValue(context.WithValue(projectCtx, contextKeyRevision, revision), contextKeyCell, *cell)
+
+		job, err := r.fetchJob(cellCtx)
+		if err != nil && strings.Contains(err.Error(), "not found") && templateReason != "" {
+			cell.State = "Failed"
+			cell.Reason = templateReason
+			continue
+		} else if err != nil && strings.Contains(err.Error(), "not found") {
+			job, err = r.createJob(cellCtx)
+			if _, ok := err.(specError); ok {
+				r.Log.Error(err, "Invalid job", "cell", cell.Name)
+
+				cell.State = "Failed"
+				cell.Reason = err.Error()
+				continue
+			} else if err != nil {
+				r.Log.Error(err, "Failed to create job", "cell", cell.Name)
+
+				retryErr = err
+				continue
+			}
+		} else if err != nil {
+			r.Log.Error(err, "Failed to fetch job", "cell", cell.Name)
+
+			retryErr = err
+			continue
+		}
+
+		if job.Status.Active > 0 {
+			cell.State = "Pending"
+		}
+		if job.Status.Failed > 0 {
+			cell.State = "Failed"
+		}
+		if job.Status.Succeeded > 0 {
+			cell.State = "Succeeded"
+		}
+
+		if cell.State == "Pending" && hasSidecars(project, pipeline) {
+			if err := r.reconcileBuildExit(cellCtx); err != nil {
+				r.Log.Error(err, "Failed to signal build exit", "cell", cell.Name)
+			}
+		}
+
+		servicesDone := false
+		if cell.State == "Pending" && len(pipeline.Services) > 0 {
+			state, reason, err := r.reconcileServices(cellCtx)
+			if err != nil {
+				r.Log.Error(err, "Failed to reconcile services", "cell", cell.Name)
+			}
+			if state != "" {
+				cell.State = state
+				cell.Reason = reason
+				servicesDone = true
+			}
+		}
+
+		if cell.State == "Failed" || cell.State == "Succeeded" {
+			if err := r.captureLogs(cellCtx); err != nil {
+				r.Log.Error(err, "Failed to capture logs", "cell", cell.Name)
+			}
+
+			// Artifacts and images are recorded together once both are
+			// collected, so that retries do not record them twice
+			var artifacts []v1beta1.ArtifactStatus
+			if hasArtifacts(pipeline) {
+				artifacts, err = r.collectArtifacts(cellCtx)
+			}
+			var images []v1beta1.ImageStatus
+			if err == nil && len(pipeline.Images) > 0 {
+				images, err = r.collectImages(cellCtx, pipeline.Images)
+				if err != nil && cell.State == "Failed" {
+					// The pods of failed jobs may be gone, e.g. past
+					// their deadline
+					r.Log.Error(err, "Failed to collect images", "cell", cell.Name)
+					err = nil
+				}
+			}
+			if err != nil {
+				r.Log.Error(err, "Failed to collect outputs", "cell", cell.Name)
+
+				// Keep the cell and its job open until its outputs are
+				// collected
+				cell.State = "Pending"
+				cell.Reason = ""
+				retryErr = err
+				continue
+			}
+			revision.Status.Artifacts = append(revision.Status.Artifacts, artifacts...)
+			revision.Status.Images = append(revision.Status.Images, images...)
+
+			if hasArtifacts(pipeline) {
+				sboms, err := r.collectSBOMs(cellCtx, artifacts)
+				if err != nil {
+					r.Log.Error(err, "Failed to collect SBOMs", "cell", cell.Name)
+				}
+				revision.Status.SBOMs = append(revision.Status.SBOMs, sboms...)
+			}
+
+			if len(pipeline.Reports) > 0 {
+				results, err := r.collectReports(cellCtx, pipeline.Reports)
+				if err != nil {
+					r.Log.Error(err, "Failed to collect test reports", "cell", cell.Name)
+				}
+				revision.Status.Tests = addTestResults(revision.Status.Tests, *cell, results)
+			}
+
+			if project.Spec.Nix != nil {
+				outputs, err := r.collectNixOutputs(cellCtx)
+				if err != nil {
+					r.Log.Error(err, "Failed to collect Nix outputs", "cell", cell.Name)
+				}
+				revision.Status.Nix = append(revision.Status.Nix, outputs...)
+			}
+		}
+
+		if servicesDone {
+			// Stop the services, which would otherwise keep the job running
+			if err := r.Delete(cellCtx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+				r.Log.Error(err, "Failed to delete job", "cell", cell.Name)
+			}
+		}
+	}
+
+	revision.Status.State = cellsState(revision.Status.Cells)
+
+	finished := revision.Status.State == "Succeeded" || revision.Status.State == "Failed"
+	if finished && pipeline.Coverage != nil && revision.Status.Coverage == nil {
+		coverageCtx := context.WithValue(projectCtx, contextKeyRevision, revision)
+
+		status, reason, err := r.reconcileCoverage(coverageCtx, *pipeline.Coverage)
+		if err != nil {
+			r.Log.Error(err, "Failed to reconcile coverage")
+
+			// Finish the revision once its coverage is known
+			revision.Status.State = "Pending"
+			retryErr = err
+		} else {
+			revision.Status.Coverage = status
+
+			if reason != "" && revision.Status.State == "Succeeded" {
+				revision.Status.State = "Failed"
+				revision.Status.Reason = reason
+			}
+		}
+	}
+
+	if revision.Status.State == "Succeeded" && project.Spec.Signing != nil && revision.Status.Signatures == nil {
+		signingCtx := context.WithValue(projectCtx, contextKeyRevision, revision)
+
+		signatures, err := r.reconcileSignatures(signingCtx, *project.Spec.Signing)
+		if err != nil {
+			r.Log.Error(err, "Failed to sign revision outputs")
+
+			// Finish the revision once its outputs are signed
+			revision.Status.State = "Pending"
+			retryErr = err
+		} else {
+			revision.Status.Signatures = signatures
+		}
+	}
+
+	if revision.Status.State == "Succeeded" && project.Spec.Provenance != nil && revision.Status.Provenance == nil {
+		provenanceCtx := context.WithValue(projectCtx, contextKeyRevision, revision)
+
+		status, err := r.reconcileProvenance(provenanceCtx, *project.Spec.Provenance)
+		if err != nil {
+			r.Log.Error(err, "Failed to generate provenance")
+
+			// Finish the revision once its provenance is stored
+			revision.Status.State = "Pending"
+			retryErr = err
+		} else {
+			revision.Status.Provenance = status
+		}
 	}
 
-	if err = r.Update(revisionCtx, &revision); err != nil {
+	if err = r.Status().Update(revisionCtx, &revision); err != nil {
 		r.Log.Error(err, "Failed to update revision state")
+
+		return ctrl.Result{}, err
 	}
 	r.Log.Info("Updated revision state", "state", revision.Status.State)
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{}, retryErr
 }
 
 func (r *RevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	ownerKey := ".metadata.controller"
 	apiGVStr := v1beta1.GroupVersion.String()
 
 	if err := mgr.GetFieldIndexer().IndexField(&batchv1.Job{}, ownerKey, func(object runtime.Object) []string {
@@ -102,50 +403,256 @@ func (r *RevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}); err != nil {
 		return err
 	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(r.Clientset, 0, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.LabelSelector = revisionLabel
+	}))
+	pods := factory.Core().V1().Pods()
+	r.pods = pods.Lister()
+
+	if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		factory.Start(stop)
+		factory.WaitForCacheSync(stop)
+		<-stop
+
+		return nil
+	})); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1beta1.Revision{}).
 		Owns(&batchv1.Job{}).
+		Watches(&source.Informer{Informer: pods.Informer()}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(func(object handler.MapObject) []reconcile.Request {
+				name, ok := object.Meta.GetLabels()[revisionLabel]
+				if !ok {
+					return nil
+				}
+
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{
+					Namespace: object.Meta.GetNamespace(),
+					Name:      name,
+				}}}
+			}),
+		}).
 		Complete(r)
 }
 
 func (r *RevisionReconciler) createJob(ctx context.Context) (batchv1.Job, error) {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
 	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	data := newTemplateData(project, revision, cell)
+
+	image, err := renderTemplate(project.Spec.Image.Name, data)
+	if err != nil {
+		return batchv1.Job{}, invalidSpec("Invalid image: %s", err)
+	}
+	if image == "" && project.Spec.Nix != nil {
+		image = r.NixImage
+	} else if image == "" {
+		image = r.RunnerImage
+	}
+
+	command, err := renderTemplates(project.Spec.Image.Entrypoint, data)
+	if err != nil {
+		return batchv1.Job{}, invalidSpec("Invalid entrypoint: %s", err)
+	}
+
+	args, err := renderTemplates(project.Spec.Image.Cmd, data)
+	if err != nil {
+		return batchv1.Job{}, invalidSpec("Invalid cmd: %s", err)
+	}
+
+	trusted := revision.Trusted(project)
+
+	var nix v1beta1.Nix
+	if project.Spec.Nix != nil {
+		nix = *project.Spec.Nix
+		if !trusted {
+			nix.BinaryCache = nil
+		}
+
+		script, err := nixScript(nix, trusted && (nix.CacheClaimName != "" || nix.BinaryCache != nil))
+		if err != nil {
+			return batchv1.Job{}, invalidSpec("Invalid Nix build: %s", err)
+		}
+
+		command = []string{"/bin/sh", "-c", script, "hedron-nix"}
+		args = nil
+	}
+
+	pipeline := revisionPipeline(project, revision)
+
+	build := corev1.Container{
+		Name:       "build",
+		Image:      image,
+		Command:    command,
+		Args:       args,
+		WorkingDir: workspacePath,
+		Env:        buildEnv(project, revision, cell),
+		EnvFrom:    buildEnvFrom(project, revision),
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workspaceVolume, MountPath: workspacePath},
+		},
+	}
+	volumes := checkoutVolumes(project, revision)
+
+	secretVolumes, secretVolumeMounts := secretMounts(project, revision)
+	build.VolumeMounts = append(build.VolumeMounts, secretVolumeMounts...)
+	volumes = append(volumes, secretVolumes...)
+
+	if project.Spec.Nix != nil {
+		nixVolumes, nixVolumeMounts := nixVolumes(nix, trusted)
+		build.VolumeMounts = append(build.VolumeMounts, nixVolumeMounts...)
+		volumes = append(volumes, nixVolumes...)
+	}
+
+	if len(pipeline.Services) > 0 {
+		// The image entrypoint is replaced by the wait for services, so the
+		// build command must be spelled out
+		if len(command) == 0 && len(args) == 0 {
+			return batchv1.Job{}, invalidSpec("services require the image entrypoint or cmd to be set")
+		}
+
+		build.Command = []string{"/bin/sh", "-c", waitForServicesScript, "hedron-step"}
+		build.Args = append(command, args...)
+		build.VolumeMounts = append(build.VolumeMounts, corev1.VolumeMount{
+			Name:      podInfoVolume,
+			MountPath: podInfoPath,
+			ReadOnly:  true,
+		})
+	}
+
+	initContainers := []corev1.Container{checkoutContainer(project, revision, r.RunnerImage)}
+	containers := append([]corev1.Container{build}, serviceContainers(pipeline)...)
+
+	if len(pipeline.Caches) > 0 {
+		caches, err := r.renderCaches(ctx, pipeline.Caches)
+		if err != nil {
+			return batchv1.Job{}, err
+		}
+
+		initContainers = append(initContainers, r.cacheRestoreContainer(revision, caches))
+		if trusted {
+			containers = append(containers, r.cacheSaveContainer(revision, caches))
+		}
+	}
+
+	if hasUploads(pipeline) {
+		containers = append(containers, r.uploadsContainer(revision, cell, pipeline))
+	}
+
+	if len(pipeline.Images) > 0 {
+		imageContainers, imageVolumes, err := r.imageContainers(pipeline, data, trusted)
+		if err != nil {
+			return batchv1.Job{}, invalidSpec("Invalid image: %s", err)
+		}
+
+		containers = append(containers, imageContainers...)
+		volumes = append(volumes, imageVolumes...)
+	}
+
+	if pipeline.SBOM != nil {
+		containers = append(containers, r.sbomContainer(pipeline, trusted))
+		volumes = append(volumes, sbomVolumeSource())
+	}
+
+	if project.Spec.Nix != nil {
+		container, err := r.nixOutputsContainer(revision, cell, nix, trusted)
+		if err != nil {
+			return batchv1.Job{}, invalidSpec("Invalid Nix build: %s", err)
+		}
+
+		containers = append(containers, container)
+	}
+
+	if len(pipeline.Services) > 0 || hasSidecars(project, pipeline) {
+		volumes = append(volumes, podInfoVolumeSource())
+	}
+
+	backoffLimit := int32(0)
 
 	job := batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      revision.Name,
+			Name:      cell.Job,
 			Namespace: revision.Namespace,
 		},
 		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
 			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{revisionLabel: revision.Name},
+				},
 				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:    "hedron-runner",
-							Image:   "ghcr.io/thmzlt/hedron-runner:latest",
-							Command: []string{"sleep", "30"},
-						},
-					},
-					RestartPolicy: "Never",
+					InitContainers: initContainers,
+					Containers:     containers,
+					Volumes:        volumes,
+					RestartPolicy:  "Never",
 				},
 			},
 		},
 	}
 
+	if project.Spec.Timeout != nil {
+		deadline := int64(project.Spec.Timeout.Duration.Seconds())
+		job.Spec.ActiveDeadlineSeconds = &deadline
+	}
+
+	if project.Spec.PodTemplate != nil {
+		if err := v1beta1.ValidatePodTemplate(*project.Spec.PodTemplate); err != nil {
+			return batchv1.Job{}, invalidSpec("Invalid pod template: %s", err)
+		}
+		applyPodTemplate(&job.Spec.Template.Spec, *project.Spec.PodTemplate)
+	}
+
 	if err := ctrl.SetControllerReference(&revision, &job, r.Scheme); err != nil {
 		return job, err
 	}
 
-	return job, r.Create(ctx, &job)
+	if err := r.Create(ctx, &job); apierrors.IsInvalid(err) {
+		return job, invalidSpec("Invalid job: %s", err)
+	} else if err != nil {
+		return job, err
+	}
+
+	return job, nil
+}
+
+// specError is an error in the spec of a job, which retrying does not fix
+type specError struct {
+	error
+}
+
+func invalidSpec(format string, args ...interface{}) error {
+	return specError{fmt.Errorf(format, args...)}
 }
 
 func (r *RevisionReconciler) fetchJob(ctx context.Context) (batchv1.Job, error) {
 	var job batchv1.Job
 
-	request := ctx.Value(contextKeyRequest).(ctrl.Request)
-	jobName := request.NamespacedName
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	return job, r.Get(ctx, client.ObjectKey{
+		Namespace: revision.Namespace,
+		Name:      cell.Job,
+	}, &job)
+}
+
+func (r *RevisionReconciler) fetchProject(ctx context.Context) (v1beta1.Project, error) {
+	var project v1beta1.Project
+
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	err := r.Get(ctx, client.ObjectKey{
+		Namespace: revision.Namespace,
+		Name:      revision.Spec.ProjectRef.Name,
+	}, &project)
 
-	return job, r.Get(ctx, jobName, &job)
+	return project, err
 }
 
 func (r *RevisionReconciler) fetchRevision(ctx context.Context) (v1beta1.Revision, error) {
@@ -0,0 +1,77 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const revisionOwnerValidatorPath = "/validate-core-hedron-build-v1beta1-revision-owner"
+
+// RevisionOwnerValidator keeps anyone but the manager from creating
+// revisions controlled by a project, which are trusted with its secrets
+type RevisionOwnerValidator struct {
+	// Username is the user the manager authenticates as, e.g.
+	// system:serviceaccount:hedron-system:default
+	Username string
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-hedron-build-v1beta1-revision-owner,mutating=false,failurePolicy=fail,groups=core.hedron.build,resources=revisions,versions=v1beta1;v1,name=vrevisionowner.kb.io
+
+func (v *RevisionOwnerValidator) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(revisionOwnerValidatorPath, &webhook.Admission{Handler: v})
+
+	return nil
+}
+
+// Handle implements admission.Handler
+func (v *RevisionOwnerValidator) Handle(ctx context.Context, request admission.Request) admission.Response {
+	if request.UserInfo.Username == v.Username {
+		return admission.Allowed("")
+	}
+
+	// Only the metadata is read, which is the same in every version
+	var revision metav1.PartialObjectMetadata
+	if err := json.Unmarshal(request.Object.Raw, &revision); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	owner := projectController(&revision)
+	if owner == nil {
+		return admission.Allowed("")
+	}
+
+	// Updates may keep or remove the controller, as the garbage collector does
+	if request.Operation == admissionv1beta1.Update {
+		var old metav1.PartialObjectMetadata
+		if err := json.Unmarshal(request.OldObject.Raw, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if oldOwner := projectController(&old); oldOwner != nil && oldOwner.UID == owner.UID {
+			return admission.Allowed("")
+		}
+	}
+
+	return admission.Denied("only the manager creates revisions controlled by a project")
+}
+
+// projectController returns the owner reference of the project controlling
+// a revision, if any
+func projectController(revision metav1.Object) *metav1.OwnerReference {
+	owner := metav1.GetControllerOf(revision)
+	if owner == nil || owner.Kind != "Project" {
+		return nil
+	}
+
+	return owner
+}
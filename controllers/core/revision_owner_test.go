@@ -0,0 +1,74 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+const testManager = "system:serviceaccount:hedron-system:default"
+
+func rawRevision(t *testing.T, revision *v1beta1.Revision) runtime.RawExtension {
+	if revision == nil {
+		return runtime.RawExtension{}
+	}
+
+	raw, err := json.Marshal(revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestRevisionOwnerValidator(t *testing.T) {
+	project := testProject()
+	owned := testRevision(project)
+	unowned := testRevision(project)
+	unowned.OwnerReferences = nil
+
+	tests := []struct {
+		name      string
+		operation admissionv1beta1.Operation
+		username  string
+		revision  v1beta1.Revision
+		old       *v1beta1.Revision
+		allowed   bool
+	}{
+		{name: "manager creating an owned revision", operation: admissionv1beta1.Create, username: testManager, revision: owned, allowed: true},
+		{name: "user creating an owned revision", operation: admissionv1beta1.Create, username: "alice", revision: owned},
+		{name: "user creating a revision", operation: admissionv1beta1.Create, username: "alice", revision: unowned, allowed: true},
+		{name: "user keeping the owner", operation: admissionv1beta1.Update, username: "alice", revision: owned, old: &owned, allowed: true},
+		{name: "user adding the owner", operation: admissionv1beta1.Update, username: "alice", revision: owned, old: &unowned},
+		{name: "garbage collector removing the owner", operation: admissionv1beta1.Update, username: "system:serviceaccount:kube-system:generic-garbage-collector", revision: unowned, old: &owned, allowed: true},
+	}
+
+	validator := RevisionOwnerValidator{Username: testManager}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revision := test.revision
+
+			response := validator.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+				Operation: test.operation,
+				UserInfo:  authenticationv1.UserInfo{Username: test.username},
+				Object:    rawRevision(t, &revision),
+				OldObject: rawRevision(t, test.old),
+			}})
+			if response.Allowed != test.allowed {
+				t.Errorf("expected allowed to be %v, got %v", test.allowed, response.Allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/sbom"
+	"github.com/thmzlt/hedron/pkg/server"
+)
+
+const (
+	// sbomContainer generates the software bills of materials of build pods
+	// into the SBOM volume, which the uploads container uploads
+	sbomContainer = "sbom"
+
+	sbomVolume = "sbom"
+	sbomPath   = "/hedron/sbom"
+
+	// sbomArtifactPrefix starts the names of SBOM artifacts
+	sbomArtifactPrefix = "sbom-"
+
+	dockerConfigsPath = "/hedron/docker"
+)
+
+// imagesDoneAnnotation is set on build pods once their image containers have
+// all terminated, which the SBOM container waits for
+const imagesDoneAnnotation = "hedron.build/images-done"
+
+// sbomDoneFile is created once the SBOM container is done, successfully or
+// not
+var sbomDoneFile = path.Join(sbomPath, ".done")
+
+// sbomFormat returns the syft output format and the file extension of the
+// SBOMs of a pipeline
+func sbomFormat(settings v1beta1.SBOM) (string, string) {
+	if settings.Format == v1beta1.SPDX {
+		return "spdx-json", ".spdx.json"
+	}
+
+	return "cyclonedx-json", ".cdx.json"
+}
+
+// sbomFileName returns the name of the SBOM of an image, or of the workspace
+// when image is empty
+func sbomFileName(image string, extension string) string {
+	if image == "" {
+		return "workspace" + extension
+	}
+
+	return "image-" + image + extension
+}
+
+// sbomImage returns the image whose SBOM an artifact holds, or "" for the
+// SBOM of the workspace
+func sbomImage(artifact string) string {
+	image := strings.TrimPrefix(artifact, sbomArtifactPrefix+"image-")
+	if image == artifact {
+		return ""
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(image, ".cdx.json"), ".spdx.json")
+}
+
+// imageReferenceFile is where image containers write the reference of the
+// image they pushed, digest included
+func imageReferenceFile(image v1beta1.ImageBuild) string {
+	return path.Join(sbomPath, image.Name+".image")
+}
+
+// sbomScript generates the SBOM of the workspace once the build succeeds,
+// then the SBOMs of the images once they are pushed
+func sbomScript(pipeline v1beta1.Pipeline) string {
+	format, extension := sbomFormat(*pipeline.SBOM)
+
+	script := []string{
+		"set -u",
+		fmt.Sprintf("trap 'touch %s' EXIT", sbomDoneFile),
+		waitForBuildScript,
+		fmt.Sprintf(`grep -qs '^%s="0"$' %s/annotations || exit 0`, buildExitCodeAnnotation, podInfoPath),
+		fmt.Sprintf(
+			`if /syft dir:%s -o %s=%s; then echo "Generated SBOM of the workspace"; else echo "SBOM of the workspace not generated"; fi`,
+			workspacePath, format, path.Join(sbomPath, sbomFileName("", extension)),
+		),
+	}
+
+	if len(pipeline.Images) == 0 {
+		return strings.Join(script, "\n")
+	}
+
+	script = append(script,
+		fmt.Sprintf(`until grep -qs '^%s=' %s/annotations; do sleep 1; done`, imagesDoneAnnotation, podInfoPath),
+	)
+	for _, image := range pipeline.Images {
+		env := []string{"DOCKER_CONFIG=" + path.Join(dockerConfigsPath, image.Name)}
+		if image.Insecure {
+			env = append(env, "SYFT_REGISTRY_INSECURE_SKIP_TLS_VERIFY=true", "SYFT_REGISTRY_INSECURE_USE_HTTP=true")
+		}
+
+		script = append(script, fmt.Sprintf(
+			`if [ -s %s ]; then `+
+				`if %s /syft "registry:$(head -n 1 %s)" -o %s=%s; then echo "Generated SBOM of image %s"; else echo "SBOM of image %s not generated"; fi; `+
+				`fi`,
+			imageReferenceFile(image),
+			strings.Join(env, " "), imageReferenceFile(image), format, path.Join(sbomPath, sbomFileName(image.Name, extension)),
+			image.Name, image.Name,
+		))
+	}
+
+	return strings.Join(script, "\n")
+}
+
+// sbomUploadsScript uploads the SBOMs once the SBOM container is done, with
+// the upload function of the uploads script
+func sbomUploadsScript() string {
+	return strings.Join([]string{
+		fmt.Sprintf(`until [ -e %s ]; do sleep 1; done`, sbomDoneFile),
+		fmt.Sprintf(`for file in %s/*.json; do if [ -f "$file" ]; then upload "%s${file##*/}" "$file"; fi; done`, sbomPath, sbomArtifactPrefix),
+	}, "\n")
+}
+
+// sbomContainer returns the container generating the SBOMs of the job of a
+// cell, which only reads pushed images for trusted revisions
+func (r *RevisionReconciler) sbomContainer(pipeline v1beta1.Pipeline, trusted bool) corev1.Container {
+	container := corev1.Container{
+		Name:       sbomContainer,
+		Image:      r.SBOMImage,
+		Command:    []string{"/busybox/sh", "-c", sbomScript(pipeline)},
+		WorkingDir: workspacePath,
+		Env: []corev1.EnvVar{
+			{Name: "HOME", Value: "/tmp"},
+			{Name: "SYFT_CHECK_FOR_APP_UPDATE", Value: "false"},
+			// Go modules are not downloaded into the workspace
+			{Name: "SYFT_GOLANG_SEARCH_REMOTE_LICENSES", Value: "true"},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workspaceVolume, MountPath: workspacePath, ReadOnly: true},
+			{Name: podInfoVolume, MountPath: podInfoPath, ReadOnly: true},
+			{Name: sbomVolume, MountPath: sbomPath},
+		},
+	}
+
+	for _, image := range pipeline.Images {
+		if image.SecretRef == nil || !trusted {
+			continue
+		}
+
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      dockerConfigVolume(image),
+			MountPath: path.Join(dockerConfigsPath, image.Name),
+			ReadOnly:  true,
+		})
+	}
+
+	return container
+}
+
+// sbomVolumeSource is the volume the SBOM container shares with the image
+// and uploads containers
+func sbomVolumeSource() corev1.Volume {
+	return corev1.Volume{
+		Name:         sbomVolume,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}
+}
+
+// collectSBOMs summarizes the SBOMs among the artifacts uploaded by the pod
+// of a finished cell
+func (r *RevisionReconciler) collectSBOMs(ctx context.Context, artifacts []v1beta1.ArtifactStatus) ([]v1beta1.SBOMStatus, error) {
+	if r.Store == nil {
+		return nil, nil
+	}
+
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	statuses := []v1beta1.SBOMStatus{}
+	for _, artifact := range artifacts {
+		if !strings.HasPrefix(artifact.Name, sbomArtifactPrefix) {
+			continue
+		}
+
+		key, err := server.ArtifactKey(revision.Namespace, revision.Name, artifact.Job, artifact.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := r.Store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		summary, err := sbom.Summarize(reader)
+		reader.Close()
+		if err != nil {
+			r.Log.Info("Ignoring SBOM", "key", key, "error", err.Error())
+			continue
+		}
+
+		statuses = append(statuses, v1beta1.SBOMStatus{
+			Name:       artifact.Name,
+			Cell:       artifact.Cell,
+			Job:        artifact.Job,
+			Image:      sbomImage(artifact.Name),
+			Components: summary.Components,
+			Licenses:   summary.Licenses,
+		})
+	}
+
+	return statuses, nil
+}
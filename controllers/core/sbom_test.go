@@ -0,0 +1,64 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+var sbomNameTests = []struct {
+	name     string
+	format   v1beta1.SBOMFormat
+	image    string
+	syft     string
+	fileName string
+	artifact string
+}{
+	{
+		name:     "workspace",
+		syft:     "cyclonedx-json",
+		fileName: "workspace.cdx.json",
+		artifact: "sbom-workspace.cdx.json",
+	},
+	{
+		name:     "image",
+		image:    "app",
+		syft:     "cyclonedx-json",
+		fileName: "image-app.cdx.json",
+		artifact: "sbom-image-app.cdx.json",
+	},
+	{
+		name:     "spdx image",
+		format:   v1beta1.SPDX,
+		image:    "app-debug",
+		syft:     "spdx-json",
+		fileName: "image-app-debug.spdx.json",
+		artifact: "sbom-image-app-debug.spdx.json",
+	},
+}
+
+func TestSBOMNames(t *testing.T) {
+	for _, test := range sbomNameTests {
+		t.Run(test.name, func(t *testing.T) {
+			syft, extension := sbomFormat(v1beta1.SBOM{Format: test.format})
+			if syft != test.syft {
+				t.Errorf("expected format %s, got %s", test.syft, syft)
+			}
+
+			fileName := sbomFileName(test.image, extension)
+			if fileName != test.fileName {
+				t.Errorf("expected file %s, got %s", test.fileName, fileName)
+			}
+			if artifact := sbomArtifactPrefix + fileName; artifact != test.artifact {
+				t.Errorf("expected artifact %s, got %s", test.artifact, artifact)
+			}
+			if image := sbomImage(test.artifact); image != test.image {
+				t.Errorf("expected image %q, got %q", test.image, image)
+			}
+		})
+	}
+}
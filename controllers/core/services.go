@@ -0,0 +1,150 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+const (
+	// servicesReadyAnnotation is set on build pods once all their services
+	// are ready, which lets the build step start
+	servicesReadyAnnotation = "hedron.build/services-ready"
+
+	podInfoVolume = "podinfo"
+	podInfoPath   = "/etc/hedron/podinfo"
+
+	serviceContainerPrefix = "service-"
+)
+
+// serviceContainers returns the containers running the services of a pipeline
+func serviceContainers(pipeline v1beta1.Pipeline) []corev1.Container {
+	containers := []corev1.Container{}
+
+	for _, service := range pipeline.Services {
+		containers = append(containers, corev1.Container{
+			Name:           serviceContainerPrefix + service.Name,
+			Image:          service.Image,
+			Command:        service.Command,
+			Args:           service.Args,
+			Env:            service.Env,
+			Ports:          service.Ports,
+			ReadinessProbe: service.ReadinessProbe,
+		})
+	}
+
+	return containers
+}
+
+// podInfoVolumeSource exposes the pod annotations to the build container so
+// that it sees when its services are ready
+func podInfoVolumeSource() corev1.Volume {
+	return corev1.Volume{
+		Name: podInfoVolume,
+		VolumeSource: corev1.VolumeSource{DownwardAPI: &corev1.DownwardAPIVolumeSource{
+			Items: []corev1.DownwardAPIVolumeFile{
+				{Path: "annotations", FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations"}},
+			},
+		}},
+	}
+}
+
+// waitForServicesScript holds the build step until the services are ready
+var waitForServicesScript = strings.Join([]string{
+	fmt.Sprintf(`until grep -qs '^%s="true"$' %s/annotations; do sleep 1; done`, servicesReadyAnnotation, podInfoPath),
+	`exec "$@"`,
+}, "\n")
+
+// reconcileServices signals the build pod of a cell once its services are
+// ready, and returns the state of its build container once it terminates
+func (r *RevisionReconciler) reconcileServices(ctx context.Context) (v1beta1.State, string, error) {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	pod, err := r.fetchPod(ctx)
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+
+	ready := true
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+
+		if status.Name == "build" && terminated != nil {
+			// Wait for the sidecars before the services are stopped
+			if !sidecarsDone(pod) {
+				return "", "", nil
+			}
+
+			if terminated.ExitCode != 0 {
+				return "Failed", fmt.Sprintf("Build exited with code %d", terminated.ExitCode), nil
+			}
+			if reason := imageFailure(pod, revision.Trusted(project)); reason != "" {
+				return "Failed", reason, nil
+			}
+
+			return "Succeeded", "", nil
+		}
+
+		if !strings.HasPrefix(status.Name, serviceContainerPrefix) {
+			continue
+		}
+		if terminated != nil {
+			name := strings.TrimPrefix(status.Name, serviceContainerPrefix)
+
+			return "Failed", fmt.Sprintf("Service %s exited with code %d", name, terminated.ExitCode), nil
+		}
+		if !status.Ready {
+			ready = false
+		}
+	}
+
+	if !ready || len(pod.Status.ContainerStatuses) == 0 || pod.Annotations[servicesReadyAnnotation] == "true" {
+		return "", "", nil
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[servicesReadyAnnotation] = "true"
+
+	r.Log.Info("Services are ready", "pod", pod.Name)
+
+	return "", "", r.Patch(ctx, &pod, patch)
+}
+
+// fetchPod returns the most recent pod of the job of a cell
+func (r *RevisionReconciler) fetchPod(ctx context.Context) (corev1.Pod, error) {
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	pods, err := r.pods.Pods(revision.Namespace).List(labels.SelectorFromSet(labels.Set{"job-name": cell.Job}))
+	if err != nil {
+		return corev1.Pod{}, err
+	}
+
+	var latest *corev1.Pod
+	for _, pod := range pods {
+		if latest == nil || latest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			latest = pod
+		}
+	}
+	if latest == nil {
+		return corev1.Pod{}, fmt.Errorf("pods of job %s not found", cell.Job)
+	}
+
+	return *latest, nil
+}
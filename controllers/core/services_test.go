@@ -0,0 +1,237 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+func running(name string, ready bool) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:  name,
+		Ready: ready,
+		State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}
+}
+
+func terminated(name string, exitCode int32) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:  name,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+	}
+}
+
+// newTestPodReconciler returns a reconciler whose build pod of the job of
+// cell has the given container statuses and annotations
+func newTestPodReconciler(t *testing.T, cell v1beta1.CellStatus, annotations map[string]string, statuses ...corev1.ContainerStatus) (RevisionReconciler, *corev1.Pod) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        cell.Job + "-abcde",
+			Labels:      map[string]string{"job-name": cell.Job},
+			Annotations: annotations,
+		},
+		Status: corev1.PodStatus{ContainerStatuses: statuses},
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := indexer.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+
+	return RevisionReconciler{
+		Client: fake.NewFakeClientWithScheme(clientgoscheme.Scheme, pod.DeepCopy()),
+		Log:    logf.NullLogger{},
+		pods:   corelisters.NewPodLister(indexer),
+	}, pod
+}
+
+func testCellContext() (context.Context, v1beta1.CellStatus) {
+	project := testProject()
+	revision := testRevision(project)
+	cell := v1beta1.CellStatus{Name: "build", Job: revision.Name + "-build", State: "Pending"}
+
+	ctx := context.WithValue(context.Background(), contextKeyProject, project)
+	ctx = context.WithValue(ctx, contextKeyRevision, revision)
+
+	return context.WithValue(ctx, contextKeyCell, cell), cell
+}
+
+var reconcileServicesTests = []struct {
+	name     string
+	statuses []corev1.ContainerStatus
+	state    v1beta1.State
+	reason   string
+	ready    bool
+}{
+	{
+		name:     "service never ready",
+		statuses: []corev1.ContainerStatus{running("build", true), running("service-db", false)},
+	},
+	{
+		name:     "services ready",
+		statuses: []corev1.ContainerStatus{running("build", true), running("service-db", true)},
+		ready:    true,
+	},
+	{
+		name:     "service crashed",
+		statuses: []corev1.ContainerStatus{running("build", true), terminated("service-db", 1)},
+		state:    "Failed",
+		reason:   "Service db exited with code 1",
+	},
+	{
+		name:     "build finished before its sidecars",
+		statuses: []corev1.ContainerStatus{terminated("build", 0), running("service-db", true), running(uploadsContainer, true)},
+	},
+	{
+		name:     "build succeeded with services running",
+		statuses: []corev1.ContainerStatus{terminated("build", 0), running("service-db", true), terminated(uploadsContainer, 0)},
+		state:    "Succeeded",
+	},
+	{
+		name:     "build failed with services running",
+		statuses: []corev1.ContainerStatus{terminated("build", 2), running("service-db", true)},
+		state:    "Failed",
+		reason:   "Build exited with code 2",
+	},
+}
+
+func TestReconcileServices(t *testing.T) {
+	for _, test := range reconcileServicesTests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cell := testCellContext()
+			reconciler, pod := newTestPodReconciler(t, cell, nil, test.statuses...)
+
+			state, reason, err := reconciler.reconcileServices(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != test.state || reason != test.reason {
+				t.Errorf("expected %q and %q, got %q and %q", test.state, test.reason, state, reason)
+			}
+
+			var patched corev1.Pod
+			if err := reconciler.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, &patched); err != nil {
+				t.Fatal(err)
+			}
+			if ready := patched.Annotations[servicesReadyAnnotation] == "true"; ready != test.ready {
+				t.Errorf("expected the services ready annotation to be %v", test.ready)
+			}
+		})
+	}
+}
+
+func TestReconcileBuildExit(t *testing.T) {
+	ctx, cell := testCellContext()
+
+	reconciler, pod := newTestPodReconciler(t, cell, nil, running("build", true), running(uploadsContainer, true))
+	if err := reconciler.reconcileBuildExit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var patched corev1.Pod
+	if err := reconciler.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, &patched); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := patched.Annotations[buildExitCodeAnnotation]; ok {
+		t.Error("expected no exit code while the build runs")
+	}
+
+	reconciler, pod = newTestPodReconciler(t, cell, nil, terminated("build", 3), running(uploadsContainer, true), terminated(imageContainerPrefix+"app", 0))
+	if err := reconciler.reconcileBuildExit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: pod.Name}, &patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.Annotations[buildExitCodeAnnotation] != "3" || patched.Annotations[imagesDoneAnnotation] != "true" {
+		t.Errorf("unexpected annotations %v", patched.Annotations)
+	}
+}
+
+func TestReconcileStopsServices(t *testing.T) {
+	ctx, cell := testCellContext()
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	project.Spec.Pipeline.Services = []v1beta1.Service{{Name: "db", Image: "postgres"}}
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	revision.Status = v1beta1.RevisionStatus{State: "Pending", Cells: []v1beta1.CellStatus{cell}}
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: revision.Namespace, Name: cell.Job},
+		Status:     batchv1.JobStatus{Active: 1},
+	}
+
+	reconciler, _ := newTestPodReconciler(t, cell, nil, terminated("build", 0), running("service-db", true))
+	reconciler.Client = fake.NewFakeClientWithScheme(testScheme(t), &project, &revision, &job)
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: revision.Namespace, Name: revision.Name}}
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	var reconciled v1beta1.Revision
+	if err := reconciler.Get(ctx, request.NamespacedName, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.Status.State != "Succeeded" || reconciled.Status.Cells[0].State != "Succeeded" {
+		t.Errorf("expected the revision to succeed, got %v", reconciled.Status)
+	}
+
+	err := reconciler.Get(ctx, client.ObjectKey{Namespace: job.Namespace, Name: job.Name}, &job)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the job running the services to be deleted, got %v", err)
+	}
+}
+
+var sidecarsDoneTests = []struct {
+	name     string
+	statuses []corev1.ContainerStatus
+	done     bool
+}{
+	{
+		name:     "no sidecars",
+		statuses: []corev1.ContainerStatus{terminated("build", 0), running("service-db", true)},
+		done:     true,
+	},
+	{
+		name:     "uploads running",
+		statuses: []corev1.ContainerStatus{terminated("build", 0), running(uploadsContainer, true)},
+	},
+	{
+		name:     "image running",
+		statuses: []corev1.ContainerStatus{terminated("build", 0), running(imageContainerPrefix+"app", true)},
+	},
+	{
+		name:     "sidecars terminated",
+		statuses: []corev1.ContainerStatus{terminated("build", 0), terminated(uploadsContainer, 0), terminated(nixOutputsContainer, 1)},
+		done:     true,
+	},
+}
+
+func TestSidecarsDone(t *testing.T) {
+	for _, test := range sidecarsDoneTests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: test.statuses}}
+			if done := sidecarsDone(pod); done != test.done {
+				t.Errorf("expected %v, got %v", test.done, done)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// Sidecars are containers of build pods, such as the ones saving caches,
+// uploading artifacts and reports, building images, generating SBOMs and
+// recording Nix outputs, that act once the build container has exited.
+
+// buildExitCodeAnnotation is set on build pods to the exit code of the build
+// container once it terminates, which sidecars wait for
+const buildExitCodeAnnotation = "hedron.build/build-exit-code"
+
+// waitForBuildScript holds a sidecar until the build container has exited
+var waitForBuildScript = fmt.Sprintf(`until grep -qs '^%s=' %s/annotations; do sleep 1; done`, buildExitCodeAnnotation, podInfoPath)
+
+// sidecarContainers are the containers that must exit before the services
+// of a build pod are stopped
+var sidecarContainers = []string{cacheSaveContainer, uploadsContainer, sbomContainer, nixOutputsContainer}
+
+// hasSidecars reports whether the build pods of a project pipeline have
+// sidecars
+func hasSidecars(project v1beta1.Project, pipeline v1beta1.Pipeline) bool {
+	return len(pipeline.Caches) > 0 || hasUploads(pipeline) || len(pipeline.Images) > 0 || pipeline.SBOM != nil || project.Spec.Nix != nil
+}
+
+// reconcileBuildExit tells the sidecars of the build pod of a cell how the
+// build container exited, and when the images are pushed
+func (r *RevisionReconciler) reconcileBuildExit(ctx context.Context) error {
+	pod, err := r.fetchPod(ctx)
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	annotations := map[string]string{}
+	if _, ok := pod.Annotations[buildExitCodeAnnotation]; !ok {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == "build" && status.State.Terminated != nil {
+				annotations[buildExitCodeAnnotation] = fmt.Sprint(status.State.Terminated.ExitCode)
+			}
+		}
+	}
+	if _, ok := pod.Annotations[imagesDoneAnnotation]; !ok && imagesDone(pod) {
+		annotations[imagesDoneAnnotation] = "true"
+	}
+
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	for name, value := range annotations {
+		pod.Annotations[name] = value
+	}
+
+	return r.Patch(ctx, &pod, patch)
+}
+
+// sidecarsDone reports whether the sidecars of a pod have all terminated
+func sidecarsDone(pod corev1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil {
+			continue
+		}
+		if strings.HasPrefix(status.Name, imageContainerPrefix) {
+			return false
+		}
+		for _, name := range sidecarContainers {
+			if status.Name == name {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,87 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// verifyCommit checks the signature of the revision commit against the
+// trusted keys of the project. It returns why the revision must be rejected,
+// or an empty string when the commit is signed by a trusted key.
+func (r *RevisionReconciler) verifyCommit(ctx context.Context) (string, error) {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	remote, err := fetchRemote(ctx, r.Client, project)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := r.Mirrors.Commit(ctx, remote, revision.Spec.Revision)
+	if err != nil {
+		return "", err
+	}
+
+	if commit.PGPSignature == "" {
+		return "Commit is not signed", nil
+	}
+
+	for _, source := range project.Spec.TrustedKeys {
+		key, err := fetchKey(ctx, r.Client, project.Namespace, source)
+		if err != nil {
+			return "", err
+		}
+
+		entity, err := commit.Verify(string(key))
+		if err != nil {
+			continue
+		}
+
+		r.Log.Info("Verified commit signature", "revision", revision.Name, "key", entity.PrimaryKey.KeyIdString())
+
+		return "", nil
+	}
+
+	return "Commit is not signed by a trusted key", nil
+}
+
+// fetchKey returns the contents of a key stored in a Secret or a ConfigMap
+func fetchKey(ctx context.Context, c client.Client, namespace string, source v1beta1.KeySource) ([]byte, error) {
+	switch {
+	case source.SecretKeyRef != nil:
+		var secret corev1.Secret
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: source.SecretKeyRef.Name}, &secret); err != nil {
+			return nil, err
+		}
+
+		key, ok := secret.Data[source.SecretKeyRef.Key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s has no key %s", secret.Name, source.SecretKeyRef.Key)
+		}
+
+		return key, nil
+	case source.ConfigMapKeyRef != nil:
+		var configMap corev1.ConfigMap
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: source.ConfigMapKeyRef.Name}, &configMap); err != nil {
+			return nil, err
+		}
+
+		key, ok := configMap.Data[source.ConfigMapKeyRef.Key]
+		if !ok {
+			return nil, fmt.Errorf("config map %s has no key %s", configMap.Name, source.ConfigMapKeyRef.Key)
+		}
+
+		return []byte(key), nil
+	}
+
+	return nil, fmt.Errorf("key source has neither a secret nor a config map reference")
+}
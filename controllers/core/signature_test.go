@@ -0,0 +1,154 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/mirror"
+)
+
+func newTestEntity(t *testing.T, name string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entity
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	var buffer bytes.Buffer
+
+	writer, err := armor.Encode(&buffer, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(writer); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	return buffer.String()
+}
+
+func TestVerifyCommit(t *testing.T) {
+	trusted := newTestEntity(t, "trusted")
+	untrusted := newTestEntity(t, "untrusted")
+
+	dir, err := ioutil.TempDir("", "hedron-signed-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(message string, key *openpgp.Entity) string {
+		if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte(message), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("README"); err != nil {
+			t.Fatal(err)
+		}
+
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			Author:  &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+			SignKey: key,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return hash.String()
+	}
+
+	tests := []struct {
+		name   string
+		hash   string
+		reason string
+	}{
+		{name: "unsigned", hash: commit("Unsigned", nil), reason: "Commit is not signed"},
+		{name: "untrusted key", hash: commit("Untrusted", untrusted), reason: "Commit is not signed by a trusted key"},
+		{name: "trusted key", hash: commit("Trusted", trusted), reason: ""},
+	}
+
+	mirrorDir, err := ioutil.TempDir("", "hedron-mirrors-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(mirrorDir)
+
+	mirrors, err := mirror.NewCache(mirrorDir, 0, logf.NullLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "keys"},
+		Data:       map[string]string{"trusted.asc": armoredPublicKey(t, trusted)},
+	}
+
+	reconciler := RevisionReconciler{
+		Client:  fake.NewFakeClientWithScheme(clientgoscheme.Scheme, &keys),
+		Log:     logf.NullLogger{},
+		Mirrors: mirrors,
+	}
+
+	project := v1beta1.Project{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: v1beta1.ProjectSpec{
+			Repository: v1beta1.Repository{URL: "file://" + dir, Ref: "refs/heads/master"},
+			TrustedKeys: []v1beta1.KeySource{{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "keys"},
+				Key:                  "trusted.asc",
+			}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revision := v1beta1.Revision{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app-" + test.hash},
+				Spec:       v1beta1.RevisionSpec{Revision: test.hash},
+			}
+
+			ctx := context.WithValue(context.Background(), contextKeyProject, project)
+			ctx = context.WithValue(ctx, contextKeyRevision, revision)
+
+			reason, err := reconciler.verifyCommit(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason != test.reason {
+				t.Errorf("expected %q, got %q", test.reason, reason)
+			}
+		})
+	}
+}
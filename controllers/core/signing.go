@@ -0,0 +1,123 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/server"
+	"github.com/thmzlt/hedron/pkg/signing"
+)
+
+// The keys of the Secrets created by cosign generate-key-pair
+const (
+	cosignKey      = "cosign.key"
+	cosignPassword = "cosign.password"
+)
+
+// reconcileSignatures signs the artifacts and images of a successful trusted
+// revision, leaving out the artifacts not recorded by the server. Artifact
+// signatures are also stored next to the artifacts, as <name>.sig.
+func (r *RevisionReconciler) reconcileSignatures(ctx context.Context, settings v1beta1.Signing) ([]v1beta1.SignatureStatus, error) {
+	project := ctx.Value(contextKeyProject).(v1beta1.Project)
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+
+	// The project key vouches for trusted builds only
+	if !revision.Trusted(project) {
+		return nil, nil
+	}
+
+	artifacts, err := r.recordedArtifacts(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Namespace: project.Namespace, Name: settings.SecretRef.Name}, &secret); err != nil {
+		return nil, err
+	}
+
+	keyData, ok := secret.Data[cosignKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no key %s", secret.Name, cosignKey)
+	}
+
+	signer, err := signing.ParseCosignPrivateKey(keyData, secret.Data[cosignPassword])
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []v1beta1.SignatureStatus{}
+	for _, artifact := range artifacts {
+		digest, err := hex.DecodeString(strings.TrimPrefix(artifact.Digest, "sha256:"))
+		if err != nil || !strings.HasPrefix(artifact.Digest, "sha256:") {
+			continue
+		}
+
+		signature, err := signing.SignDigest(signer, digest)
+		if err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(signature)
+
+		key, err := server.ArtifactKey(revision.Namespace, revision.Name, artifact.Job, artifact.Name+".sig")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.Store.Put(ctx, key, strings.NewReader(encoded)); err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, v1beta1.SignatureStatus{
+			Kind:      v1beta1.ArtifactSignature,
+			Name:      artifact.Job + "/" + artifact.Name,
+			Digest:    artifact.Digest,
+			Signature: encoded,
+		})
+	}
+
+	for _, image := range revision.Status.Images {
+		signed := map[string]bool{}
+
+		for _, destination := range image.Destinations {
+			repository := imageRepository(destination)
+			if signed[repository] {
+				continue
+			}
+			signed[repository] = true
+
+			payload, err := signing.ImagePayload(repository, image.Digest)
+			if err != nil {
+				return nil, err
+			}
+
+			digest := sha256.Sum256(payload)
+			signature, err := signing.SignDigest(signer, digest[:])
+			if err != nil {
+				return nil, err
+			}
+
+			statuses = append(statuses, v1beta1.SignatureStatus{
+				Kind:      v1beta1.ImageSignature,
+				Name:      repository,
+				Digest:    image.Digest,
+				Signature: base64.StdEncoding.EncodeToString(signature),
+			})
+		}
+	}
+
+	r.Log.Info("Signed revision outputs", "revision", revision.Name, "signatures", len(statuses))
+
+	return statuses, nil
+}
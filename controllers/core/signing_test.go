@@ -0,0 +1,158 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/server"
+	"github.com/thmzlt/hedron/pkg/signing"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+func TestReconcileSignatures(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "hedron-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cosign"},
+		Data:       map[string][]byte{cosignKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})},
+	}
+
+	store := &storage.FileStore{Dir: dir}
+	reconciler := RevisionReconciler{
+		Client: fake.NewFakeClientWithScheme(clientgoscheme.Scheme, &secret),
+		Log:    logf.NullLogger{},
+		Store:  store,
+	}
+
+	artifactDigest := sha256.Sum256([]byte("app"))
+	project := testProject()
+	revision := testRevision(project)
+	revision.Status.Artifacts = []v1beta1.ArtifactStatus{
+		{Name: "app.tar.gz", Job: "app-0123abc-linux", Digest: "sha256:" + hex.EncodeToString(artifactDigest[:])},
+		{Name: "unknown.tar.gz", Job: "app-0123abc-linux", Digest: "md5:0123"},
+		{Name: "forged.tar.gz", Job: "app-0123abc-linux", Digest: "sha256:" + hex.EncodeToString(artifactDigest[:])},
+	}
+
+	// Only the artifacts uploaded through the server are recorded
+	prefix, err := server.ArtifactRecordsPrefix(revision.Namespace, revision.Name, "app-0123abc-linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, artifact := range revision.Status.Artifacts[:2] {
+		record, err := json.Marshal(artifact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Put(context.Background(), prefix+"/"+artifact.Name+".json", bytes.NewReader(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revision.Status.Images = []v1beta1.ImageStatus{{
+		Name:         "app",
+		Destinations: []string{"registry.example.com/app:latest", "registry.example.com/app:0123abc"},
+		Digest:       testDigest,
+	}}
+
+	ctx := context.WithValue(context.Background(), contextKeyProject, project)
+	ctx = context.WithValue(ctx, contextKeyRevision, revision)
+
+	signatures, err := reconciler.reconcileSignatures(ctx, v1beta1.Signing{SecretRef: corev1.LocalObjectReference{Name: "cosign"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 2 {
+		t.Fatalf("expected 2 signatures, got %v", signatures)
+	}
+
+	artifact := signatures[0]
+	if artifact.Kind != v1beta1.ArtifactSignature || artifact.Name != "app-0123abc-linux/app.tar.gz" {
+		t.Errorf("unexpected artifact signature %v", artifact)
+	}
+	verify(t, &key.PublicKey, artifactDigest[:], artifact.Signature)
+
+	storeKey, err := server.ArtifactKey(revision.Namespace, revision.Name, "app-0123abc-linux", "app.tar.gz.sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := store.Get(context.Background(), storeKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stored) != artifact.Signature {
+		t.Errorf("expected the stored signature %q, got %q", artifact.Signature, stored)
+	}
+
+	image := signatures[1]
+	if image.Kind != v1beta1.ImageSignature || image.Name != "registry.example.com/app" {
+		t.Errorf("unexpected image signature %v", image)
+	}
+	payload, err := signing.ImagePayload("registry.example.com/app", testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadDigest := sha256.Sum256(payload)
+	verify(t, &key.PublicKey, payloadDigest[:], image.Signature)
+
+	if _, err := reconciler.reconcileSignatures(ctx, v1beta1.Signing{SecretRef: corev1.LocalObjectReference{Name: "missing"}}); err == nil {
+		t.Error("expected an error signing with a missing secret")
+	}
+
+	revision.OwnerReferences = nil
+	ctx = context.WithValue(ctx, contextKeyRevision, revision)
+
+	signatures, err = reconciler.reconcileSignatures(ctx, v1beta1.Signing{SecretRef: corev1.LocalObjectReference{Name: "cosign"}})
+	if err != nil || signatures != nil {
+		t.Errorf("expected no signatures for an untrusted revision, got %v and %v", signatures, err)
+	}
+}
+
+func verify(t *testing.T, publicKey *ecdsa.PublicKey, digest []byte, encoded string) {
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signing.VerifyDigest(publicKey, digest, signature); err != nil {
+		t.Error(err)
+	}
+}
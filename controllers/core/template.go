@@ -0,0 +1,103 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"text/template"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// templateData is what pipeline values can refer to, e.g. "golang:{{ .Matrix.go }}"
+type templateData struct {
+	Project     string
+	Revision    string
+	Commit      string
+	ShortCommit string
+	Ref         string
+	BuildNumber int64
+	Matrix      map[string]string
+
+	// readFile reads files of the built commit, nil for missing files
+	readFile func(path string) ([]byte, error)
+}
+
+// HashFiles returns the SHA-256 of the given files of the built commit, for
+// cache keys that change along with lockfiles. Missing files are skipped.
+func (d templateData) HashFiles(paths ...string) (string, error) {
+	hash := sha256.New()
+
+	for _, path := range paths {
+		if d.readFile == nil {
+			break
+		}
+
+		contents, err := d.readFile(path)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(contents)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func newTemplateData(project v1beta1.Project, revision v1beta1.Revision, cell v1beta1.CellStatus) templateData {
+	commit := revision.Spec.Revision
+	shortCommit := commit
+	if len(shortCommit) > 7 {
+		shortCommit = shortCommit[:7]
+	}
+
+	return templateData{
+		Project:     project.Name,
+		Revision:    revision.Name,
+		Commit:      commit,
+		ShortCommit: shortCommit,
+		Ref:         revision.Spec.Ref,
+		BuildNumber: revision.Spec.BuildNumber,
+		Matrix:      cell.Values,
+	}
+}
+
+// renderTemplate expands text as a Go template, leaving text without actions
+// untouched.
+func renderTemplate(text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", err
+	}
+
+	return builder.String(), nil
+}
+
+// renderTemplates expands every element of texts
+func renderTemplates(texts []string, data templateData) ([]string, error) {
+	if texts == nil {
+		return nil, nil
+	}
+
+	rendered := make([]string, len(texts))
+	for i, text := range texts {
+		var err error
+		if rendered[i], err = renderTemplate(text, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,93 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func sha256Hex(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+var renderTemplateTests = []struct {
+	name     string
+	text     string
+	rendered string
+	valid    bool
+}{
+	{
+		name:     "no action",
+		text:     "golang:1.15",
+		rendered: "golang:1.15",
+		valid:    true,
+	},
+	{
+		name:     "matrix",
+		text:     "golang:{{ .Matrix.go }}",
+		rendered: "golang:1.15",
+		valid:    true,
+	},
+	{
+		name:     "missing matrix value",
+		text:     "{{ .Matrix.os }}",
+		rendered: "",
+		valid:    false,
+	},
+	{
+		name:     "hash files",
+		text:     `go-{{ .HashFiles "go.sum" }}`,
+		rendered: "go-" + sha256Hex("go.sum contents"),
+		valid:    true,
+	},
+	{
+		name:     "hash several files",
+		text:     `{{ .HashFiles "go.mod" "go.sum" }}`,
+		rendered: sha256Hex("go.mod contentsgo.sum contents"),
+		valid:    true,
+	},
+	{
+		name:     "hash missing file",
+		text:     `{{ .HashFiles "missing" "go.sum" }}`,
+		rendered: sha256Hex("go.sum contents"),
+		valid:    true,
+	},
+	{
+		name:     "invalid template",
+		text:     "{{ .Matrix.go",
+		rendered: "",
+		valid:    false,
+	},
+}
+
+func TestRenderTemplate(t *testing.T) {
+	files := map[string]string{"go.mod": "go.mod contents", "go.sum": "go.sum contents"}
+
+	data := templateData{
+		Matrix: map[string]string{"go": "1.15"},
+		readFile: func(path string) ([]byte, error) {
+			contents, ok := files[path]
+			if !ok {
+				return nil, nil
+			}
+			return []byte(contents), nil
+		},
+	}
+
+	for _, test := range renderTemplateTests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, err := renderTemplate(test.text, data)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+			if rendered != test.rendered {
+				t.Errorf("expected %q, got %q", test.rendered, rendered)
+			}
+		})
+	}
+}
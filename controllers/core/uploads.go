@@ -0,0 +1,216 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/server"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// uploadsContainer uploads the artifacts, test and coverage reports of build
+// pods
+const uploadsContainer = "uploads"
+
+// isArchive reports whether an artifact archives the files it matches
+func isArchive(artifact v1beta1.Artifact) bool {
+	return strings.HasSuffix(artifact.Name, ".tar.gz") || strings.HasSuffix(artifact.Name, ".tgz")
+}
+
+// uploadsScript uploads artifacts, test and coverage reports, then SBOMs,
+// once the build exits, successfully or not
+func uploadsScript(pipeline v1beta1.Pipeline) string {
+	script := []string{
+		"set -u",
+		waitForBuildScript,
+		// Only split file lists on new lines
+		"IFS='\n'",
+		`upload() { if curl -fsS -T "$2" -H "Authorization: Bearer $HEDRON_TOKEN" "$HEDRON_ARTIFACT_URL/$1" > /dev/null; then echo "Uploaded artifact $1"; else echo "Artifact $1 not uploaded"; fi; }`,
+	}
+
+	for _, artifact := range pipeline.Artifacts {
+		name := shellQuote(artifact.Name)
+
+		script = append(script,
+			matchFilesScript(artifact.Paths),
+			fmt.Sprintf(`if [ -z "$files" ]; then echo "Artifact "%s" matched no files"`, name),
+		)
+
+		if isArchive(artifact) {
+			script = append(script,
+				fmt.Sprintf(`elif tar -czf /tmp/artifact -- $files; then upload %s /tmp/artifact`, name),
+				fmt.Sprintf(`else echo "Artifact "%s" not archived"; fi`, name),
+				"rm -f /tmp/artifact",
+			)
+		} else {
+			script = append(script,
+				fmt.Sprintf(`elif [ "$(echo "$files" | wc -l)" -ne 1 ] || [ ! -f "$files" ]; then echo "Artifact "%s" must match a single file"`, name),
+				fmt.Sprintf(`else upload %s "$files"; fi`, name),
+			)
+		}
+	}
+
+	for _, report := range pipeline.Reports {
+		script = append(script,
+			matchFilesScript(report.Paths),
+			uploadFilesScript(`"$HEDRON_REPORT_URL/"`+shellQuote(report.Name)),
+		)
+	}
+
+	if pipeline.Coverage != nil {
+		script = append(script,
+			matchFilesScript(pipeline.Coverage.Paths),
+			uploadFilesScript(`"$HEDRON_COVERAGE_URL"`),
+		)
+	}
+
+	if pipeline.SBOM != nil {
+		script = append(script, sbomUploadsScript())
+	}
+
+	return strings.Join(script, "\n")
+}
+
+// matchFilesScript sets $files to the files matching glob patterns, one per
+// line
+func matchFilesScript(paths []string) string {
+	patterns := make([]string, len(paths))
+	for i, pattern := range paths {
+		patterns[i] = shellQuote(pattern)
+	}
+
+	return fmt.Sprintf(`files=$(for pattern in %s; do for file in $pattern; do if [ -e "$file" ]; then echo "$file"; fi; done; done)`, strings.Join(patterns, " "))
+}
+
+// uploadFilesScript uploads $files to url/<index>
+func uploadFilesScript(url string) string {
+	return strings.Join([]string{
+		"index=0",
+		fmt.Sprintf(`for file in $files; do if [ -f "$file" ] && curl -fsS -T "$file" -H "Authorization: Bearer $HEDRON_TOKEN" %s"/$index"; then echo "Uploaded $file"; else echo "File $file not uploaded"; fi; index=$((index + 1)); done`, url),
+	}, "\n")
+}
+
+// uploadsContainer returns the container that uploads the artifacts, test and
+// coverage reports of the job of a cell
+func (r *RevisionReconciler) uploadsContainer(revision v1beta1.Revision, cell v1beta1.CellStatus, pipeline v1beta1.Pipeline) corev1.Container {
+	url := strings.TrimSuffix(r.ServerURL, "/")
+	path := "/" + revision.Namespace + "/" + revision.Name + "/" + cell.Job
+
+	container := corev1.Container{
+		Name:       uploadsContainer,
+		Image:      r.RunnerImage,
+		Command:    []string{"/bin/sh", "-c", uploadsScript(pipeline)},
+		WorkingDir: workspacePath,
+		Env: []corev1.EnvVar{
+			{Name: "HOME", Value: "/tmp"},
+			{Name: "HEDRON_ARTIFACT_URL", Value: url + "/artifacts" + path},
+			{Name: "HEDRON_REPORT_URL", Value: url + "/reports" + path},
+			{Name: "HEDRON_COVERAGE_URL", Value: url + "/coverage" + path},
+			{Name: "HEDRON_TOKEN", Value: r.Tokens.Sign(revision.Namespace, revision.Name)},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workspaceVolume, MountPath: workspacePath, ReadOnly: true},
+			{Name: podInfoVolume, MountPath: podInfoPath, ReadOnly: true},
+		},
+	}
+
+	if pipeline.SBOM != nil {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: sbomVolume, MountPath: sbomPath, ReadOnly: true})
+	}
+
+	return container
+}
+
+// collectArtifacts returns the artifacts uploaded by the pod of a finished
+// cell, as recorded by the server when it stored them
+func (r *RevisionReconciler) collectArtifacts(ctx context.Context) ([]v1beta1.ArtifactStatus, error) {
+	revision := ctx.Value(contextKeyRevision).(v1beta1.Revision)
+	cell := ctx.Value(contextKeyCell).(v1beta1.CellStatus)
+
+	prefix, err := server.ArtifactRecordsPrefix(revision.Namespace, revision.Name, cell.Job)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := r.Store.List(ctx, prefix+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []v1beta1.ArtifactStatus{}
+	for _, object := range objects {
+		reader, err := r.Store.Get(ctx, object.Key)
+		if err != nil {
+			return artifacts, err
+		}
+
+		var artifact v1beta1.ArtifactStatus
+		err = json.NewDecoder(reader).Decode(&artifact)
+		reader.Close()
+		if err != nil {
+			return artifacts, err
+		}
+		artifact.Cell = cell.Name
+
+		artifacts = append(artifacts, artifact)
+	}
+
+	return artifacts, nil
+}
+
+// recordedArtifacts returns the artifacts of a revision whose digests match
+// the records the server stored along with them, leaving out the artifacts
+// the controller did not collect itself
+func (r *RevisionReconciler) recordedArtifacts(ctx context.Context, revision v1beta1.Revision) ([]v1beta1.ArtifactStatus, error) {
+	if r.Store == nil {
+		return nil, nil
+	}
+
+	artifacts := []v1beta1.ArtifactStatus{}
+	for _, artifact := range revision.Status.Artifacts {
+		prefix, err := server.ArtifactRecordsPrefix(revision.Namespace, revision.Name, artifact.Job)
+		if err != nil {
+			r.Log.Info("Ignoring unrecorded artifact", "artifact", artifact.Name, "error", err.Error())
+			continue
+		}
+
+		reader, err := r.Store.Get(ctx, prefix+"/"+artifact.Name+".json")
+		if err == storage.ErrNotFound {
+			r.Log.Info("Ignoring unrecorded artifact", "artifact", artifact.Name)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var record v1beta1.ArtifactStatus
+		err = json.NewDecoder(reader).Decode(&record)
+		reader.Close()
+		if err != nil || record.Name != artifact.Name || record.Digest != artifact.Digest {
+			r.Log.Info("Ignoring unrecorded artifact", "artifact", artifact.Name)
+			continue
+		}
+
+		artifacts = append(artifacts, artifact)
+	}
+
+	return artifacts, nil
+}
+
+// hasArtifacts reports whether the build pods of a pipeline upload artifacts
+func hasArtifacts(pipeline v1beta1.Pipeline) bool {
+	return len(pipeline.Artifacts) > 0 || pipeline.SBOM != nil
+}
+
+// hasUploads reports whether the build pods of a pipeline upload files
+func hasUploads(pipeline v1beta1.Pipeline) bool {
+	return hasArtifacts(pipeline) || len(pipeline.Reports) > 0 || pipeline.Coverage != nil
+}
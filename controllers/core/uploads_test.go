@@ -0,0 +1,190 @@
+/*
+Unlicensed
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/server"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+func TestCollectArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hedron-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &storage.FileStore{Dir: dir}
+	reconciler := RevisionReconciler{Store: store}
+
+	project := testProject()
+	revision := testRevision(project)
+	cell := v1beta1.CellStatus{Name: "linux", Job: revision.Name + "-linux"}
+
+	prefix, err := server.ArtifactRecordsPrefix(revision.Namespace, revision.Name, cell.Job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := map[string]string{
+		"app.tar.gz.json": `{"name":"app.tar.gz","job":"app-0123abc-linux","size":8,"digest":"sha256:0123"}`,
+		"sbom-app.json":   `{"name":"sbom-app.json","job":"app-0123abc-linux","size":2,"digest":"sha256:4567"}`,
+	}
+	for name, record := range records {
+		if _, err := store.Put(context.Background(), prefix+"/"+name, strings.NewReader(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), contextKeyRevision, revision)
+	ctx = context.WithValue(ctx, contextKeyCell, cell)
+
+	artifacts, err := reconciler.collectArtifacts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []v1beta1.ArtifactStatus{
+		{Name: "app.tar.gz", Cell: "linux", Job: "app-0123abc-linux", Size: 8, Digest: "sha256:0123"},
+		{Name: "sbom-app.json", Cell: "linux", Job: "app-0123abc-linux", Size: 2, Digest: "sha256:4567"},
+	}
+	if !reflect.DeepEqual(artifacts, expected) {
+		t.Errorf("expected %v, got %v", expected, artifacts)
+	}
+}
+
+// failingStore fails to list objects while failing is set
+type failingStore struct {
+	storage.Store
+	failing bool
+}
+
+func (s *failingStore) List(ctx context.Context, prefix string) ([]storage.Object, error) {
+	if s.failing {
+		return nil, errors.New("storage unavailable")
+	}
+
+	return s.Store.List(ctx, prefix)
+}
+
+func TestReconcileRetriesArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hedron-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	project := testProject()
+	project.Spec.Pipeline.Artifacts = []v1beta1.Artifact{{Name: "app.tar.gz", Paths: []string{"dist"}}}
+	revision := testRevision(project)
+	cell := v1beta1.CellStatus{Name: "build", Job: revision.Name + "-build", State: "Pending"}
+	revision.Status = v1beta1.RevisionStatus{State: "Pending", Cells: []v1beta1.CellStatus{cell}}
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: revision.Namespace, Name: cell.Job},
+		Status:     batchv1.JobStatus{Succeeded: 1},
+	}
+
+	store := &failingStore{Store: &storage.FileStore{Dir: dir}, failing: true}
+	prefix, err := server.ArtifactRecordsPrefix(revision.Namespace, revision.Name, cell.Job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := `{"name":"app.tar.gz","job":"app-0123abc-build","size":8,"digest":"sha256:0123"}`
+	if _, err := store.Put(context.Background(), prefix+"/app.tar.gz.json", strings.NewReader(record)); err != nil {
+		t.Fatal(err)
+	}
+
+	reconciler := RevisionReconciler{
+		Client: fake.NewFakeClientWithScheme(testScheme(t), &project, &revision, &job),
+		Log:    logf.NullLogger{},
+		Store:  store,
+	}
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: revision.Namespace, Name: revision.Name}}
+
+	if _, err := reconciler.Reconcile(request); err == nil {
+		t.Error("expected an error while the artifacts cannot be collected")
+	}
+
+	var reconciled v1beta1.Revision
+	if err := reconciler.Get(context.Background(), request.NamespacedName, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.Status.State != "Pending" || reconciled.Status.Cells[0].State != "Pending" {
+		t.Errorf("expected the revision to stay pending, got %v", reconciled.Status)
+	}
+
+	store.failing = false
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reconciler.Get(context.Background(), request.NamespacedName, &reconciled); err != nil {
+		t.Fatal(err)
+	}
+	if reconciled.Status.State != "Succeeded" || len(reconciled.Status.Artifacts) != 1 {
+		t.Errorf("expected the revision to succeed with its artifact, got %v", reconciled.Status)
+	}
+}
+
+func TestRecordedArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hedron-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &storage.FileStore{Dir: dir}
+	reconciler := RevisionReconciler{Log: logf.NullLogger{}, Store: store}
+
+	project := testProject()
+	revision := testRevision(project)
+	job := revision.Name + "-linux"
+
+	prefix, err := server.ArtifactRecordsPrefix(revision.Namespace, revision.Name, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := map[string]string{
+		"app.tar.gz.json": `{"name":"app.tar.gz","job":"app-0123abc-linux","size":8,"digest":"sha256:0123"}`,
+		"lib.tar.gz.json": `{"name":"lib.tar.gz","job":"app-0123abc-linux","size":8,"digest":"sha256:4567"}`,
+	}
+	for name, record := range records {
+		if _, err := store.Put(context.Background(), prefix+"/"+name, strings.NewReader(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revision.Status.Artifacts = []v1beta1.ArtifactStatus{
+		{Name: "app.tar.gz", Cell: "linux", Job: job, Size: 8, Digest: "sha256:0123"},
+		{Name: "lib.tar.gz", Cell: "linux", Job: job, Size: 8, Digest: "sha256:89ab"},
+		{Name: "forged.tar.gz", Cell: "linux", Job: job, Size: 8, Digest: "sha256:cdef"},
+		{Name: "app.tar.gz", Cell: "linux", Job: "../other", Size: 8, Digest: "sha256:0123"},
+	}
+
+	artifacts, err := reconciler.recordedArtifacts(context.Background(), revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := revision.Status.Artifacts[:1]
+	if !reflect.DeepEqual(artifacts, expected) {
+		t.Errorf("expected %v, got %v", expected, artifacts)
+	}
+}
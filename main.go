@@ -5,17 +5,31 @@ Unlicensed
 package main
 
 import (
+	"crypto/rand"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
+	corev1 "github.com/thmzlt/hedron/apis/core/v1"
 	corev1beta1 "github.com/thmzlt/hedron/apis/core/v1beta1"
 	corecontroller "github.com/thmzlt/hedron/controllers/core"
+	"github.com/thmzlt/hedron/pkg/flaky"
+	"github.com/thmzlt/hedron/pkg/mirror"
+	"github.com/thmzlt/hedron/pkg/server"
+	"github.com/thmzlt/hedron/pkg/storage"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -28,20 +42,85 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = corev1beta1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var mirrorDir string
+	var mirrorMaxBytes int64
+	var runnerImage string
+	var imageBuilderImage string
+	var sbomImage string
+	var nixImage string
+	var builderID string
+	var serverAddr string
+	var storageDir string
+	var storageBackend string
+	var s3Endpoint string
+	var s3Bucket string
+	var s3Region string
+	var serverURL string
+	var tokenKeyFile string
+	var cacheQuotaBytes int64
+	var uploadLimitBytes int64
+	var flakyInterval time.Duration
+	var flakyWindow int
+	var projectDefaults string
+	var buildServiceAccounts string
+	var managerUsername string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&mirrorDir, "mirror-dir", filepath.Join(os.TempDir(), "hedron-mirrors"),
+		"The directory where bare mirrors of project repositories are kept.")
+	flag.Int64Var(&mirrorMaxBytes, "mirror-max-bytes", 5<<30,
+		"The size above which least recently used repository mirrors are evicted.")
+	flag.StringVar(&runnerImage, "runner-image", "ghcr.io/thmzlt/hedron-runner:latest",
+		"The image used for checkouts and as the default build image.")
+	flag.StringVar(&imageBuilderImage, "image-builder-image", "gcr.io/kaniko-project/executor:debug",
+		"The image used to build and push the images of pipelines.")
+	flag.StringVar(&sbomImage, "sbom-image", "anchore/syft:debug", "The image used to generate the SBOMs of pipelines.")
+	flag.StringVar(&builderID, "builder-id", "https://github.com/thmzlt/hedron",
+		"The builder identity recorded in the provenance of revisions.")
+	flag.StringVar(&nixImage, "nix-image", "nixos/nix:latest", "The image used to build projects with Nix.")
+	flag.StringVar(&serverAddr, "server-addr", ":8082", "The address the server of build logs and caches binds to.")
+	flag.StringVar(&storageDir, "storage-dir", filepath.Join(os.TempDir(), "hedron-storage"),
+		"The directory where build outputs are stored with the file storage.")
+	flag.StringVar(&storageBackend, "storage", "file", "Where build outputs are stored: file or s3.")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "https://s3.amazonaws.com",
+		"The endpoint of the S3-compatible service used by the s3 storage. "+
+			"Credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.")
+	flag.StringVar(&s3Bucket, "s3-bucket", "", "The bucket used by the s3 storage.")
+	flag.StringVar(&s3Region, "s3-region", "us-east-1", "The region of the bucket used by the s3 storage.")
+	flag.StringVar(&serverURL, "server-url", "http://hedron-controller-manager.hedron-system.svc",
+		"The URL build pods reach the server at.")
+	flag.StringVar(&tokenKeyFile, "token-key-file", "",
+		"A file with the key build pod tokens are signed with, random when unset, "+
+			"which invalidates the tokens of running builds on restart.")
+	flag.Int64Var(&cacheQuotaBytes, "cache-quota-bytes", 1<<30,
+		"The size above which least recently used build caches of a project are evicted.")
+	flag.Int64Var(&uploadLimitBytes, "upload-limit-bytes", 1<<30,
+		"The size of the largest artifact or report build pods may upload.")
+	flag.DurationVar(&flakyInterval, "flaky-interval", 5*time.Minute, "How often flaky tests are detected.")
+	flag.IntVar(&flakyWindow, "flaky-window", 50, "The number of revisions of a project flaky tests are detected in.")
+	flag.StringVar(&projectDefaults, "project-defaults", "hedron-system/hedron-defaults",
+		"The namespace/name of the ConfigMap with the image, pollInterval, timeout and retention defaults of projects.")
+	flag.StringVar(&buildServiceAccounts, "build-service-accounts", "",
+		"The comma-separated service accounts the pod templates of projects may run builds as.")
+	flag.StringVar(&managerUsername, "manager-username", "system:serviceaccount:hedron-system:default",
+		"The user the manager authenticates as, the only one allowed to create revisions controlled by projects.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	if buildServiceAccounts != "" {
+		corev1beta1.BuildServiceAccounts = strings.Split(buildServiceAccounts, ",")
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -54,27 +133,147 @@ func main() {
 		os.Exit(1)
 	}
 
+	mirrors, err := mirror.NewCache(mirrorDir, mirrorMaxBytes, ctrl.Log.WithName("mirror"))
+	if err != nil {
+		setupLog.Error(err, "unable to create mirror cache")
+		os.Exit(1)
+	}
+
+	var store storage.Store
+	switch storageBackend {
+	case "file":
+		store = &storage.FileStore{Dir: storageDir}
+	case "s3":
+		store = &storage.S3Store{
+			Endpoint:        s3Endpoint,
+			Bucket:          s3Bucket,
+			Region:          s3Region,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+	default:
+		setupLog.Error(fmt.Errorf("unknown storage %q", storageBackend), "unable to create storage")
+		os.Exit(1)
+	}
+
+	tokens := server.Tokens{Key: make([]byte, 32)}
+	if tokenKeyFile != "" {
+		tokens.Key, err = ioutil.ReadFile(tokenKeyFile)
+	} else {
+		_, err = rand.Read(tokens.Key)
+	}
+	if err != nil {
+		setupLog.Error(err, "unable to load token key")
+		os.Exit(1)
+	}
+
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create clientset")
+		os.Exit(1)
+	}
+
 	if err = (&corecontroller.ProjectReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Project"),
-		Scheme: mgr.GetScheme(),
+		Client:  mgr.GetClient(),
+		Log:     ctrl.Log.WithName("controllers").WithName("Project"),
+		Scheme:  mgr.GetScheme(),
+		Mirrors: mirrors,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Project")
 		os.Exit(1)
 	}
 	if err = (&corecontroller.RevisionReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Revision"),
-		Scheme: mgr.GetScheme(),
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("Revision"),
+		Scheme:      mgr.GetScheme(),
+		Mirrors:     mirrors,
+		RunnerImage: runnerImage,
+		Store:       store,
+		Clientset:   clientset,
+		ServerURL:   serverURL,
+		Tokens:      tokens,
+
+		BuilderID:         builderID,
+		NixImage:          nixImage,
+		ImageBuilderImage: imageBuilderImage,
+		SBOMImage:         sbomImage,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Revision")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&corev1beta1.Project{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Project")
+			os.Exit(1)
+		}
+		if err = (&corev1beta1.Revision{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Revision")
+			os.Exit(1)
+		}
+		if err = (&corev1beta1.ProjectTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ProjectTemplate")
+			os.Exit(1)
+		}
+
+		defaultsNamespace, defaultsName := splitNamespacedName(projectDefaults)
+		if err = (&corecontroller.ProjectDefaulter{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("webhooks").WithName("Project"),
+			Defaults: types.NamespacedName{Namespace: defaultsNamespace, Name: defaultsName},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ProjectDefaulter")
+			os.Exit(1)
+		}
+		if err = (&corecontroller.RevisionOwnerValidator{
+			Username: managerUsername,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "RevisionOwnerValidator")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	analyzer := &flaky.Analyzer{
+		Client:   mgr.GetClient(),
+		Store:    store,
+		Log:      ctrl.Log.WithName("flaky"),
+		Interval: flakyInterval,
+		Window:   flakyWindow,
+	}
+	if err = mgr.Add(analyzer); err != nil {
+		setupLog.Error(err, "unable to add flaky test analyzer")
+		os.Exit(1)
+	}
+	metrics.Registry.MustRegister(analyzer)
+
+	if err = mgr.Add(&server.Server{
+		Addr:        serverAddr,
+		Client:      mgr.GetClient(),
+		Clientset:   clientset,
+		Store:       store,
+		Tokens:      tokens,
+		Flaky:       analyzer,
+		Log:         ctrl.Log.WithName("server"),
+		CacheQuota:  cacheQuotaBytes,
+		UploadLimit: uploadLimitBytes,
+	}); err != nil {
+		setupLog.Error(err, "unable to add server")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// splitNamespacedName splits namespace/name, the namespace defaulting to
+// hedron-system
+func splitNamespacedName(namespacedName string) (string, string) {
+	if i := strings.Index(namespacedName, "/"); i >= 0 {
+		return namespacedName[:i], namespacedName[i+1:]
+	}
+
+	return "hedron-system", namespacedName
+}
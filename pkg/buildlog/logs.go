@@ -0,0 +1,54 @@
+/*
+Unlicensed
+*/
+
+package buildlog
+
+import (
+	"context"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// Key returns where the log of a container of a build job is stored
+func Key(namespace string, revision string, job string, container string) (string, error) {
+	return storage.Key(namespace, revision, "logs", job, container+".log")
+}
+
+// Stream opens the log of a container of a pod
+func Stream(ctx context.Context, clientset kubernetes.Interface, namespace string, pod string, container string, follow bool) (io.ReadCloser, error) {
+	return clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		Follow:    follow,
+	}).Context(ctx).Stream()
+}
+
+// Capture stores the redacted log of a container of a finished pod under key
+func Capture(ctx context.Context, clientset kubernetes.Interface, store storage.Store, pod corev1.Pod, container string, key string, secrets []string) error {
+	stream, err := Stream(ctx, clientset, pod.Namespace, pod.Name, container, false)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	reader, writer := io.Pipe()
+
+	go func() {
+		masker := NewMasker(writer, secrets)
+
+		_, err := io.Copy(masker, stream)
+		if err == nil {
+			err = masker.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	_, err = store.Put(ctx, key, reader)
+	reader.Close()
+
+	return err
+}
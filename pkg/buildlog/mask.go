@@ -0,0 +1,157 @@
+/*
+Unlicensed
+*/
+
+// Package buildlog captures the logs of build pods and redacts the secrets
+// injected into builds before they are stored or streamed.
+package buildlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Mask replaces secret values in logs
+const Mask = "***"
+
+// minSecretLength is the length below which values are not masked, as they
+// would mask too much unrelated output
+const minSecretLength = 4
+
+// Masker is a writer that redacts secret values before passing output on.
+// Values may be split across writes, so output that could be the beginning
+// of a secret is held back until the next write or Close.
+type Masker struct {
+	writer  io.Writer
+	secrets [][]byte
+	buffer  []byte
+}
+
+// NewMasker returns a Masker writing to writer that redacts the given values
+// along with their base64 and URL-encoded forms
+func NewMasker(writer io.Writer, values []string) *Masker {
+	unique := map[string]bool{}
+	for _, value := range values {
+		for _, variant := range variants(value) {
+			if len(variant) >= minSecretLength {
+				unique[variant] = true
+			}
+		}
+	}
+
+	secrets := make([][]byte, 0, len(unique))
+	for secret := range unique {
+		secrets = append(secrets, []byte(secret))
+	}
+
+	// Match the longest values first so that values starting with other
+	// values are entirely masked
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return bytes.Compare(secrets[i], secrets[j]) < 0
+	})
+
+	return &Masker{
+		writer:  writer,
+		secrets: secrets,
+	}
+}
+
+// variants returns the forms a secret value may appear in within logs
+func variants(value string) []string {
+	forms := []string{
+		value,
+		base64.StdEncoding.EncodeToString([]byte(value)),
+		base64.RawStdEncoding.EncodeToString([]byte(value)),
+		base64.URLEncoding.EncodeToString([]byte(value)),
+		base64.RawURLEncoding.EncodeToString([]byte(value)),
+		url.QueryEscape(value),
+		url.PathEscape(value),
+	}
+
+	// Multi-line values such as keys are usually printed line by line
+	if strings.Contains(value, "\n") {
+		for _, line := range strings.Split(value, "\n") {
+			forms = append(forms, strings.TrimSpace(line))
+		}
+	}
+
+	return forms
+}
+
+// Write redacts and writes the part of the output that can no longer be the
+// beginning of a secret
+func (m *Masker) Write(p []byte) (int, error) {
+	if len(m.secrets) == 0 {
+		return m.writer.Write(p)
+	}
+
+	m.buffer = append(m.buffer, p...)
+	if err := m.flush(false); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close redacts and writes the output held back
+func (m *Masker) Close() error {
+	return m.flush(true)
+}
+
+// flush writes the redacted buffer up to the first position where a secret
+// may continue in the next write, or all of it when final is set
+func (m *Masker) flush(final bool) error {
+	output := make([]byte, 0, len(m.buffer))
+
+	i := 0
+scan:
+	for i < len(m.buffer) {
+		rest := m.buffer[i:]
+		partial := false
+
+		for _, secret := range m.secrets {
+			if len(rest) < len(secret) {
+				if !final && bytes.HasPrefix(secret, rest) {
+					partial = true
+				}
+				continue
+			}
+			if !bytes.HasPrefix(rest, secret) {
+				continue
+			}
+
+			// A longer secret starting with this one may still match
+			if partial {
+				break scan
+			}
+
+			output = append(output, Mask...)
+			i += len(secret)
+			continue scan
+		}
+
+		if partial {
+			break
+		}
+
+		output = append(output, m.buffer[i])
+		i++
+	}
+
+	m.buffer = append(m.buffer[:0], m.buffer[i:]...)
+
+	if len(output) == 0 {
+		return nil
+	}
+
+	_, err := m.writer.Write(output)
+
+	return err
+}
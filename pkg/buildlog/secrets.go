@@ -0,0 +1,158 @@
+/*
+Unlicensed
+*/
+
+package buildlog
+
+import (
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// SecretValues returns the values of all the secrets that can be injected
+// into the builds of a revision, which must not appear in their logs
+func SecretValues(ctx context.Context, c client.Reader, project v1beta1.Project, revision v1beta1.Revision) ([]string, error) {
+	collector := secretCollector{
+		ctx:       ctx,
+		client:    c,
+		namespace: project.Namespace,
+		secrets:   map[string]corev1.Secret{},
+	}
+
+	if project.Spec.Repository.SecretRef != nil {
+		collector.addSecret(project.Spec.Repository.SecretRef.Name)
+	}
+
+	for _, envVar := range project.Spec.Env {
+		collector.addEnvVar(envVar.EnvVar)
+	}
+	for _, source := range project.Spec.EnvFrom {
+		if source.SecretRef != nil {
+			collector.addSecret(source.SecretRef.Name)
+		}
+	}
+	for _, secretMount := range project.Spec.SecretMounts {
+		collector.addSecret(secretMount.SecretName)
+	}
+
+	pipeline := project.Spec.Pipeline
+	if revision.Spec.Pipeline != nil {
+		pipeline = *revision.Spec.Pipeline
+	}
+	for _, service := range pipeline.Services {
+		for _, envVar := range service.Env {
+			collector.addEnvVar(envVar)
+		}
+	}
+	for _, image := range pipeline.Images {
+		if image.SecretRef != nil {
+			collector.addSecret(image.SecretRef.Name)
+		}
+	}
+
+	if nix := project.Spec.Nix; nix != nil {
+		if nix.CacheSigningKey != nil {
+			collector.addSecret(nix.CacheSigningKey.Name)
+		}
+		if nix.BinaryCache != nil {
+			collector.addSecret(nix.BinaryCache.SigningKey.Name)
+			if nix.BinaryCache.CredentialsSecretRef != nil {
+				collector.addSecret(nix.BinaryCache.CredentialsSecretRef.Name)
+			}
+		}
+	}
+	if project.Spec.Signing != nil {
+		collector.addSecret(project.Spec.Signing.SecretRef.Name)
+	}
+	if project.Spec.Provenance != nil {
+		collector.addSecret(project.Spec.Provenance.SigningKey.Name)
+	}
+
+	return collector.values, collector.err
+}
+
+type secretCollector struct {
+	ctx       context.Context
+	client    client.Reader
+	namespace string
+	secrets   map[string]corev1.Secret
+	values    []string
+	err       error
+}
+
+func (c *secretCollector) fetchSecret(name string) (corev1.Secret, bool) {
+	if secret, ok := c.secrets[name]; ok {
+		return secret, true
+	}
+
+	var secret corev1.Secret
+	if err := c.client.Get(c.ctx, client.ObjectKey{Namespace: c.namespace, Name: name}, &secret); err != nil {
+		// Secrets that do not exist cannot be injected either
+		if client.IgnoreNotFound(err) != nil && c.err == nil {
+			c.err = err
+		}
+		return secret, false
+	}
+	c.secrets[name] = secret
+
+	return secret, true
+}
+
+func (c *secretCollector) addSecret(name string) {
+	if _, ok := c.secrets[name]; ok {
+		return
+	}
+
+	secret, ok := c.fetchSecret(name)
+	if !ok {
+		return
+	}
+	for _, value := range secret.Data {
+		c.values = append(c.values, string(value))
+	}
+	if secret.Type == corev1.SecretTypeDockerConfigJson {
+		c.addDockerConfig(secret.Data[corev1.DockerConfigJsonKey])
+	}
+}
+
+// addDockerConfig adds the credentials of a registry configuration, which
+// builds may print on their own
+func (c *secretCollector) addDockerConfig(data []byte) {
+	var config struct {
+		Auths map[string]struct {
+			Password      string `json:"password"`
+			Auth          string `json:"auth"`
+			IdentityToken string `json:"identitytoken"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return
+	}
+
+	for _, auth := range config.Auths {
+		for _, value := range []string{auth.Password, auth.Auth, auth.IdentityToken} {
+			if value != "" {
+				c.values = append(c.values, value)
+			}
+		}
+	}
+}
+
+func (c *secretCollector) addEnvVar(envVar corev1.EnvVar) {
+	if envVar.ValueFrom == nil || envVar.ValueFrom.SecretKeyRef == nil {
+		return
+	}
+
+	secret, ok := c.fetchSecret(envVar.ValueFrom.SecretKeyRef.Name)
+	if !ok {
+		return
+	}
+	if value, ok := secret.Data[envVar.ValueFrom.SecretKeyRef.Key]; ok {
+		c.values = append(c.values, string(value))
+	}
+}
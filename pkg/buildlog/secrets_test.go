@@ -0,0 +1,87 @@
+/*
+Unlicensed
+*/
+
+package buildlog
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+func testSecret(name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Data:       map[string][]byte{},
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+
+	return secret
+}
+
+func TestSecretValues(t *testing.T) {
+	registry := testSecret("registry", map[string]string{
+		corev1.DockerConfigJsonKey: `{"auths":{"registry.example.com":{"username":"ci","password":"registry-password","auth":"Y2k6cmVnaXN0cnktcGFzc3dvcmQ="}}}`,
+	})
+	registry.Type = corev1.SecretTypeDockerConfigJson
+
+	c := fake.NewFakeClientWithScheme(clientgoscheme.Scheme,
+		testSecret("env", map[string]string{"token": "env-token"}),
+		registry,
+		testSecret("nix-cache", map[string]string{"secret-key": "nix-cache-key"}),
+		testSecret("nix-s3", map[string]string{"AWS_SECRET_ACCESS_KEY": "nix-s3-secret"}),
+		testSecret("cosign", map[string]string{"cosign.key": "cosign-key", "cosign.password": "cosign-password"}),
+		testSecret("provenance", map[string]string{"key.pem": "provenance-key"}),
+	)
+
+	project := v1beta1.Project{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: v1beta1.ProjectSpec{
+			EnvFrom: []v1beta1.EnvFromSource{{EnvFromSource: corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "env"}},
+			}}},
+			Pipeline: v1beta1.Pipeline{Images: []v1beta1.ImageBuild{{
+				Name:      "app",
+				SecretRef: &corev1.LocalObjectReference{Name: "registry"},
+			}}},
+			Nix: &v1beta1.Nix{
+				CacheSigningKey: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "nix-cache"}, Key: "secret-key"},
+				BinaryCache: &v1beta1.NixBinaryCache{
+					SigningKey:           corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "nix-cache"}, Key: "secret-key"},
+					CredentialsSecretRef: &corev1.LocalObjectReference{Name: "nix-s3"},
+				},
+			},
+			Signing:    &v1beta1.Signing{SecretRef: corev1.LocalObjectReference{Name: "cosign"}},
+			Provenance: &v1beta1.Provenance{SigningKey: corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "provenance"}, Key: "key.pem"}},
+		},
+	}
+
+	values, err := SecretValues(context.Background(), c, project, v1beta1.Revision{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]bool{}
+	for _, value := range values {
+		found[value] = true
+	}
+	for _, expected := range []string{
+		"env-token", "registry-password", "Y2k6cmVnaXN0cnktcGFzc3dvcmQ=", "nix-cache-key",
+		"nix-s3-secret", "cosign-key", "cosign-password", "provenance-key",
+	} {
+		if !found[expected] {
+			sort.Strings(values)
+			t.Errorf("expected %q in %v", expected, values)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Unlicensed
+*/
+
+// Package coverage parses the code coverage reports of builds.
+package coverage
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// Format is the format of a coverage report
+type Format string
+
+const (
+	Go        Format = "go"
+	Cobertura Format = "cobertura"
+	LCOV      Format = "lcov"
+)
+
+// unit is a block of Go statements or a line of code
+type unit struct {
+	weight  int64
+	covered bool
+}
+
+// Profile merges coverage reports: code is covered when any report covers it
+type Profile struct {
+	units map[string]unit
+}
+
+// NewProfile returns an empty profile
+func NewProfile() *Profile {
+	return &Profile{units: map[string]unit{}}
+}
+
+// Totals returns the covered and total statements or lines of a profile
+func (p *Profile) Totals() (covered int64, total int64) {
+	for _, u := range p.units {
+		total += u.weight
+		if u.covered {
+			covered += u.weight
+		}
+	}
+
+	return covered, total
+}
+
+func (p *Profile) add(key string, weight int64, covered bool) {
+	u := p.units[key]
+	u.weight = weight
+	u.covered = u.covered || covered
+	p.units[key] = u
+}
+
+// Parse merges a report into a profile
+func (p *Profile) Parse(format Format, reader io.Reader) error {
+	switch format {
+	case Go:
+		return p.parseGo(reader)
+	case Cobertura:
+		return p.parseCobertura(reader)
+	case LCOV:
+		return p.parseLCOV(reader)
+	}
+
+	return fmt.Errorf("unknown coverage format %q", format)
+}
+
+// parseGo reads go test -coverprofile output, weighted by statements
+func (p *Profile) parseGo(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		// file.go:10.2,12.16 3 1
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid coverage line %q", line)
+		}
+		statements, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid coverage line %q", line)
+		}
+		count, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid coverage line %q", line)
+		}
+
+		p.add(fields[0], statements, count > 0)
+	}
+
+	return scanner.Err()
+}
+
+type coberturaReport struct {
+	Classes []struct {
+		Filename string `xml:"filename,attr"`
+		Lines    []struct {
+			Number string `xml:"number,attr"`
+			Hits   int64  `xml:"hits,attr"`
+		} `xml:"lines>line"`
+	} `xml:"packages>package>classes>class"`
+}
+
+// parseCobertura reads Cobertura XML reports, weighted by lines
+func (p *Profile) parseCobertura(reader io.Reader) error {
+	var report coberturaReport
+	if err := xml.NewDecoder(reader).Decode(&report); err != nil {
+		return err
+	}
+
+	for _, class := range report.Classes {
+		for _, line := range class.Lines {
+			p.add(class.Filename+":"+line.Number, 1, line.Hits > 0)
+		}
+	}
+
+	return nil
+}
+
+// parseLCOV reads LCOV tracefiles, weighted by lines
+func (p *Profile) parseLCOV(reader io.Reader) error {
+	file := ""
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file = strings.TrimPrefix(line, "SF:")
+		case strings.HasPrefix(line, "DA:"):
+			// DA:<line>,<hits>[,<checksum>]
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				return fmt.Errorf("invalid coverage line %q", line)
+			}
+			hits, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid coverage line %q", line)
+			}
+			p.add(file+":"+fields[0], 1, hits > 0)
+		case line == "end_of_record":
+			file = ""
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Percentage returns the share of covered code, 0 when there is none
+func Percentage(covered int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return 100 * float64(covered) / float64(total)
+}
+
+// Key returns where a file of the coverage reports of a build job is stored
+func Key(namespace string, revision string, job string, index int) (string, error) {
+	return storage.Key(namespace, revision, "coverage", job, strconv.Itoa(index))
+}
@@ -0,0 +1,73 @@
+/*
+Unlicensed
+*/
+
+package coverage
+
+import (
+	"strings"
+	"testing"
+)
+
+var parseTests = []struct {
+	name    string
+	format  Format
+	reports []string
+	covered int64
+	total   int64
+}{
+	{
+		name:   "go",
+		format: Go,
+		reports: []string{
+			"mode: set\nexample.com/a/a.go:3.20,5.2 2 1\nexample.com/a/a.go:7.20,9.2 3 0\n",
+			"mode: set\nexample.com/a/a.go:7.20,9.2 3 1\nexample.com/a/b.go:3.20,5.2 5 0\n",
+		},
+		covered: 5,
+		total:   10,
+	},
+	{
+		name:   "cobertura",
+		format: Cobertura,
+		reports: []string{`<?xml version="1.0"?>
+<coverage line-rate="0.5">
+  <packages><package name="app"><classes>
+    <class name="main" filename="app/main.py"><lines>
+      <line number="1" hits="1"/>
+      <line number="2" hits="0"/>
+      <line number="3" hits="4"/>
+    </lines></class>
+  </classes></package></packages>
+</coverage>`},
+		covered: 2,
+		total:   3,
+	},
+	{
+		name:   "lcov",
+		format: LCOV,
+		reports: []string{
+			"TN:\nSF:src/index.js\nDA:1,1\nDA:2,0\nend_of_record\nSF:src/util.js\nDA:1,0\nend_of_record\n",
+			"SF:src/index.js\nDA:2,3\nend_of_record\n",
+		},
+		covered: 2,
+		total:   3,
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range parseTests {
+		t.Run(test.name, func(t *testing.T) {
+			profile := NewProfile()
+			for _, report := range test.reports {
+				if err := profile.Parse(test.format, strings.NewReader(report)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			covered, total := profile.Totals()
+			if covered != test.covered || total != test.total {
+				t.Errorf("got %d/%d, want %d/%d", covered, total, test.covered, test.total)
+			}
+		})
+	}
+}
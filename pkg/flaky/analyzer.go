@@ -0,0 +1,236 @@
+/*
+Unlicensed
+*/
+
+package flaky
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/report"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// maxMetrics bounds the tests of a project exported as metrics
+const maxMetrics = 20
+
+var (
+	flakinessDesc = prometheus.NewDesc(
+		"hedron_test_flakiness",
+		"Flakiness score of the flakiest tests of projects, from 0 to 1.",
+		[]string{"namespace", "project", "cell", "suite", "test"}, nil,
+	)
+	flakyTestsDesc = prometheus.NewDesc(
+		"hedron_flaky_tests",
+		"Number of tests of projects that flipped between passing and failing.",
+		[]string{"namespace", "project"}, nil,
+	)
+)
+
+// Analyzer periodically scores the tests of the last revisions of every
+// project. It is a manager runnable and a Prometheus collector.
+type Analyzer struct {
+	Client   client.Client
+	Store    storage.Store
+	Log      logr.Logger
+	Interval time.Duration
+
+	// Window is the number of revisions of a project analyzed
+	Window int
+
+	mu     sync.RWMutex
+	scores map[types.NamespacedName][]Score
+
+	// results caches the results of finished revisions, which do not change
+	results map[types.UID]map[Test][]report.Status
+}
+
+// Start analyzes projects every interval until stop is closed
+func (a *Analyzer) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.analyze(context.Background()); err != nil {
+			a.Log.Error(err, "Failed to analyze test results")
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection lets every replica serve scores
+func (a *Analyzer) NeedLeaderElection() bool {
+	return false
+}
+
+// Scores returns the ranked scores of a project
+func (a *Analyzer) Scores(namespace string, project string) ([]Score, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	scores, ok := a.scores[types.NamespacedName{Namespace: namespace, Name: project}]
+
+	return scores, ok
+}
+
+func (a *Analyzer) Describe(descs chan<- *prometheus.Desc) {
+	descs <- flakinessDesc
+	descs <- flakyTestsDesc
+}
+
+func (a *Analyzer) Collect(metrics chan<- prometheus.Metric) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for project, scores := range a.scores {
+		metrics <- prometheus.MustNewConstMetric(flakyTestsDesc, prometheus.GaugeValue, float64(len(scores)), project.Namespace, project.Name)
+
+		for i, score := range scores {
+			if i == maxMetrics {
+				break
+			}
+			metrics <- prometheus.MustNewConstMetric(flakinessDesc, prometheus.GaugeValue, score.Score,
+				project.Namespace, project.Name, score.Cell, score.Suite, score.Name)
+		}
+	}
+}
+
+func (a *Analyzer) analyze(ctx context.Context) error {
+	var projects v1beta1.ProjectList
+	if err := a.Client.List(ctx, &projects); err != nil {
+		return err
+	}
+
+	var revisions v1beta1.RevisionList
+	if err := a.Client.List(ctx, &revisions); err != nil {
+		return err
+	}
+
+	byProject := map[types.NamespacedName][]v1beta1.Revision{}
+	for _, revision := range revisions.Items {
+		if revision.Status.State != "Succeeded" && revision.Status.State != "Failed" {
+			continue
+		}
+
+		project := types.NamespacedName{Namespace: revision.Namespace, Name: revision.Spec.ProjectRef.Name}
+		byProject[project] = append(byProject[project], revision)
+	}
+
+	scores := map[types.NamespacedName][]Score{}
+	seen := map[types.UID]bool{}
+
+	for _, project := range projects.Items {
+		name := types.NamespacedName{Namespace: project.Namespace, Name: project.Name}
+
+		projectRevisions := byProject[name]
+		sort.Slice(projectRevisions, func(i, j int) bool {
+			return projectRevisions[i].Spec.BuildNumber > projectRevisions[j].Spec.BuildNumber
+		})
+		if len(projectRevisions) > a.Window {
+			projectRevisions = projectRevisions[:a.Window]
+		}
+
+		history := map[Test][]Outcome{}
+		for _, revision := range projectRevisions {
+			seen[revision.UID] = true
+
+			results, err := a.revisionResults(ctx, revision)
+			if err != nil {
+				a.Log.Error(err, "Failed to read test results", "revision", revision.Name)
+				continue
+			}
+
+			for test, statuses := range results {
+				for _, status := range statuses {
+					history[test] = append(history[test], Outcome{
+						Revision:    revision.Name,
+						Commit:      revision.Spec.Revision,
+						BuildNumber: revision.Spec.BuildNumber,
+						Status:      status,
+					})
+				}
+			}
+		}
+
+		scores[name] = Analyze(history)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.scores = scores
+	for uid := range a.results {
+		if !seen[uid] {
+			delete(a.results, uid)
+		}
+	}
+
+	return nil
+}
+
+// revisionResults reads the results of the tests of a finished revision,
+// which may have run more than once
+func (a *Analyzer) revisionResults(ctx context.Context, revision v1beta1.Revision) (map[Test][]report.Status, error) {
+	a.mu.RLock()
+	cached, ok := a.results[revision.UID]
+	a.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	results := map[Test][]report.Status{}
+	for _, cell := range revision.Status.Cells {
+		if cell.Job == "" {
+			continue
+		}
+
+		key, err := report.ResultsKey(revision.Namespace, revision.Name, cell.Job)
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := a.Store.Get(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var cellResults []report.Result
+		err = json.NewDecoder(reader).Decode(&cellResults)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range cellResults {
+			test := Test{Cell: cell.Name, Suite: result.Suite, Name: result.Name}
+			results[test] = append(results[test], result.Status)
+		}
+	}
+
+	a.mu.Lock()
+	if a.results == nil {
+		a.results = map[types.UID]map[Test][]report.Status{}
+	}
+	a.results[revision.UID] = results
+	a.mu.Unlock()
+
+	return results, nil
+}
@@ -0,0 +1,104 @@
+/*
+Unlicensed
+*/
+
+// Package flaky scores how often tests flip between passing and failing
+// across the revisions of a project.
+package flaky
+
+import (
+	"sort"
+
+	"github.com/thmzlt/hedron/pkg/report"
+)
+
+// Test identifies a test across revisions
+type Test struct {
+	Cell  string `json:"cell,omitempty"`
+	Suite string `json:"suite,omitempty"`
+	Name  string `json:"name"`
+}
+
+// Outcome is the result of a test in one revision
+type Outcome struct {
+	Revision    string
+	Commit      string
+	BuildNumber int64
+	Status      report.Status
+}
+
+// Score is the flakiness of a test, from 0 for tests whose outcome never
+// changed to 1 for tests whose outcome changed on every run
+type Score struct {
+	Test
+
+	Runs  int `json:"runs"`
+	Flips int `json:"flips"`
+
+	// SameCommitFlips are flips between runs of the same commit, which
+	// cannot come from code changes
+	SameCommitFlips int     `json:"sameCommitFlips"`
+	Score           float64 `json:"score"`
+}
+
+// Analyze scores the tests that flipped at least once. Outcomes are ordered
+// by build number; flips between runs of the same commit count double.
+// Scores are ranked from the flakiest test.
+func Analyze(history map[Test][]Outcome) []Score {
+	scores := []Score{}
+
+	for test, outcomes := range history {
+		runs := []Outcome{}
+		for _, outcome := range outcomes {
+			if outcome.Status == report.Passed || outcome.Status == report.Failed {
+				runs = append(runs, outcome)
+			}
+		}
+		if len(runs) < 2 {
+			continue
+		}
+
+		sort.SliceStable(runs, func(i, j int) bool {
+			return runs[i].BuildNumber < runs[j].BuildNumber
+		})
+
+		score := Score{Test: test, Runs: len(runs)}
+		for i := 1; i < len(runs); i++ {
+			if runs[i].Status == runs[i-1].Status {
+				continue
+			}
+			score.Flips++
+			if runs[i].Commit == runs[i-1].Commit {
+				score.SameCommitFlips++
+			}
+		}
+		if score.Flips == 0 {
+			continue
+		}
+
+		score.Score = float64(score.Flips+score.SameCommitFlips) / float64(len(runs)-1)
+		if score.Score > 1 {
+			score.Score = 1
+		}
+
+		scores = append(scores, score)
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		if scores[i].Flips != scores[j].Flips {
+			return scores[i].Flips > scores[j].Flips
+		}
+		if scores[i].Cell != scores[j].Cell {
+			return scores[i].Cell < scores[j].Cell
+		}
+		if scores[i].Suite != scores[j].Suite {
+			return scores[i].Suite < scores[j].Suite
+		}
+		return scores[i].Name < scores[j].Name
+	})
+
+	return scores
+}
@@ -0,0 +1,51 @@
+/*
+Unlicensed
+*/
+
+package flaky
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thmzlt/hedron/pkg/report"
+)
+
+func outcomes(commits string, statuses string) []Outcome {
+	outcomes := []Outcome{}
+	for i := range statuses {
+		status := report.Passed
+		switch statuses[i] {
+		case 'F':
+			status = report.Failed
+		case 'S':
+			status = report.Skipped
+		}
+		outcomes = append(outcomes, Outcome{Commit: commits[i : i+1], BuildNumber: int64(i), Status: status})
+	}
+
+	return outcomes
+}
+
+func TestAnalyze(t *testing.T) {
+	stable := Test{Name: "TestStable"}
+	fixed := Test{Name: "TestFixed"}
+	flaky := Test{Name: "TestFlaky"}
+	retried := Test{Name: "TestRetried"}
+
+	scores := Analyze(map[Test][]Outcome{
+		stable:  outcomes("abcd", "PPSP"),
+		fixed:   outcomes("abcd", "FFPP"),
+		flaky:   outcomes("abcd", "PFPF"),
+		retried: outcomes("aabb", "FPPP"),
+	})
+
+	want := []Score{
+		{Test: flaky, Runs: 4, Flips: 3, Score: 1},
+		{Test: retried, Runs: 4, Flips: 1, SameCommitFlips: 1, Score: 2.0 / 3},
+		{Test: fixed, Runs: 4, Flips: 1, Score: 1.0 / 3},
+	}
+	if !reflect.DeepEqual(scores, want) {
+		t.Errorf("got %+v, want %+v", scores, want)
+	}
+}
@@ -0,0 +1,410 @@
+/*
+Unlicensed
+*/
+
+// Package mirror maintains persistent bare mirrors of remote Git repositories
+// so that controllers can resolve refs and read commits without cloning.
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-logr/logr"
+)
+
+// refSpecs mirror every branch and tag of the remote
+var refSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// Remote identifies a repository and how its contents are read
+type Remote struct {
+	URL  string
+	Auth transport.AuthMethod
+
+	// SubmoduleDepth is how many levels of submodules file reads descend
+	// into, or -1 to descend into all of them.
+	SubmoduleDepth int
+
+	// LFS makes file reads replace Git LFS pointers with the object content
+	LFS bool
+}
+
+// Cache keeps one bare mirror per repository URL under Dir and evicts the
+// least recently used mirrors once their total size exceeds MaxBytes.
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+	Log      logr.Logger
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	evictMu sync.Mutex
+}
+
+// evictedPrefix names the directories mirrors are moved to before removal
+const evictedPrefix = ".evicted-"
+
+type entry struct {
+	sync.RWMutex
+
+	dir      string
+	users    int
+	lastUsed time.Time
+}
+
+// NewCache creates the cache directory and registers mirrors left over by
+// previous runs so they are reused and accounted for eviction.
+func NewCache(dir string, maxBytes int64, log logr.Logger) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	cache := &Cache{
+		Dir:      dir,
+		MaxBytes: maxBytes,
+		Log:      log,
+		entries:  map[string]*entry{},
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(info.Name(), evictedPrefix) {
+			os.RemoveAll(filepath.Join(dir, info.Name()))
+			continue
+		}
+		cache.entries[info.Name()] = &entry{
+			dir:      filepath.Join(dir, info.Name()),
+			lastUsed: info.ModTime(),
+		}
+	}
+
+	return cache, nil
+}
+
+// Resolve fetches the remote and returns the commit ref currently points to
+func (c *Cache) Resolve(ctx context.Context, remote Remote, ref string) (*object.Commit, error) {
+	e, repo, err := c.update(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(e)
+
+	e.RLock()
+	defer e.RUnlock()
+
+	reference, err := repo.Reference(plumbing.ReferenceName(ref), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.CommitObject(reference.Hash())
+}
+
+// DefaultBranch returns the ref HEAD of the remote points to, such as
+// refs/heads/main, listing the remote refs without fetching. go-git cannot
+// cancel listing, so the listing is abandoned when ctx is done.
+func DefaultBranch(ctx context.Context, remote Remote) (string, error) {
+	type result struct {
+		references []*plumbing.Reference
+		err        error
+	}
+	results := make(chan result, 1)
+
+	go func() {
+		list := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+			Name: git.DefaultRemoteName,
+			URLs: []string{remote.URL},
+		})
+
+		references, err := list.List(&git.ListOptions{Auth: remote.Auth})
+		results <- result{references: references, err: err}
+	}()
+
+	var listed result
+	select {
+	case listed = <-results:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if listed.err != nil {
+		return "", listed.err
+	}
+
+	for _, reference := range listed.references {
+		if reference.Name() == plumbing.HEAD && reference.Type() == plumbing.SymbolicReference {
+			return reference.Target().String(), nil
+		}
+	}
+
+	return "", errors.New("remote HEAD is not a branch")
+}
+
+// Commit returns the commit identified by hash, fetching the remote when the
+// mirror does not contain it yet.
+func (c *Cache) Commit(ctx context.Context, remote Remote, hash string) (*object.Commit, error) {
+	e, repo, err := c.open(remote.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(e)
+
+	e.RLock()
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	e.RUnlock()
+	if err == nil {
+		return commit, nil
+	}
+
+	if err = c.fetch(ctx, e, repo, remote.Auth); err != nil {
+		return nil, err
+	}
+
+	e.RLock()
+	defer e.RUnlock()
+
+	return repo.CommitObject(plumbing.NewHash(hash))
+}
+
+// ReadFile returns the contents of path in the tree of the commit identified
+// by hash, descending into submodules and resolving LFS pointers as
+// configured on the remote.
+func (c *Cache) ReadFile(ctx context.Context, remote Remote, hash string, path string) ([]byte, error) {
+	commit, err := c.Commit(ctx, remote, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	if remote.SubmoduleDepth != 0 {
+		submodule, err := findSubmodule(tree, remote, path)
+		if err != nil {
+			return nil, err
+		}
+		if submodule != nil {
+			return c.ReadFile(ctx, submodule.remote, submodule.hash, submodule.path)
+		}
+	}
+
+	file, err := tree.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	if remote.LFS {
+		if pointer, ok := parseLFSPointer(contents); ok {
+			return c.readLFSObject(ctx, remote, pointer)
+		}
+	}
+
+	return []byte(contents), nil
+}
+
+// open returns the mirror for url, initializing an empty one if needed. The
+// caller must release the returned entry.
+func (c *Cache) open(url string) (*entry, *git.Repository, error) {
+	e := c.acquire(url)
+
+	e.Lock()
+	defer e.Unlock()
+
+	repo, err := git.PlainOpen(e.dir)
+	if err == git.ErrRepositoryNotExists {
+		repo, err = git.PlainInit(e.dir, true)
+		if err == nil {
+			_, err = repo.CreateRemote(&config.RemoteConfig{
+				Name:  git.DefaultRemoteName,
+				URLs:  []string{url},
+				Fetch: refSpecs,
+			})
+		}
+	}
+	if err != nil {
+		c.release(e)
+
+		return nil, nil, err
+	}
+
+	return e, repo, nil
+}
+
+// update opens the mirror for the remote and fetches it
+func (c *Cache) update(ctx context.Context, remote Remote) (*entry, *git.Repository, error) {
+	e, repo, err := c.open(remote.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = c.fetch(ctx, e, repo, remote.Auth); err != nil {
+		c.release(e)
+
+		return nil, nil, err
+	}
+
+	return e, repo, nil
+}
+
+func (c *Cache) fetch(ctx context.Context, e *entry, repo *git.Repository, auth transport.AuthMethod) error {
+	e.Lock()
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   refSpecs,
+		Auth:       auth,
+		Tags:       git.AllTags,
+		Force:      true,
+	})
+	e.Unlock()
+
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	c.evict()
+
+	return nil
+}
+
+func (c *Cache) acquire(url string) *entry {
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &entry{dir: filepath.Join(c.Dir, key)}
+		c.entries[key] = e
+	}
+	e.users++
+	e.lastUsed = time.Now()
+
+	return e
+}
+
+func (c *Cache) release(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.users--
+}
+
+// evict removes idle mirrors, least recently used first, until the total
+// size of the cache is below MaxBytes. Sizes are computed without holding
+// the cache lock, which is only taken to claim the mirrors evicted.
+func (c *Cache) evict() {
+	if c.MaxBytes <= 0 {
+		return
+	}
+
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+
+	type candidate struct {
+		key      string
+		entry    *entry
+		lastUsed time.Time
+		size     int64
+	}
+
+	c.mu.Lock()
+	candidates := []candidate{}
+	for key, e := range c.entries {
+		candidates = append(candidates, candidate{key: key, entry: e, lastUsed: e.lastUsed})
+	}
+	c.mu.Unlock()
+
+	var total int64
+	for i := range candidates {
+		size, err := dirSize(candidates[i].entry.dir)
+		if err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		candidates[i].size = size
+		total += size
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed.Before(candidates[j].lastUsed)
+	})
+
+	for _, candidate := range candidates {
+		if total <= c.MaxBytes {
+			break
+		}
+
+		evicted := filepath.Join(c.Dir, evictedPrefix+candidate.key)
+
+		c.mu.Lock()
+		e, ok := c.entries[candidate.key]
+		if !ok || e != candidate.entry || e.users > 0 {
+			c.mu.Unlock()
+			continue
+		}
+		err := os.Rename(e.dir, evicted)
+		if err == nil || os.IsNotExist(err) {
+			delete(c.entries, candidate.key)
+		}
+		c.mu.Unlock()
+
+		if err != nil && !os.IsNotExist(err) {
+			c.Log.Error(err, "Failed to evict mirror", "dir", e.dir)
+			continue
+		}
+
+		if err := os.RemoveAll(evicted); err != nil {
+			c.Log.Error(err, "Failed to remove evicted mirror", "dir", evicted)
+		}
+		c.Log.Info("Evicted mirror", "dir", e.dir, "size", candidate.size)
+
+		total -= candidate.size
+	}
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
@@ -0,0 +1,194 @@
+/*
+Unlicensed
+*/
+
+package mirror
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// initRepository creates a repository with one commit of files on master
+func initRepository(t *testing.T, files map[string]string) (string, plumbing.Hash) {
+	dir, err := ioutil.TempDir("", "hedron-mirror-source-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := worktree.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "file://" + dir, hash
+}
+
+func newTestCache(t *testing.T, maxBytes int64) *Cache {
+	dir, err := ioutil.TempDir("", "hedron-mirrors-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cache, err := NewCache(dir, maxBytes, logf.NullLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cache
+}
+
+func TestResolve(t *testing.T) {
+	url, hash := initRepository(t, map[string]string{"hedron.yaml": "matrix: {}\n"})
+	cache := newTestCache(t, 0)
+
+	commit, err := cache.Resolve(context.Background(), Remote{URL: url}, "refs/heads/master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Hash != hash {
+		t.Errorf("expected %s, got %s", hash, commit.Hash)
+	}
+
+	contents, err := cache.ReadFile(context.Background(), Remote{URL: url}, hash.String(), "hedron.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "matrix: {}\n" {
+		t.Errorf("unexpected contents %q", contents)
+	}
+
+	if _, err := cache.ReadFile(context.Background(), Remote{URL: url}, hash.String(), "missing.yaml"); err == nil {
+		t.Error("expected an error reading a missing file")
+	}
+}
+
+func TestCommitFetchesMissing(t *testing.T) {
+	url, hash := initRepository(t, map[string]string{"README": "hello\n"})
+	cache := newTestCache(t, 0)
+
+	commit, err := cache.Commit(context.Background(), Remote{URL: url}, hash.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Message != "Initial commit" {
+		t.Errorf("unexpected message %q", commit.Message)
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	url, _ := initRepository(t, map[string]string{"README": "hello\n"})
+
+	branch, err := DefaultBranch(context.Background(), Remote{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "refs/heads/master" {
+		t.Errorf("expected refs/heads/master, got %s", branch)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DefaultBranch(ctx, Remote{URL: url}); err == nil {
+		t.Error("expected an error listing with a cancelled context")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	first, _ := initRepository(t, map[string]string{"README": "first\n"})
+	second, _ := initRepository(t, map[string]string{"README": "second\n"})
+	cache := newTestCache(t, 0)
+
+	for _, url := range []string{first, second} {
+		if _, err := cache.Resolve(context.Background(), Remote{URL: url}, "refs/heads/master"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cache.entries) != 2 {
+		t.Fatalf("expected 2 mirrors, got %d", len(cache.entries))
+	}
+
+	// Keep the second mirror in use, which protects it from eviction
+	inUse := cache.acquire(second)
+	cache.MaxBytes = 1
+	cache.evict()
+
+	if len(cache.entries) != 1 {
+		t.Fatalf("expected 1 mirror, got %d", len(cache.entries))
+	}
+	if _, err := os.Stat(inUse.dir); err != nil {
+		t.Errorf("expected the mirror in use to remain: %s", err)
+	}
+
+	infos, err := ioutil.ReadDir(cache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Errorf("expected 1 directory, got %d", len(infos))
+	}
+
+	cache.release(inUse)
+	cache.evict()
+
+	if len(cache.entries) != 0 {
+		t.Errorf("expected no mirror, got %d", len(cache.entries))
+	}
+}
+
+func TestNewCacheRegistersMirrors(t *testing.T) {
+	url, _ := initRepository(t, map[string]string{"README": "hello\n"})
+	cache := newTestCache(t, 0)
+
+	if _, err := cache.Resolve(context.Background(), Remote{URL: url}, "refs/heads/master"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(cache.Dir, evictedPrefix+"stale"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewCache(cache.Dir, 0, logf.NullLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.entries) != 1 {
+		t.Errorf("expected 1 mirror, got %d", len(reopened.entries))
+	}
+	if _, err := os.Stat(filepath.Join(cache.Dir, evictedPrefix+"stale")); !os.IsNotExist(err) {
+		t.Error("expected the evicted directory to be removed")
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Unlicensed
+*/
+
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+type lfsPointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+type lfsBatchRequest struct {
+	Operation string       `json:"operation"`
+	Transfers []string     `json:"transfers"`
+	Objects   []lfsPointer `json:"objects"`
+}
+
+type lfsBatchResponse struct {
+	Objects []struct {
+		lfsPointer
+		Actions struct {
+			Download *struct {
+				Href   string            `json:"href"`
+				Header map[string]string `json:"header"`
+			} `json:"download"`
+		} `json:"actions"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"objects"`
+}
+
+// parseLFSPointer recognizes the contents of a Git LFS pointer file
+func parseLFSPointer(contents string) (lfsPointer, bool) {
+	var pointer lfsPointer
+
+	if !strings.HasPrefix(contents, lfsPointerVersion) || len(contents) > 1024 {
+		return pointer, false
+	}
+
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "oid":
+			pointer.OID = strings.TrimPrefix(fields[1], "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return pointer, false
+			}
+			pointer.Size = size
+		}
+	}
+
+	return pointer, pointer.OID != ""
+}
+
+// readLFSObject returns the content of an LFS object, downloading it through
+// the LFS batch API into the mirror directory on first use.
+func (c *Cache) readLFSObject(ctx context.Context, remote Remote, pointer lfsPointer) ([]byte, error) {
+	e := c.acquire(remote.URL)
+	defer c.release(e)
+
+	if len(pointer.OID) < 5 {
+		return nil, fmt.Errorf("invalid LFS object id %q", pointer.OID)
+	}
+	objectPath := filepath.Join(e.dir, "lfs", "objects", pointer.OID[0:2], pointer.OID[2:4], pointer.OID)
+
+	if contents, err := ioutil.ReadFile(objectPath); err == nil {
+		return contents, nil
+	}
+
+	contents, err := downloadLFSObject(ctx, remote, pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return nil, err
+	}
+
+	return contents, ioutil.WriteFile(objectPath, contents, 0644)
+}
+
+func downloadLFSObject(ctx context.Context, remote Remote, pointer lfsPointer) ([]byte, error) {
+	body, err := json.Marshal(lfsBatchRequest{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   []lfsPointer{pointer},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, lfsEndpoint(remote.URL)+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", lfsMediaType)
+	request.Header.Set("Content-Type", lfsMediaType)
+	if auth, ok := remote.Auth.(*githttp.BasicAuth); ok {
+		request.SetBasicAuth(auth.Username, auth.Password)
+	}
+
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LFS batch request failed: %s", response.Status)
+	}
+
+	var batch lfsBatchResponse
+	if err := json.NewDecoder(response.Body).Decode(&batch); err != nil {
+		return nil, err
+	}
+	if len(batch.Objects) != 1 {
+		return nil, fmt.Errorf("LFS batch response has %d objects", len(batch.Objects))
+	}
+
+	object := batch.Objects[0]
+	if object.Error != nil {
+		return nil, fmt.Errorf("LFS object %s: %s", pointer.OID, object.Error.Message)
+	}
+	if object.Actions.Download == nil {
+		return nil, fmt.Errorf("LFS object %s has no download action", pointer.OID)
+	}
+
+	request, err = http.NewRequest(http.MethodGet, object.Actions.Download.Href, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range object.Actions.Download.Header {
+		request.Header.Set(key, value)
+	}
+
+	response, err = http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LFS object %s download failed: %s", pointer.OID, response.Status)
+	}
+
+	hash := sha256.New()
+	contents, err := ioutil.ReadAll(io.TeeReader(response.Body, hash))
+	if err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != pointer.OID {
+		return nil, fmt.Errorf("LFS object %s failed checksum verification", pointer.OID)
+	}
+
+	return contents, nil
+}
+
+// lfsEndpoint infers the LFS server URL of a repository the same way the git
+// lfs client does when no lfs.url is configured.
+func lfsEndpoint(repositoryURL string) string {
+	endpoint := strings.TrimSuffix(repositoryURL, "/")
+
+	if strings.HasPrefix(endpoint, "ssh://") {
+		if u, err := url.Parse(endpoint); err == nil {
+			u.Scheme = "https"
+			u.User = nil
+			endpoint = u.String()
+		}
+	} else if !strings.Contains(endpoint, "://") {
+		// scp-like syntax, e.g. git@github.com:org/repo.git
+		if i := strings.Index(endpoint, "@"); i >= 0 {
+			endpoint = endpoint[i+1:]
+		}
+		endpoint = "https://" + strings.Replace(endpoint, ":", "/", 1)
+	}
+
+	if !strings.HasSuffix(endpoint, ".git") {
+		endpoint += ".git"
+	}
+
+	return endpoint + "/info/lfs"
+}
@@ -0,0 +1,72 @@
+/*
+Unlicensed
+*/
+
+package mirror
+
+import (
+	"testing"
+)
+
+var parseLFSPointerTests = []struct {
+	name     string
+	contents string
+	pointer  lfsPointer
+	ok       bool
+}{
+	{
+		name:     "pointer",
+		contents: "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n",
+		pointer:  lfsPointer{OID: "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", Size: 12345},
+		ok:       true,
+	},
+	{
+		name:     "regular file",
+		contents: "hello world\n",
+	},
+	{
+		name:     "missing oid",
+		contents: "version https://git-lfs.github.com/spec/v1\nsize 12345\n",
+		pointer:  lfsPointer{Size: 12345},
+	},
+	{
+		name:     "invalid size",
+		contents: "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a\nsize large\n",
+		pointer:  lfsPointer{OID: "4d7a"},
+	},
+}
+
+func TestParseLFSPointer(t *testing.T) {
+	for _, test := range parseLFSPointerTests {
+		t.Run(test.name, func(t *testing.T) {
+			pointer, ok := parseLFSPointer(test.contents)
+			if ok != test.ok {
+				t.Errorf("expected ok %v, got %v", test.ok, ok)
+			}
+			if pointer != test.pointer {
+				t.Errorf("expected %+v, got %+v", test.pointer, pointer)
+			}
+		})
+	}
+}
+
+var lfsEndpointTests = []struct {
+	url      string
+	endpoint string
+}{
+	{"https://github.com/org/repo", "https://github.com/org/repo.git/info/lfs"},
+	{"https://github.com/org/repo.git/", "https://github.com/org/repo.git/info/lfs"},
+	{"git@github.com:org/repo.git", "https://github.com/org/repo.git/info/lfs"},
+	{"ssh://git@github.com/org/repo.git", "https://github.com/org/repo.git/info/lfs"},
+	{"ssh://git@git.example.com:2222/org/repo", "https://git.example.com:2222/org/repo.git/info/lfs"},
+}
+
+func TestLFSEndpoint(t *testing.T) {
+	for _, test := range lfsEndpointTests {
+		t.Run(test.url, func(t *testing.T) {
+			if endpoint := lfsEndpoint(test.url); endpoint != test.endpoint {
+				t.Errorf("expected %s, got %s", test.endpoint, endpoint)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Unlicensed
+*/
+
+package mirror
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// submoduleFile is a file path located inside a submodule
+type submoduleFile struct {
+	remote Remote
+	hash   string
+	path   string
+}
+
+// findSubmodule returns where to read filePath from when one of its parent
+// directories is a submodule, or nil when it is a regular path of tree.
+func findSubmodule(tree *object.Tree, remote Remote, filePath string) (*submoduleFile, error) {
+	components := strings.Split(strings.Trim(filePath, "/"), "/")
+
+	for i := 1; i < len(components); i++ {
+		prefix := strings.Join(components[:i], "/")
+
+		entry, err := tree.FindEntry(prefix)
+		if err != nil {
+			return nil, nil
+		}
+		if entry.Mode == filemode.Dir {
+			continue
+		}
+		if entry.Mode != filemode.Submodule {
+			return nil, nil
+		}
+
+		submoduleURL, err := submoduleURL(tree, remote.URL, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		depth := remote.SubmoduleDepth
+		if depth > 0 {
+			depth--
+		}
+
+		return &submoduleFile{
+			remote: Remote{
+				URL:            submoduleURL,
+				Auth:           remote.Auth,
+				SubmoduleDepth: depth,
+				LFS:            remote.LFS,
+			},
+			hash: entry.Hash.String(),
+			path: strings.Join(components[i:], "/"),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// submoduleURL looks up the URL of the submodule at submodulePath in
+// .gitmodules, resolving relative URLs against the parent repository URL.
+func submoduleURL(tree *object.Tree, parentURL string, submodulePath string) (string, error) {
+	file, err := tree.File(".gitmodules")
+	if err != nil {
+		return "", fmt.Errorf("submodule %s: %w", submodulePath, err)
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return "", err
+	}
+
+	modules := config.NewModules()
+	if err := modules.Unmarshal([]byte(contents)); err != nil {
+		return "", err
+	}
+
+	for _, submodule := range modules.Submodules {
+		if submodule.Path != submodulePath {
+			continue
+		}
+		if !strings.HasPrefix(submodule.URL, "./") && !strings.HasPrefix(submodule.URL, "../") {
+			return submodule.URL, nil
+		}
+
+		return resolveRelativeURL(parentURL, submodule.URL)
+	}
+
+	return "", fmt.Errorf("submodule %s is not declared in .gitmodules", submodulePath)
+}
+
+func resolveRelativeURL(parentURL string, relativeURL string) (string, error) {
+	// scp-like syntax, e.g. git@github.com:org/repo.git
+	if !strings.Contains(parentURL, "://") {
+		if i := strings.Index(parentURL, ":"); i >= 0 {
+			return parentURL[:i+1] + strings.TrimPrefix(path.Join(parentURL[i+1:], relativeURL), "/"), nil
+		}
+		return path.Join(parentURL, relativeURL), nil
+	}
+
+	u, err := url.Parse(parentURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, relativeURL)
+
+	return u.String(), nil
+}
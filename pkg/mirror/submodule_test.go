@@ -0,0 +1,35 @@
+/*
+Unlicensed
+*/
+
+package mirror
+
+import (
+	"testing"
+)
+
+var resolveRelativeURLTests = []struct {
+	parent   string
+	relative string
+	url      string
+}{
+	{"https://github.com/org/repo.git", "../lib.git", "https://github.com/org/lib.git"},
+	{"https://github.com/org/repo.git", "./lib.git", "https://github.com/org/repo.git/lib.git"},
+	{"ssh://git@git.example.com:2222/org/repo.git", "../../other/lib.git", "ssh://git@git.example.com:2222/other/lib.git"},
+	{"git@github.com:org/repo.git", "../lib.git", "git@github.com:org/lib.git"},
+	{"/srv/git/repo.git", "../lib.git", "/srv/git/lib.git"},
+}
+
+func TestResolveRelativeURL(t *testing.T) {
+	for _, test := range resolveRelativeURLTests {
+		t.Run(test.parent+" "+test.relative, func(t *testing.T) {
+			url, err := resolveRelativeURL(test.parent, test.relative)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if url != test.url {
+				t.Errorf("expected %s, got %s", test.url, url)
+			}
+		})
+	}
+}
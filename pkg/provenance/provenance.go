@@ -0,0 +1,122 @@
+/*
+Unlicensed
+*/
+
+// Package provenance describes how revisions were built with in-toto
+// statements carrying SLSA provenance.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+const (
+	StatementType = "https://in-toto.io/Statement/v0.1"
+	PredicateType = "https://slsa.dev/provenance/v0.2"
+	PayloadType   = "application/vnd.in-toto+json"
+
+	// BuildType identifies builds of revisions
+	BuildType = "https://hedron.build/Revision@v1beta1"
+
+	// Name is the artifact name provenance is stored under
+	Name = "provenance.intoto.jsonl"
+)
+
+// DigestSet maps hash algorithms to hex-encoded digests
+type DigestSet map[string]string
+
+// Subject is an output of a build
+type Subject struct {
+	Name   string    `json:"name"`
+	Digest DigestSet `json:"digest"`
+}
+
+// Statement binds a predicate to the outputs of a build
+type Statement struct {
+	Type          string    `json:"_type"`
+	PredicateType string    `json:"predicateType"`
+	Subject       []Subject `json:"subject"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+// Predicate is SLSA provenance
+type Predicate struct {
+	Builder    Builder    `json:"builder"`
+	BuildType  string     `json:"buildType"`
+	Invocation Invocation `json:"invocation"`
+	Metadata   Metadata   `json:"metadata"`
+	Materials  []Material `json:"materials,omitempty"`
+}
+
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// Invocation is what started a build
+type Invocation struct {
+	ConfigSource ConfigSource           `json:"configSource"`
+	Parameters   map[string]string      `json:"parameters,omitempty"`
+	Environment  map[string]interface{} `json:"environment,omitempty"`
+}
+
+// ConfigSource is where the definition of a build comes from
+type ConfigSource struct {
+	URI        string    `json:"uri"`
+	Digest     DigestSet `json:"digest"`
+	EntryPoint string    `json:"entryPoint,omitempty"`
+}
+
+type Metadata struct {
+	BuildInvocationID string       `json:"buildInvocationId,omitempty"`
+	BuildStartedOn    *time.Time   `json:"buildStartedOn,omitempty"`
+	BuildFinishedOn   *time.Time   `json:"buildFinishedOn,omitempty"`
+	Completeness      Completeness `json:"completeness"`
+	Reproducible      bool         `json:"reproducible"`
+}
+
+// Completeness tells which parts of a predicate are known to be complete
+type Completeness struct {
+	Parameters  bool `json:"parameters"`
+	Environment bool `json:"environment"`
+	Materials   bool `json:"materials"`
+}
+
+// Material is an input of a build
+type Material struct {
+	URI    string    `json:"uri"`
+	Digest DigestSet `json:"digest"`
+}
+
+// NewStatement returns a statement about subjects
+func NewStatement(subjects []Subject, predicate Predicate) Statement {
+	return Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Subject:       subjects,
+		Predicate:     predicate,
+	}
+}
+
+// SHA256 returns the digest set of JSON-encoded data, such as the pipeline
+// definition of a build
+func SHA256(value interface{}) (DigestSet, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return DigestSet{"sha256": hex.EncodeToString(sum[:])}, nil
+}
+
+// Key returns where the provenance of a revision is stored, next to its
+// artifacts and served as an artifact of the revision itself
+func Key(namespace string, revision string) (string, error) {
+	return storage.Key(namespace, revision, "artifacts", revision, Name)
+}
@@ -0,0 +1,101 @@
+/*
+Unlicensed
+*/
+
+package provenance
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSHA256(t *testing.T) {
+	first, err := SHA256(map[string]string{"b": "2", "a": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SHA256(map[string]string{"a": "1", "b": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The digest of {"a":"1","b":"2"}, as maps are encoded in key order
+	expected := DigestSet{"sha256": "21f76dfbfe6dfe21f762080ef484112cf2952974cef30741fd1931e1c6d92112"}
+	if !reflect.DeepEqual(first, expected) || !reflect.DeepEqual(second, expected) {
+		t.Errorf("expected %v, got %v and %v", expected, first, second)
+	}
+}
+
+func TestStatementJSON(t *testing.T) {
+	statement := NewStatement(
+		[]Subject{{Name: "app-1/app.tar.gz", Digest: DigestSet{"sha256": "0123"}}},
+		Predicate{
+			Builder:   Builder{ID: "https://example.com/builder"},
+			BuildType: BuildType,
+			Invocation: Invocation{
+				ConfigSource: ConfigSource{URI: "git+https://example.com/app.git@refs/heads/main", Digest: DigestSet{"sha1": "abc"}},
+			},
+		},
+	)
+
+	data, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{"_type": StatementType, "predicateType": PredicateType} {
+		if decoded[key] != expected {
+			t.Errorf("expected %s to be %q, got %v", key, expected, decoded[key])
+		}
+	}
+
+	predicate := decoded["predicate"].(map[string]interface{})
+	if predicate["buildType"] != BuildType {
+		t.Errorf("unexpected build type %v", predicate["buildType"])
+	}
+	if _, ok := predicate["materials"]; ok {
+		t.Error("expected no materials")
+	}
+}
+
+var keyTests = []struct {
+	name      string
+	namespace string
+	revision  string
+	key       string
+	valid     bool
+}{
+	{
+		name:      "revision",
+		namespace: "default",
+		revision:  "app-1",
+		key:       "default/app-1/artifacts/app-1/provenance.intoto.jsonl",
+		valid:     true,
+	},
+	{
+		name:      "escaping revision",
+		namespace: "default",
+		revision:  "..",
+		valid:     false,
+	},
+}
+
+func TestKey(t *testing.T) {
+	for _, test := range keyTests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := Key(test.namespace, test.revision)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+			if key != test.key {
+				t.Errorf("expected %q, got %q", test.key, key)
+			}
+		})
+	}
+}
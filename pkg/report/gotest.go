@@ -0,0 +1,92 @@
+/*
+Unlicensed
+*/
+
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// goTestEvent is a line of go test -json output
+type goTestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// ParseGoTestJSON reads go test -json output. Packages failing without a
+// failed test, such as packages that do not build, are reported as a
+// failed test named after the package.
+func ParseGoTestJSON(reader io.Reader) ([]Result, error) {
+	results := []Result{}
+	outputs := map[[2]string]*strings.Builder{}
+	failedTests := map[string]bool{}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		var event goTestEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			// Build output is mixed with events when tests are compiled
+			continue
+		}
+
+		key := [2]string{event.Package, event.Test}
+
+		switch event.Action {
+		case "output":
+			if outputs[key] == nil {
+				outputs[key] = &strings.Builder{}
+			}
+			if outputs[key].Len() < maxMessageLength {
+				outputs[key].WriteString(event.Output)
+			}
+
+		case "pass", "fail", "skip":
+			if event.Test == "" && (event.Action != "fail" || failedTests[event.Package]) {
+				delete(outputs, key)
+				continue
+			}
+
+			result := Result{
+				Suite:    event.Package,
+				Name:     event.Test,
+				Duration: time.Duration(event.Elapsed * float64(time.Second)),
+			}
+			if result.Name == "" {
+				result.Name = event.Package
+			}
+
+			switch event.Action {
+			case "pass":
+				result.Status = Passed
+			case "fail":
+				result.Status = Failed
+				failedTests[event.Package] = true
+				if output := outputs[key]; output != nil {
+					result.Message = truncate(output.String())
+				}
+			case "skip":
+				result.Status = Skipped
+			}
+			delete(outputs, key)
+
+			results = append(results, result)
+		}
+	}
+
+	return results, scanner.Err()
+}
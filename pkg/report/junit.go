@@ -0,0 +1,90 @@
+/*
+Unlicensed
+*/
+
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failures  []junitOutput `xml:"failure"`
+	Errors    []junitOutput `xml:"error"`
+	Skipped   *junitOutput  `xml:"skipped"`
+}
+
+type junitOutput struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit reads JUnit XML reports, whose root is either a testsuites or
+// a testsuite element
+func ParseJUnit(reader io.Reader) ([]Result, error) {
+	var root junitSuite
+	if err := xml.NewDecoder(reader).Decode(&root); err != nil {
+		return nil, err
+	}
+
+	results := []Result{}
+	var walk func(suite junitSuite)
+	walk = func(suite junitSuite) {
+		for _, testCase := range suite.Cases {
+			results = append(results, junitResult(suite, testCase))
+		}
+		for _, child := range suite.Suites {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return results, nil
+}
+
+func junitResult(suite junitSuite, testCase junitCase) Result {
+	result := Result{
+		Suite:  testCase.ClassName,
+		Name:   testCase.Name,
+		Status: Passed,
+	}
+	if result.Suite == "" {
+		result.Suite = suite.Name
+	}
+
+	if seconds, err := strconv.ParseFloat(strings.Replace(testCase.Time, ",", "", -1), 64); err == nil {
+		result.Duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	if outputs := append(testCase.Failures, testCase.Errors...); len(outputs) > 0 {
+		result.Status = Failed
+
+		messages := []string{}
+		for _, output := range outputs {
+			for _, message := range []string{output.Message, strings.TrimSpace(output.Text)} {
+				if message != "" {
+					messages = append(messages, message)
+				}
+			}
+		}
+		result.Message = truncate(strings.Join(messages, "\n"))
+	} else if testCase.Skipped != nil {
+		result.Status = Skipped
+		result.Message = truncate(testCase.Skipped.Message)
+	}
+
+	return result
+}
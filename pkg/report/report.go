@@ -0,0 +1,102 @@
+/*
+Unlicensed
+*/
+
+// Package report parses the test reports of builds into per-test results.
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// Format is the format of a test report
+type Format string
+
+const (
+	JUnit      Format = "junit"
+	GoTestJSON Format = "go-test-json"
+)
+
+// Status is the outcome of a test
+type Status string
+
+const (
+	Passed  Status = "passed"
+	Failed  Status = "failed"
+	Skipped Status = "skipped"
+)
+
+// maxMessageLength bounds the failure messages kept for a test
+const maxMessageLength = 4096
+
+// Result is the outcome of one test
+type Result struct {
+	Suite    string        `json:"suite,omitempty"`
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Message  string        `json:"message,omitempty"`
+}
+
+// Summary totals results
+type Summary struct {
+	Passed   int64
+	Failed   int64
+	Skipped  int64
+	Duration time.Duration
+}
+
+// Parse reads the results of a report
+func Parse(format Format, reader io.Reader) ([]Result, error) {
+	switch format {
+	case JUnit:
+		return ParseJUnit(reader)
+	case GoTestJSON:
+		return ParseGoTestJSON(reader)
+	}
+
+	return nil, fmt.Errorf("unknown report format %q", format)
+}
+
+// Summarize totals results
+func Summarize(results []Result) Summary {
+	var summary Summary
+
+	for _, result := range results {
+		switch result.Status {
+		case Passed:
+			summary.Passed++
+		case Failed:
+			summary.Failed++
+		case Skipped:
+			summary.Skipped++
+		}
+		summary.Duration += result.Duration
+	}
+
+	return summary
+}
+
+func truncate(message string) string {
+	if len(message) > maxMessageLength {
+		return message[:maxMessageLength]
+	}
+
+	return message
+}
+
+// Key returns where a file of a report of a build job is stored
+func Key(namespace string, revision string, job string, name string, index int) (string, error) {
+	return storage.Key(namespace, revision, "reports", job, name, strconv.Itoa(index))
+}
+
+// ResultsKey returns where the results of the reports of a build job are
+// stored
+func ResultsKey(namespace string, revision string, job string) (string, error) {
+	return storage.Key(namespace, revision, "tests", job+".json")
+}
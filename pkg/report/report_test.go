@@ -0,0 +1,94 @@
+/*
+Unlicensed
+*/
+
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var parseTests = []struct {
+	name    string
+	format  Format
+	input   string
+	results []Result
+}{
+	{
+		name:   "junit testsuites",
+		format: JUnit,
+		input: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="api">
+    <testcase classname="api.Users" name="create" time="0.5"/>
+    <testcase classname="api.Users" name="delete" time="1.25">
+      <failure message="expected 204">got 500</failure>
+    </testcase>
+    <testcase classname="api.Users" name="update">
+      <skipped message="not implemented"/>
+    </testcase>
+  </testsuite>
+</testsuites>`,
+		results: []Result{
+			{Suite: "api.Users", Name: "create", Status: Passed, Duration: 500 * time.Millisecond},
+			{Suite: "api.Users", Name: "delete", Status: Failed, Duration: 1250 * time.Millisecond, Message: "expected 204\ngot 500"},
+			{Suite: "api.Users", Name: "update", Status: Skipped, Message: "not implemented"},
+		},
+	},
+	{
+		name:   "junit testsuite",
+		format: JUnit,
+		input: `<testsuite name="web">
+  <testcase name="renders"><error message="timeout"/></testcase>
+</testsuite>`,
+		results: []Result{
+			{Suite: "web", Name: "renders", Status: Failed, Message: "timeout"},
+		},
+	},
+	{
+		name:   "go test json",
+		format: GoTestJSON,
+		input: `{"Action":"run","Package":"example.com/a","Test":"TestOK"}
+{"Action":"pass","Package":"example.com/a","Test":"TestOK","Elapsed":0.1}
+{"Action":"run","Package":"example.com/a","Test":"TestBad"}
+{"Action":"output","Package":"example.com/a","Test":"TestBad","Output":"    a_test.go:10: boom\n"}
+{"Action":"fail","Package":"example.com/a","Test":"TestBad","Elapsed":0.2}
+{"Action":"skip","Package":"example.com/a","Test":"TestLater"}
+{"Action":"fail","Package":"example.com/a","Elapsed":0.3}
+{"Action":"skip","Package":"example.com/b"}`,
+		results: []Result{
+			{Suite: "example.com/a", Name: "TestOK", Status: Passed, Duration: 100 * time.Millisecond},
+			{Suite: "example.com/a", Name: "TestBad", Status: Failed, Duration: 200 * time.Millisecond, Message: "    a_test.go:10: boom\n"},
+			{Suite: "example.com/a", Name: "TestLater", Status: Skipped},
+		},
+	},
+	{
+		name:   "go test json build failure",
+		format: GoTestJSON,
+		input: `# example.com/c
+c.go:3:1: syntax error
+{"Action":"output","Package":"example.com/c","Output":"FAIL\texample.com/c [build failed]\n"}
+{"Action":"fail","Package":"example.com/c","Elapsed":0}`,
+		results: []Result{
+			{Suite: "example.com/c", Name: "example.com/c", Status: Failed, Message: "FAIL\texample.com/c [build failed]\n"},
+		},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range parseTests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := Parse(test.format, strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(results, test.results) {
+				t.Errorf("got %+v, want %+v", results, test.results)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Unlicensed
+*/
+
+// Package sbom summarizes software bills of materials in the CycloneDX and
+// SPDX JSON formats.
+package sbom
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// Summary is the number of components of a bill of materials and their
+// licenses
+type Summary struct {
+	Components int64
+	Licenses   []string
+}
+
+// cycloneDX is the part of CycloneDX documents summaries are made of
+type cycloneDX struct {
+	BOMFormat  string      `json:"bomFormat"`
+	Components []component `json:"components"`
+}
+
+type component struct {
+	Licenses []struct {
+		License *struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"license"`
+		Expression string `json:"expression"`
+	} `json:"licenses"`
+	Components []component `json:"components"`
+}
+
+// spdx is the part of SPDX documents summaries are made of
+type spdx struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+	} `json:"packages"`
+}
+
+// Summarize reads a CycloneDX or SPDX JSON document
+func Summarize(reader io.Reader) (Summary, error) {
+	var document struct {
+		cycloneDX
+		spdx
+	}
+	if err := json.NewDecoder(reader).Decode(&document); err != nil {
+		return Summary{}, err
+	}
+
+	licenses := map[string]bool{}
+	summary := Summary{}
+
+	switch {
+	case document.BOMFormat == "CycloneDX":
+		var walk func(components []component)
+		walk = func(components []component) {
+			for _, component := range components {
+				summary.Components++
+
+				for _, license := range component.Licenses {
+					switch {
+					case license.Expression != "":
+						licenses[license.Expression] = true
+					case license.License != nil && license.License.ID != "":
+						licenses[license.License.ID] = true
+					case license.License != nil && license.License.Name != "":
+						licenses[license.License.Name] = true
+					}
+				}
+
+				walk(component.Components)
+			}
+		}
+		walk(document.Components)
+	case document.SPDXVersion != "":
+		for _, pkg := range document.Packages {
+			summary.Components++
+
+			license := pkg.LicenseConcluded
+			if !isKnownLicense(license) {
+				license = pkg.LicenseDeclared
+			}
+			if isKnownLicense(license) {
+				licenses[license] = true
+			}
+		}
+	default:
+		return Summary{}, errors.New("neither a CycloneDX nor an SPDX document")
+	}
+
+	for license := range licenses {
+		summary.Licenses = append(summary.Licenses, license)
+	}
+	sort.Strings(summary.Licenses)
+
+	return summary, nil
+}
+
+// isKnownLicense reports whether an SPDX license field has a value
+func isKnownLicense(license string) bool {
+	return license != "" && license != "NOASSERTION" && license != "NONE"
+}
@@ -0,0 +1,72 @@
+/*
+Unlicensed
+*/
+
+package sbom
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var summarizeTests = []struct {
+	name    string
+	input   string
+	summary Summary
+}{
+	{
+		name: "cyclonedx",
+		input: `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.4",
+  "components": [
+    {"name": "github.com/go-logr/logr", "licenses": [{"license": {"id": "Apache-2.0"}}]},
+    {"name": "github.com/pkg/errors", "licenses": [{"license": {"name": "BSD 2-clause"}}]},
+    {"name": "alpine", "components": [
+      {"name": "musl", "licenses": [{"expression": "MIT"}]},
+      {"name": "busybox"}
+    ]}
+  ]
+}`,
+		summary: Summary{Components: 5, Licenses: []string{"Apache-2.0", "BSD 2-clause", "MIT"}},
+	},
+	{
+		name: "spdx",
+		input: `{
+  "spdxVersion": "SPDX-2.3",
+  "packages": [
+    {"name": "golang.org/x/crypto", "licenseConcluded": "NOASSERTION", "licenseDeclared": "BSD-3-Clause"},
+    {"name": "k8s.io/api", "licenseConcluded": "Apache-2.0", "licenseDeclared": "Apache-2.0"},
+    {"name": "unknown", "licenseConcluded": "NOASSERTION", "licenseDeclared": "NONE"}
+  ]
+}`,
+		summary: Summary{Components: 3, Licenses: []string{"Apache-2.0", "BSD-3-Clause"}},
+	},
+	{
+		name:    "empty cyclonedx",
+		input:   `{"bomFormat": "CycloneDX", "specVersion": "1.4"}`,
+		summary: Summary{},
+	},
+}
+
+func TestSummarize(t *testing.T) {
+	for _, test := range summarizeTests {
+		t.Run(test.name, func(t *testing.T) {
+			summary, err := Summarize(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(summary, test.summary) {
+				t.Errorf("got %+v, want %+v", summary, test.summary)
+			}
+		})
+	}
+}
+
+func TestSummarizeRejectsOtherDocuments(t *testing.T) {
+	if _, err := Summarize(strings.NewReader(`{"name": "package.json"}`)); err == nil {
+		t.Error("summarized a document that is not a bill of materials")
+	}
+}
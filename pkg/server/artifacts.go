@@ -0,0 +1,150 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// ArtifactKey returns where an artifact of a build job is stored
+func ArtifactKey(namespace string, revision string, job string, name string) (string, error) {
+	if !namePattern.MatchString(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid artifact name %q", name)
+	}
+
+	return storage.Key(namespace, revision, "artifacts", job, name)
+}
+
+// ArtifactRecordsPrefix returns where the statuses of the artifacts of a
+// build job are recorded when they are stored
+func ArtifactRecordsPrefix(namespace string, revision string, job string) (string, error) {
+	return storage.Key(namespace, revision, "artifact-records", job)
+}
+
+// serveArtifacts serves /artifacts/<namespace>/<revision>/<job>/<name>,
+// which build pods PUT and readers of the revision GET
+func (s *Server) serveArtifacts(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/artifacts/"), "/")
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, revisionName, job, name := parts[0], parts[1], parts[2], parts[3]
+
+	// Jobs are named after their revision
+	if !strings.HasPrefix(job, revisionName) {
+		http.NotFound(w, r)
+		return
+	}
+
+	key, err := ArtifactKey(namespace, revisionName, job, name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !s.authorizeRead(r, namespace, revisionName) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.getArtifact(w, r, key, name)
+	case http.MethodPut:
+		if !s.authorize(r, namespace, revisionName) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if _, _, err := s.fetchRevisionProject(r.Context(), namespace, revisionName); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		prefix, err := ArtifactRecordsPrefix(namespace, revisionName, job)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.putArtifact(w, r, key, prefix+"/"+name+".json", job, name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) getArtifact(w http.ResponseWriter, r *http.Request, key string, name string) {
+	reader, err := s.Store.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		s.Log.Error(err, "Failed to fetch artifact", "key", key)
+		http.Error(w, "failed to fetch artifact", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		s.Log.Error(err, "Failed to serve artifact", "key", key)
+	}
+}
+
+func (s *Server) putArtifact(w http.ResponseWriter, r *http.Request, key string, recordKey string, job string, name string) {
+	hash := sha256.New()
+
+	object, err := s.Store.Put(r.Context(), key, io.TeeReader(s.limitUpload(w, r), hash))
+	if err != nil && strings.Contains(err.Error(), "request body too large") {
+		http.Error(w, "artifact exceeds the upload limit", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		s.Log.Error(err, "Failed to store artifact", "key", key)
+		http.Error(w, "failed to store artifact", http.StatusInternalServerError)
+		return
+	}
+	s.Log.Info("Stored artifact", "key", key, "size", object.Size)
+
+	record, err := json.Marshal(v1beta1.ArtifactStatus{
+		Name:   name,
+		Job:    job,
+		Size:   object.Size,
+		Digest: "sha256:" + hex.EncodeToString(hash.Sum(nil)),
+	})
+	if err != nil {
+		http.Error(w, "failed to record artifact", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.Store.Put(r.Context(), recordKey, bytes.NewReader(record)); err != nil {
+		s.Log.Error(err, "Failed to record artifact", "key", key)
+		http.Error(w, "failed to record artifact", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(record)
+}
+
+// limitUpload returns the body of an upload, failing past the upload limit
+func (s *Server) limitUpload(w http.ResponseWriter, r *http.Request) io.Reader {
+	if s.UploadLimit <= 0 {
+		return r.Body
+	}
+
+	return http.MaxBytesReader(w, r.Body, s.UploadLimit)
+}
@@ -0,0 +1,87 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+func artifactRequest(s *Server, method string, token string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/artifacts/default/app-1/app-1-linux/app.tar.gz", strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+
+	s.serveArtifacts(recorder, request)
+
+	return recorder
+}
+
+func TestServeArtifacts(t *testing.T) {
+	s := newTestCacheServer(t)
+	s.UploadLimit = 16
+
+	token := s.Tokens.Sign("default", "app-1")
+
+	if recorder := artifactRequest(s, http.MethodPut, "", "contents"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected uploads without a token to be unauthorized, got status %d", recorder.Code)
+	}
+	if recorder := artifactRequest(s, http.MethodPut, token, strings.Repeat("x", 17)); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected uploads above the limit to be rejected, got status %d", recorder.Code)
+	}
+	if recorder := artifactRequest(s, http.MethodPut, token, "contents"); recorder.Code != http.StatusCreated {
+		t.Fatalf("expected the upload to be stored, got status %d", recorder.Code)
+	}
+
+	prefix, err := ArtifactRecordsPrefix("default", "app-1", "app-1-linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := s.Store.Get(context.Background(), prefix+"/app.tar.gz.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	var artifact v1beta1.ArtifactStatus
+	if err := json.NewDecoder(reader).Decode(&artifact); err != nil {
+		t.Fatal(err)
+	}
+	expected := v1beta1.ArtifactStatus{
+		Name:   "app.tar.gz",
+		Job:    "app-1-linux",
+		Size:   8,
+		Digest: "sha256:d1b2a59fbea7e20077af9f91b27e95e865061b270be03ff539ab3b73587882e8",
+	}
+	if artifact != expected {
+		t.Errorf("expected %+v, got %+v", expected, artifact)
+	}
+
+	for _, test := range []struct {
+		token  string
+		status int
+	}{
+		{token: "", status: http.StatusUnauthorized},
+		{token: "stranger-token", status: http.StatusUnauthorized},
+		{token: "reader-token", status: http.StatusOK},
+		{token: token, status: http.StatusOK},
+	} {
+		recorder := artifactRequest(s, http.MethodGet, test.token, "")
+		if recorder.Code != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.token, test.status, recorder.Code)
+		}
+		if test.status == http.StatusOK && recorder.Body.String() != "contents" {
+			t.Errorf("%q: unexpected body %q", test.token, recorder.Body.String())
+		}
+	}
+}
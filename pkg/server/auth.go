@@ -0,0 +1,71 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// authorizeRead reports whether a request may read the outputs of a revision:
+// its bearer token is either the token of the revision, or the token of a
+// Kubernetes user allowed to get the revision
+func (s *Server) authorizeRead(r *http.Request, namespace string, revision string) bool {
+	if s.authorize(r, namespace, revision) {
+		return true
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || s.Clientset == nil {
+		return false
+	}
+
+	review, err := s.Clientset.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	})
+	if err != nil {
+		s.Log.Error(err, "Failed to review token")
+		return false
+	}
+	if !review.Status.Authenticated {
+		return false
+	}
+
+	user := review.Status.User
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+
+	access, err := s.Clientset.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Group:     v1beta1.GroupVersion.Group,
+				Resource:  "revisions",
+				Name:      revision,
+			},
+		},
+	})
+	if err != nil {
+		s.Log.Error(err, "Failed to review access")
+		return false
+	}
+
+	return access.Status.Allowed
+}
@@ -0,0 +1,212 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// namePattern matches the names that can be used in object keys as is
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// CacheKey returns where the cache entry of a project is stored
+func CacheKey(namespace string, project string, key string) (string, error) {
+	if !namePattern.MatchString(key) || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+
+	return storage.Key(namespace, "caches", project, key+".tar.gz")
+}
+
+// serveCaches serves /caches/<namespace>/<revision>/<key>: build pods GET
+// the cache entries of their project before the build and PUT them after
+func (s *Server) serveCaches(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/caches/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, revisionName, key := parts[0], parts[1], parts[2]
+
+	if !s.authorize(r, namespace, revisionName) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	project, revision, err := s.fetchRevisionProject(r.Context(), namespace, revisionName)
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		s.Log.Error(err, "Failed to fetch project", "revision", revisionName)
+		http.Error(w, "failed to fetch project", http.StatusInternalServerError)
+		return
+	}
+
+	objectKey, err := CacheKey(namespace, project.Name, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.getCache(w, r, objectKey)
+	case http.MethodPut:
+		// Untrusted revisions could poison the caches trusted builds restore
+		if !revision.Trusted(project) {
+			http.Error(w, "caches are only saved by trusted revisions", http.StatusForbidden)
+			return
+		}
+		s.putCache(w, r, project, objectKey)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) getCache(w http.ResponseWriter, r *http.Request, key string) {
+	object, err := s.Store.Stat(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		s.Log.Error(err, "Failed to fetch cache", "key", key)
+		http.Error(w, "failed to fetch cache", http.StatusInternalServerError)
+		return
+	}
+
+	// Mark the entry as recently used so that eviction keeps it
+	if toucher, ok := s.Store.(storage.Toucher); ok {
+		if err := toucher.Touch(r.Context(), key); err != nil {
+			s.Log.Error(err, "Failed to touch cache", "key", key)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	reader, err := s.Store.Get(r.Context(), key)
+	if err != nil {
+		s.Log.Error(err, "Failed to fetch cache", "key", key)
+		http.Error(w, "failed to fetch cache", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(w, reader); err != nil {
+		s.Log.Error(err, "Failed to serve cache", "key", key)
+	}
+}
+
+func (s *Server) putCache(w http.ResponseWriter, r *http.Request, project v1beta1.Project, key string) {
+	quota := s.CacheQuota
+	if project.Spec.CacheQuota != nil {
+		quota = project.Spec.CacheQuota.Value()
+	}
+	if r.ContentLength > quota {
+		http.Error(w, "cache exceeds the project quota", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	object, err := s.Store.Put(r.Context(), key, http.MaxBytesReader(w, r.Body, quota))
+	if err != nil && strings.Contains(err.Error(), "request body too large") {
+		http.Error(w, "cache exceeds the project quota", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		s.Log.Error(err, "Failed to store cache", "key", key)
+		http.Error(w, "failed to store cache", http.StatusInternalServerError)
+		return
+	}
+	s.Log.Info("Stored cache", "key", key, "size", object.Size)
+
+	if err := s.evictCaches(r.Context(), project, quota); err != nil {
+		s.Log.Error(err, "Failed to evict caches", "project", project.Name)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// evictCaches deletes the least recently used cache entries of a project
+// until they fit in its quota
+func (s *Server) evictCaches(ctx context.Context, project v1beta1.Project, quota int64) error {
+	prefix, err := storage.Key(project.Namespace, "caches", project.Name)
+	if err != nil {
+		return err
+	}
+
+	objects, err := s.Store.List(ctx, prefix+"/")
+	if err != nil {
+		return err
+	}
+
+	total := int64(0)
+	for _, object := range objects {
+		total += object.Size
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ModTime.Before(objects[j].ModTime)
+	})
+
+	for _, object := range objects {
+		if total <= quota {
+			break
+		}
+		if err := s.Store.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+		total -= object.Size
+
+		s.Log.Info("Evicted cache", "key", object.Key, "size", object.Size)
+	}
+
+	return nil
+}
+
+// authorize checks the token of the revision a request is made for
+func (s *Server) authorize(r *http.Request, namespace string, revision string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return token != "" && s.Tokens.Verify(token, namespace, revision)
+}
+
+// fetchRevisionProject returns a revision that is still building and its
+// project, as finished revisions no longer act on their project
+func (s *Server) fetchRevisionProject(ctx context.Context, namespace string, revisionName string) (v1beta1.Project, v1beta1.Revision, error) {
+	var revision v1beta1.Revision
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: revisionName}, &revision); err != nil {
+		return v1beta1.Project{}, revision, err
+	}
+
+	switch revision.Status.State {
+	case "Failed", "Succeeded", "Skipped", "Rejected":
+		return v1beta1.Project{}, revision, fmt.Errorf("revision %s not found in a building state", revisionName)
+	}
+
+	var project v1beta1.Project
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: revision.Spec.ProjectRef.Name}, &project); err != nil {
+		return project, revision, err
+	}
+
+	project, _, err := v1beta1.ResolveProject(ctx, s.Client, project)
+
+	return project, revision, err
+}
@@ -0,0 +1,160 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// newTestCacheServer returns a test server whose client knows a project,
+// its trusted revision "app-1" and the untrusted revision "app-2" of a fork
+func newTestCacheServer(t *testing.T) *Server {
+	project := &v1beta1.Project{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app", UID: "project-uid"},
+		Spec: v1beta1.ProjectSpec{
+			Repository: v1beta1.Repository{URL: "https://example.com/app.git", Ref: "refs/heads/main"},
+		},
+	}
+
+	trusted := &v1beta1.Revision{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "app-1",
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(project, v1beta1.GroupVersion.WithKind("Project"))},
+		},
+		Spec: v1beta1.RevisionSpec{ProjectRef: corev1.LocalObjectReference{Name: project.Name}, Ref: "refs/heads/main"},
+	}
+
+	fork := trusted.DeepCopy()
+	fork.Name = "app-2"
+	fork.Spec.PullRequest = &v1beta1.PullRequest{Number: 1, SourceURL: "https://example.com/fork.git"}
+
+	scheme := runtime.NewScheme()
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t)
+	s.Client = fake.NewFakeClientWithScheme(scheme, project, trusted, fork)
+	s.CacheQuota = 1024
+
+	return s
+}
+
+func cacheRequest(s *Server, method string, revision string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/caches/default/"+revision+"/go", strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+s.Tokens.Sign("default", revision))
+	recorder := httptest.NewRecorder()
+
+	s.serveCaches(recorder, request)
+
+	return recorder
+}
+
+func TestServeCaches(t *testing.T) {
+	s := newTestCacheServer(t)
+
+	if recorder := cacheRequest(s, http.MethodHead, "app-1", ""); recorder.Code != http.StatusNotFound {
+		t.Errorf("expected a missing entry, got status %d", recorder.Code)
+	}
+
+	if recorder := cacheRequest(s, http.MethodPut, "app-2", "poisoned"); recorder.Code != http.StatusForbidden {
+		t.Errorf("expected untrusted revisions to be forbidden, got status %d", recorder.Code)
+	}
+	if recorder := cacheRequest(s, http.MethodPut, "app-1", "cached"); recorder.Code != http.StatusCreated {
+		t.Fatalf("expected trusted revisions to save caches, got status %d", recorder.Code)
+	}
+
+	recorder := cacheRequest(s, http.MethodHead, "app-2", "")
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected an existing entry, got status %d", recorder.Code)
+	}
+	if length := recorder.Header().Get("Content-Length"); length != "6" {
+		t.Errorf("expected a length of 6, got %q", length)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Errorf("expected no body, got %q", recorder.Body.String())
+	}
+
+	recorder = cacheRequest(s, http.MethodGet, "app-2", "")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "cached" {
+		t.Errorf("expected the cached entry, got status %d and %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestPutCacheQuota(t *testing.T) {
+	s := newTestCacheServer(t)
+
+	if recorder := cacheRequest(s, http.MethodPut, "app-1", strings.Repeat("x", 1025)); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected entries above the quota to be rejected, got status %d", recorder.Code)
+	}
+
+	project := v1beta1.Project{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}}
+	quota := resource.MustParse("10")
+	project.Spec.CacheQuota = &quota
+
+	request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(strings.Repeat("x", 11)))
+	request.ContentLength = -1
+	recorder := httptest.NewRecorder()
+
+	s.putCache(recorder, request, project, "default/caches/app/go.tar.gz")
+
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected streamed entries above the project quota to fail, got status %d", recorder.Code)
+	}
+	if _, err := s.Store.Stat(context.Background(), "default/caches/app/go.tar.gz"); err != storage.ErrNotFound {
+		t.Errorf("expected no entry, got %v", err)
+	}
+}
+
+func TestEvictCaches(t *testing.T) {
+	s := newTestServer(t)
+	store := s.Store.(*storage.FileStore)
+
+	now := time.Now()
+	for i, key := range []string{"oldest", "old", "recent"} {
+		objectKey, err := CacheKey("default", "app", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Store.Put(context.Background(), objectKey, strings.NewReader("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(filepath.Join(store.Dir, objectKey), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	project := v1beta1.Project{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}}
+	if err := s.evictCaches(context.Background(), project, 15); err != nil {
+		t.Fatal(err)
+	}
+
+	objects, err := s.Store.List(context.Background(), "default/caches/app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != "default/caches/app/recent.tar.gz" {
+		t.Errorf("expected only the most recent entry to remain, got %v", objects)
+	}
+}
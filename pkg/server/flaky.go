@@ -0,0 +1,30 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// serveFlaky serves /flaky/<namespace>/<project>: the tests of a project
+// that flipped between passing and failing, from the flakiest
+func (s *Server) serveFlaky(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/flaky/"), "/")
+	if len(parts) != 2 || s.Flaky == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	scores, ok := s.Flaky.Scores(parts[0], parts[1])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scores)
+}
@@ -0,0 +1,179 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/thmzlt/hedron/pkg/coverage"
+	"github.com/thmzlt/hedron/pkg/report"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// serveReports serves /reports/<namespace>/<revision>/<job>/<name>/<index>,
+// where build pods PUT the files of their test reports
+func (s *Server) serveReports(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/reports/"), "/")
+	if len(parts) != 5 {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, revisionName, job, name := parts[0], parts[1], parts[2], parts[3]
+
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	index, err := strconv.Atoi(parts[4])
+	if err != nil || !strings.HasPrefix(job, revisionName) || !namePattern.MatchString(name) {
+		http.Error(w, "invalid report", http.StatusBadRequest)
+		return
+	}
+
+	key, err := report.Key(namespace, revisionName, job, name, index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.storeUpload(w, r, namespace, revisionName, key)
+}
+
+// serveCoverage serves /coverage/<namespace>/<revision>/<job>/<index>, where
+// build pods PUT the files of their coverage reports
+func (s *Server) serveCoverage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/coverage/"), "/")
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, revisionName, job := parts[0], parts[1], parts[2]
+
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	index, err := strconv.Atoi(parts[3])
+	if err != nil || !strings.HasPrefix(job, revisionName) {
+		http.Error(w, "invalid coverage report", http.StatusBadRequest)
+		return
+	}
+
+	key, err := coverage.Key(namespace, revisionName, job, index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.storeUpload(w, r, namespace, revisionName, key)
+}
+
+// NixOutputsKey returns where the Nix outputs of a build job are stored
+func NixOutputsKey(namespace string, revision string, job string) (string, error) {
+	return storage.Key(namespace, revision, "nix", job)
+}
+
+// serveNixOutputs serves /nix/<namespace>/<revision>/<job>, where build pods
+// PUT the store paths built by Nix along with their NAR hashes
+func (s *Server) serveNixOutputs(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/nix/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, revisionName, job := parts[0], parts[1], parts[2]
+
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(job, revisionName) {
+		http.Error(w, "invalid Nix outputs", http.StatusBadRequest)
+		return
+	}
+
+	key, err := NixOutputsKey(namespace, revisionName, job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.storeUpload(w, r, namespace, revisionName, key)
+}
+
+// storeUpload stores the body of an upload of a build pod of a revision
+func (s *Server) storeUpload(w http.ResponseWriter, r *http.Request, namespace string, revisionName string, key string) {
+	if !s.authorize(r, namespace, revisionName) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if _, _, err := s.fetchRevisionProject(r.Context(), namespace, revisionName); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	_, err := s.Store.Put(r.Context(), key, s.limitUpload(w, r))
+	if err != nil && strings.Contains(err.Error(), "request body too large") {
+		http.Error(w, "upload exceeds the upload limit", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		s.Log.Error(err, "Failed to store upload", "key", key)
+		http.Error(w, "failed to store upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveTests serves /tests/<namespace>/<revision>: the per-test results of
+// the build jobs of a revision, by job
+func (s *Server) serveTests(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/tests/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	prefix, err := storage.Key(parts[0], parts[1], "tests")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	objects, err := s.Store.List(r.Context(), prefix+"/")
+	if err != nil {
+		s.Log.Error(err, "Failed to list test results", "prefix", prefix)
+		http.Error(w, "failed to list test results", http.StatusInternalServerError)
+		return
+	}
+
+	jobs := map[string][]report.Result{}
+	for _, object := range objects {
+		reader, err := s.Store.Get(r.Context(), object.Key)
+		if err != nil {
+			s.Log.Error(err, "Failed to fetch test results", "key", object.Key)
+			continue
+		}
+
+		var results []report.Result
+		err = json.NewDecoder(reader).Decode(&results)
+		reader.Close()
+		if err != nil {
+			s.Log.Error(err, "Failed to decode test results", "key", object.Key)
+			continue
+		}
+
+		jobs[strings.TrimSuffix(path.Base(object.Key), ".json")] = results
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
@@ -0,0 +1,213 @@
+/*
+Unlicensed
+*/
+
+// Package server serves build outputs, such as logs, over HTTP from the
+// manager.
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/thmzlt/hedron/apis/core/v1beta1"
+	"github.com/thmzlt/hedron/pkg/buildlog"
+	"github.com/thmzlt/hedron/pkg/flaky"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// Server is a manager runnable serving build outputs
+type Server struct {
+	Addr      string
+	Client    client.Client
+	Clientset kubernetes.Interface
+	Store     storage.Store
+	Tokens    Tokens
+	Flaky     *flaky.Analyzer
+	Log       logr.Logger
+
+	// CacheQuota is the size cache entries of projects are evicted above,
+	// unless projects set their own quota
+	CacheQuota int64
+
+	// UploadLimit is the size of the largest artifact or report build pods
+	// may upload, unlimited when zero
+	UploadLimit int64
+}
+
+// Start serves until stop is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/logs/", s.serveLogs)
+	mux.HandleFunc("/caches/", s.serveCaches)
+	mux.HandleFunc("/artifacts/", s.serveArtifacts)
+	mux.HandleFunc("/reports/", s.serveReports)
+	mux.HandleFunc("/coverage/", s.serveCoverage)
+	mux.HandleFunc("/nix/", s.serveNixOutputs)
+	mux.HandleFunc("/tests/", s.serveTests)
+	mux.HandleFunc("/flaky/", s.serveFlaky)
+
+	server := &http.Server{Addr: s.Addr, Handler: mux}
+
+	errs := make(chan error, 1)
+	go func() {
+		s.Log.Info("Starting server", "addr", s.Addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-stop:
+		return server.Shutdown(context.Background())
+	case err := <-errs:
+		return err
+	}
+}
+
+// NeedLeaderElection lets every replica serve build outputs
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// serveLogs serves /logs/<namespace>/<revision>/<job>/<container>: the
+// captured log of a finished build, or the masked live log of a running one,
+// to readers of the revision
+func (s *Server) serveLogs(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/logs/"), "/")
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+	namespace, revisionName, job, container := parts[0], parts[1], parts[2], parts[3]
+
+	if !s.authorizeRead(r, namespace, revisionName) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	key, err := buildlog.Key(namespace, revisionName, job, container)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	stored, err := s.Store.Get(r.Context(), key)
+	if err == nil {
+		defer stored.Close()
+
+		if _, err := io.Copy(w, stored); err != nil {
+			s.Log.Error(err, "Failed to serve log", "key", key)
+		}
+		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		s.Log.Error(err, "Failed to fetch log", "key", key)
+		http.Error(w, "failed to fetch log", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.streamLog(w, r, namespace, revisionName, job, container); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.NotFound(w, r)
+			return
+		}
+		s.Log.Error(err, "Failed to stream log", "key", key)
+		http.Error(w, "failed to stream log", http.StatusInternalServerError)
+	}
+}
+
+// streamLog follows the log of a running build container
+func (s *Server) streamLog(w http.ResponseWriter, r *http.Request, namespace string, revisionName string, job string, container string) error {
+	ctx := r.Context()
+
+	var revision v1beta1.Revision
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: revisionName}, &revision); err != nil {
+		return err
+	}
+
+	var project v1beta1.Project
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: revision.Spec.ProjectRef.Name}, &project); err != nil {
+		return err
+	}
+
+	// Template env variables are masked as well
+	project, _, err := v1beta1.ResolveProject(ctx, s.Client, project)
+	if err != nil {
+		return err
+	}
+
+	pod, err := s.fetchPod(ctx, namespace, revisionName, job)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := buildlog.SecretValues(ctx, s.Client, project, revision)
+	if err != nil {
+		return err
+	}
+
+	stream, err := buildlog.Stream(ctx, s.Clientset, namespace, pod.Name, container, true)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	masker := buildlog.NewMasker(flushWriter{w}, secrets)
+	if _, err := io.Copy(masker, stream); err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	return masker.Close()
+}
+
+// fetchPod returns the most recent pod of a build job of a revision
+func (s *Server) fetchPod(ctx context.Context, namespace string, revisionName string, job string) (corev1.Pod, error) {
+	// Build pods are listed from the API rather than the cache, which would
+	// hold every pod of the cluster
+	pods, err := s.Clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"job-name": job}).String(),
+	})
+	if err != nil {
+		return corev1.Pod{}, err
+	}
+
+	var latest *corev1.Pod
+	for i := range pods.Items {
+		// Only serve the pods of the requested revision
+		if pods.Items[i].Labels["hedron.build/revision"] != revisionName {
+			continue
+		}
+		if latest == nil || latest.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			latest = &pods.Items[i]
+		}
+	}
+	if latest == nil {
+		return corev1.Pod{}, errors.New("pods of job " + job + " not found")
+	}
+
+	return *latest, nil
+}
+
+// flushWriter sends output to clients as soon as it is written
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return n, err
+}
@@ -0,0 +1,163 @@
+/*
+Unlicensed
+*/
+
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/thmzlt/hedron/pkg/buildlog"
+	"github.com/thmzlt/hedron/pkg/storage"
+)
+
+// newTestServer returns a server storing objects in a temporary directory,
+// whose Kubernetes users are "reader", allowed to get revisions of the
+// default namespace, and "stranger"
+func newTestServer(t *testing.T) *Server {
+	dir, err := ioutil.TempDir("", "hedron-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		switch review.Spec.Token {
+		case "reader-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "reader"}}
+		case "stranger-token":
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: "stranger"}}
+		}
+
+		return true, review, nil
+	})
+	clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "reader" && attributes.Namespace == "default" &&
+			attributes.Resource == "revisions" && attributes.Verb == "get"
+
+		return true, review, nil
+	})
+
+	return &Server{
+		Clientset: clientset,
+		Store:     &storage.FileStore{Dir: dir},
+		Tokens:    Tokens{Key: []byte("key")},
+		Log:       logf.NullLogger{},
+	}
+}
+
+var authorizeReadTests = []struct {
+	name      string
+	namespace string
+	token     func(s *Server) string
+	allowed   bool
+}{
+	{
+		name:      "revision token",
+		namespace: "default",
+		token:     func(s *Server) string { return s.Tokens.Sign("default", "app-1") },
+		allowed:   true,
+	},
+	{
+		name:      "token of another revision",
+		namespace: "default",
+		token:     func(s *Server) string { return s.Tokens.Sign("default", "app-2") },
+	},
+	{
+		name:      "no token",
+		namespace: "default",
+		token:     func(s *Server) string { return "" },
+	},
+	{
+		name:      "allowed user",
+		namespace: "default",
+		token:     func(s *Server) string { return "reader-token" },
+		allowed:   true,
+	},
+	{
+		name:      "user of another namespace",
+		namespace: "other",
+		token:     func(s *Server) string { return "reader-token" },
+	},
+	{
+		name:      "denied user",
+		namespace: "default",
+		token:     func(s *Server) string { return "stranger-token" },
+	},
+	{
+		name:      "unauthenticated user",
+		namespace: "default",
+		token:     func(s *Server) string { return "invalid-token" },
+	},
+}
+
+func TestAuthorizeRead(t *testing.T) {
+	for _, test := range authorizeReadTests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if token := test.token(s); token != "" {
+				request.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			if allowed := s.authorizeRead(request, test.namespace, "app-1"); allowed != test.allowed {
+				t.Errorf("expected %v, got %v", test.allowed, allowed)
+			}
+		})
+	}
+}
+
+func TestServeLogs(t *testing.T) {
+	s := newTestServer(t)
+
+	key, err := buildlog.Key("default", "app-1", "app-1", "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Store.Put(context.Background(), key, strings.NewReader("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		token  string
+		status int
+		body   string
+	}{
+		{token: "", status: http.StatusUnauthorized},
+		{token: "stranger-token", status: http.StatusUnauthorized},
+		{token: "reader-token", status: http.StatusOK, body: "hello\n"},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/logs/default/app-1/app-1/build", nil)
+		if test.token != "" {
+			request.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		recorder := httptest.NewRecorder()
+
+		s.serveLogs(recorder, request)
+
+		if recorder.Code != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.token, test.status, recorder.Code)
+		}
+		if test.body != "" && recorder.Body.String() != test.body {
+			t.Errorf("%q: unexpected body %q", test.token, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Unlicensed
+*/
+
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedKey is the encrypted private key format of cosign
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ParseCosignPrivateKey parses a private key generated by cosign
+// generate-key-pair, decrypting it with password, or a private key accepted
+// by ParsePrivateKey
+func ParseCosignPrivateKey(data []byte, password []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM-encoded private key")
+	}
+	if block.Type != "ENCRYPTED COSIGN PRIVATE KEY" && block.Type != "ENCRYPTED SIGSTORE PRIVATE KEY" {
+		return ParsePrivateKey(data)
+	}
+
+	var encrypted encryptedKey
+	if err := json.Unmarshal(block.Bytes, &encrypted); err != nil {
+		return nil, err
+	}
+	if encrypted.KDF.Name != "scrypt" || encrypted.Cipher.Name != "nacl/secretbox" || len(encrypted.Cipher.Nonce) != 24 {
+		return nil, errors.New("unsupported private key encryption")
+	}
+
+	params := encrypted.KDF.Params
+	secret, err := scrypt.Key(password, encrypted.KDF.Salt, params.N, params.R, params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	var key [32]byte
+	var nonce [24]byte
+	copy(key[:], secret)
+	copy(nonce[:], encrypted.Cipher.Nonce)
+
+	der, ok := secretbox.Open(nil, encrypted.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, errors.New("wrong private key password")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkSigner(privateKey)
+}
+
+// SignDigest signs the SHA-256 digest of a blob with an ECDSA key, which is
+// what cosign sign-blob outputs once base64-encoded
+func SignDigest(signer crypto.Signer, digest []byte) ([]byte, error) {
+	if _, ok := signer.(*ecdsa.PrivateKey); !ok || len(digest) != sha256.Size {
+		return nil, errors.New("digests can only be signed with ECDSA keys")
+	}
+
+	return signer.Sign(rand.Reader, digest, crypto.SHA256)
+}
+
+// VerifyDigest checks a signature made by SignDigest
+func VerifyDigest(publicKey crypto.PublicKey, digest []byte, signature []byte) error {
+	key, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("digests can only be verified with ECDSA keys")
+	}
+	if !ecdsa.VerifyASN1(key, digest, signature) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+// imagePayload is the simple signing payload cosign signs for images
+type imagePayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// ImagePayload returns the payload cosign signs for the image of a
+// repository with a manifest digest
+func ImagePayload(repository string, digest string) ([]byte, error) {
+	var payload imagePayload
+	payload.Critical.Identity.DockerReference = repository
+	payload.Critical.Image.DockerManifestDigest = digest
+	payload.Critical.Type = "cosign container image signature"
+
+	return json.Marshal(payload)
+}
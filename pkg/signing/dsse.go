@@ -0,0 +1,72 @@
+/*
+Unlicensed
+*/
+
+package signing
+
+import (
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Envelope is a Dead Simple Signing Envelope, which in-toto attestations
+// are distributed in
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a signature of an envelope
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// pae is the pre-authentication encoding of a payload, which is what
+// envelopes sign
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// SignEnvelope wraps a payload in an envelope signed by signer
+func SignEnvelope(signer crypto.Signer, payloadType string, payload []byte) (Envelope, error) {
+	keyID, err := KeyID(signer.Public())
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	signature, err := Sign(signer, pae(payloadType, payload))
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []Signature{{KeyID: keyID, Sig: base64.StdEncoding.EncodeToString(signature)}},
+	}, nil
+}
+
+// VerifyEnvelope returns the payload of an envelope signed by publicKey
+func VerifyEnvelope(publicKey crypto.PublicKey, envelope Envelope) ([]byte, error) {
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, signature := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(signature.Sig)
+		if err != nil {
+			continue
+		}
+
+		if Verify(publicKey, pae(envelope.PayloadType, payload), sig) == nil {
+			return payload, nil
+		}
+	}
+
+	return nil, errors.New("envelope is not signed by the key")
+}
@@ -0,0 +1,170 @@
+/*
+Unlicensed
+*/
+
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+func generateKeys(t *testing.T) map[string][]byte {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaDER, err := x509.MarshalECPrivateKey(ecdsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519DER, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string][]byte{
+		"ecdsa":   pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecdsaDER}),
+		"ed25519": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ed25519DER}),
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	for name, keyPEM := range generateKeys(t) {
+		t.Run(name, func(t *testing.T) {
+			signer, err := ParsePrivateKey(keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			publicPEM, err := MarshalPublicKey(signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			publicKey, err := ParsePublicKey(publicPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			envelope, err := SignEnvelope(signer, "application/vnd.in-toto+json", []byte(`{"_type":"statement"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload, err := VerifyEnvelope(publicKey, envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(payload) != `{"_type":"statement"}` {
+				t.Errorf("got payload %s", payload)
+			}
+
+			tampered := envelope
+			tampered.Payload = base64.StdEncoding.EncodeToString([]byte(`{"_type":"forged"}`))
+			if _, err := VerifyEnvelope(publicKey, tampered); err == nil {
+				t.Error("verified a tampered envelope")
+			}
+
+			retyped := envelope
+			retyped.PayloadType = "text/plain"
+			if _, err := VerifyEnvelope(publicKey, retyped); err == nil {
+				t.Error("verified an envelope with another payload type")
+			}
+		})
+	}
+}
+
+func TestParsePrivateKeyRejectsOtherCurves(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err == nil {
+		t.Error("parsed a P-384 key")
+	}
+}
+
+func TestParseCosignPrivateKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Encrypt the key the way cosign generate-key-pair does
+	var encrypted encryptedKey
+	encrypted.KDF.Name = "scrypt"
+	encrypted.KDF.Params.N, encrypted.KDF.Params.R, encrypted.KDF.Params.P = 1024, 8, 1
+	encrypted.KDF.Salt = []byte("0123456789abcdef0123456789abcdef")
+	encrypted.Cipher.Name = "nacl/secretbox"
+	encrypted.Cipher.Nonce = []byte("0123456789abcdef01234567")
+
+	secret, err := scrypt.Key([]byte("hunter2"), encrypted.KDF.Salt, 1024, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secretKey [32]byte
+	var nonce [24]byte
+	copy(secretKey[:], secret)
+	copy(nonce[:], encrypted.Cipher.Nonce)
+	encrypted.Ciphertext = secretbox.Seal(nil, der, &nonce, &secretKey)
+
+	data, err := json.Marshal(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED COSIGN PRIVATE KEY", Bytes: data})
+
+	if _, err := ParseCosignPrivateKey(keyPEM, []byte("hunter3")); err == nil {
+		t.Error("decrypted a key with the wrong password")
+	}
+
+	signer, err := ParseCosignPrivateKey(keyPEM, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := ImagePayload("registry.example.com/app", "sha256:d2c3b5b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != `{"critical":{"identity":{"docker-reference":"registry.example.com/app"},"image":{"docker-manifest-digest":"sha256:d2c3b5b1"},"type":"cosign container image signature"},"optional":null}` {
+		t.Errorf("got payload %s", payload)
+	}
+
+	digest := sha256.Sum256(payload)
+	signature, err := SignDigest(signer, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyDigest(&key.PublicKey, digest[:], signature); err != nil {
+		t.Error(err)
+	}
+	if err := Verify(&key.PublicKey, payload, signature); err != nil {
+		t.Errorf("digest signatures do not verify as message signatures: %s", err)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Unlicensed
+*/
+
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStore keeps objects as files under Dir, typically on a persistent
+// volume
+type FileStore struct {
+	Dir string
+}
+
+func (s *FileStore) Put(ctx context.Context, key string, reader io.Reader) (Object, error) {
+	path := s.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return Object{}, err
+	}
+
+	// Write to a temporary file first so that readers never see partial objects
+	file, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return Object{}, err
+	}
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Object{}, err
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return Object{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Object{}, err
+	}
+
+	return Object{Key: key, Size: size, ModTime: info.ModTime()}, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return file, err
+}
+
+func (s *FileStore) Stat(ctx context.Context, key string) (Object, error) {
+	info, err := os.Stat(s.path(key))
+	if os.IsNotExist(err) {
+		return Object{}, ErrNotFound
+	} else if err != nil {
+		return Object{}, err
+	}
+
+	return Object{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *FileStore) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+
+	// Only walk the directory the prefix points into
+	root := filepath.Clean(s.Dir)
+	start := root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		start = filepath.Join(root, filepath.FromSlash(prefix[:i]))
+	}
+
+	err := filepath.Walk(start, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+
+		key := filepath.ToSlash(strings.TrimPrefix(path, root+string(filepath.Separator)))
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		}
+
+		return nil
+	})
+
+	return objects, err
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// Touch sets the modification time of an object to now
+func (s *FileStore) Touch(ctx context.Context, key string) error {
+	now := time.Now()
+
+	err := os.Chtimes(s.path(key), now, now)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(key))
+}
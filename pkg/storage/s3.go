@@ -0,0 +1,322 @@
+/*
+Unlicensed
+*/
+
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	emptyPayloadHash    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayloadHash = "UNSIGNED-PAYLOAD"
+)
+
+// S3Store keeps objects in a bucket of an S3-compatible service, addressed
+// path-style so that services such as MinIO work without DNS setup
+type S3Store struct {
+	// Endpoint is the base URL of the service, e.g. https://s3.amazonaws.com
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, reader io.Reader) (Object, error) {
+	// S3 requires the length of objects upfront, so spool them to disk
+	file, err := ioutil.TempFile("", "hedron-s3-")
+	if err != nil {
+		return Object{}, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := io.Copy(file, reader)
+	if err != nil {
+		return Object{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Object{}, err
+	}
+
+	request, err := s.newRequest(ctx, http.MethodPut, key, nil, ioutil.NopCloser(file))
+	if err != nil {
+		return Object{}, err
+	}
+	request.ContentLength = size
+
+	response, err := s.do(request, unsignedPayloadHash)
+	if err != nil {
+		return Object{}, err
+	}
+	response.Body.Close()
+
+	return Object{Key: key, Size: size, ModTime: time.Now()}, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	request, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.do(request, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (Object, error) {
+	request, err := s.newRequest(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return Object{}, err
+	}
+
+	response, err := s.do(request, emptyPayloadHash)
+	if err != nil {
+		return Object{}, err
+	}
+	response.Body.Close()
+
+	modTime, err := http.ParseTime(response.Header.Get("Last-Modified"))
+	if err != nil {
+		modTime = time.Now()
+	}
+
+	return Object{Key: key, Size: response.ContentLength, ModTime: modTime}, nil
+}
+
+// listResult is the response of ListObjectsV2
+type listResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		request, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := s.do(request, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, ModTime: content.LastModified})
+		}
+
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	request, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := s.do(request, emptyPayloadHash)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+// Touch copies an object onto itself, which updates its modification time
+func (s *S3Store) Touch(ctx context.Context, key string) error {
+	request, err := s.newRequest(ctx, http.MethodPut, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("x-amz-copy-source", "/"+s.Bucket+"/"+escapePath(key))
+	request.Header.Set("x-amz-metadata-directive", "REPLACE")
+
+	response, err := s.do(request, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+func (s *S3Store) newRequest(ctx context.Context, method string, key string, query url.Values, body io.ReadCloser) (*http.Request, error) {
+	path := "/" + s.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+
+	location := strings.TrimSuffix(s.Endpoint, "/") + escapePath(path)
+	if len(query) > 0 {
+		location += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequest(method, location, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return request.WithContext(ctx), nil
+}
+
+// do signs and sends a request, turning error responses into errors
+func (s *S3Store) do(request *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(request, payloadHash, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response, nil
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+
+	return nil, fmt.Errorf("%s %s: %s: %s", request.Method, request.URL.Path, response.Status, message)
+}
+
+// sign adds an AWS Signature Version 4 authorization to a request
+func (s *S3Store) sign(request *http.Request, payloadHash string, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+
+	request.Header.Set("x-amz-date", timestamp)
+	request.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		escapePath(request.URL.Path),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// escapePath encodes every byte of a path but unreserved characters and
+// slashes, as signatures require
+func escapePath(path string) string {
+	var builder strings.Builder
+
+	for _, b := range []byte(path) {
+		if b == '/' || isUnreserved(b) {
+			builder.WriteByte(b)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+
+	return builder.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	parameters := []string{}
+	for key, values := range query {
+		for _, value := range values {
+			parameters = append(parameters, escapeQuery(key)+"="+escapeQuery(value))
+		}
+	}
+	sort.Strings(parameters)
+
+	return strings.Join(parameters, "&")
+}
+
+func escapeQuery(s string) string {
+	return strings.Replace(escapePath(s), "/", "%2F", -1)
+}
+
+func isUnreserved(b byte) bool {
+	return 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~'
+}
@@ -0,0 +1,55 @@
+/*
+Unlicensed
+*/
+
+// Package storage stores the files produced by builds, such as logs, behind
+// a common interface implemented by the supported backends.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored object
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Store keeps objects identified by slash-separated keys
+type Store interface {
+	Put(ctx context.Context, key string, reader io.Reader) (Object, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (Object, error)
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Toucher is implemented by stores that can mark an object as used without
+// rewriting it, which least recently used eviction relies on
+type Toucher interface {
+	Touch(ctx context.Context, key string) error
+}
+
+// Key joins parts into an object key, rejecting parts that would escape
+// their prefix
+func Key(parts ...string) (string, error) {
+	for _, part := range parts {
+		for _, segment := range strings.Split(part, "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				return "", errors.New("invalid object key " + strings.Join(parts, "/"))
+			}
+		}
+	}
+
+	return path.Join(parts...), nil
+}